@@ -0,0 +1,104 @@
+// Command migrate creates the PostgreSQL tables of the postgres sink from a file of event declarations.
+//
+//	go run cmd/migrate/migrate.go -f events.abi -dsn postgres://localhost:5432/indexer
+//
+// The file holds one human-readable declaration per line, e.g.
+// "event Transfer(address indexed from, address indexed to, uint256 value)".
+// Function and error fragments are skipped, so an ethers ABI works as is. Empty lines and # comments are ignored.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/ryuux05/indexer-sdk-go/pkg/sink/postgres"
+)
+
+func main() {
+	file := flag.String("f", "", "file of event declarations, one per line")
+	dsn := flag.String("dsn", os.Getenv("DATABASE_URL"), "PostgreSQL connection string, $DATABASE_URL by default")
+	schema := flag.String("schema", "", "schema of the tables, public by default")
+	prefix := flag.String("prefix", "", "prefix of the event table names")
+	cursorTable := flag.String("cursor-table", "", "name of the cursor table, "+postgres.DefaultCursorTable+" by default")
+	dryRun := flag.Bool("dry-run", false, "print the statements instead of running them")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	events, err := readEvents(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if *dryRun {
+		sink, err := postgres.New(nil, postgres.Config{Events: events, Schema: *schema, TablePrefix: *prefix, CursorTable: *cursorTable})
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, stmt := range sink.Schema() {
+			fmt.Printf("%s;\n\n", stmt)
+		}
+		return
+	}
+
+	if *dsn == "" {
+		log.Fatal("missing -dsn or DATABASE_URL")
+	}
+	conn, err := pgx.Connect(ctx, *dsn)
+	if err != nil {
+		log.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close(context.Background())
+
+	sink, err := postgres.New(conn, postgres.Config{Events: events, Schema: *schema, TablePrefix: *prefix, CursorTable: *cursorTable})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := sink.Migrate(ctx); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Migrated %d event tables from %s", len(events), *file)
+}
+
+// readEvents returns the event declarations of the file.
+func readEvents(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keyword, _, _ := strings.Cut(line, " ")
+		switch keyword {
+		case "function", "error", "constructor", "fallback", "receive":
+			continue
+		}
+		events = append(events, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("no event declaration in %s", path)
+	}
+	return events, nil
+}
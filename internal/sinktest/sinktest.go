@@ -0,0 +1,130 @@
+// Package sinktest holds the fixtures shared by the sink tests.
+package sinktest
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+)
+
+const (
+	TransferDecl = "Transfer(address indexed from, address indexed to, uint256 value)"
+	// Token emits the Transfer logs
+	Token = "0xdac17f958d2ee523a2206206994597c13d831ec7"
+)
+
+// Transfer describes a Transfer log, empty fields are derived from Block and LogIndex.
+type Transfer struct {
+	Block    uint64
+	LogIndex uint64
+	// Token by default
+	Address string
+	// From is the block and To the log index as addresses by default
+	From string
+	To   string
+	// Value is Block*100+LogIndex by default, any value core.EncodeArguments takes for a uint256
+	Value any
+}
+
+// Log encodes the Transfer log. Each log has its own transaction.
+func (tr Transfer) Log() core.Log {
+	if tr.Address == "" {
+		tr.Address = Token
+	}
+	if tr.From == "" {
+		tr.From = fmt.Sprintf("0x%040x", tr.Block)
+	}
+	if tr.To == "" {
+		tr.To = fmt.Sprintf("0x%040x", tr.LogIndex)
+	}
+	if tr.Value == nil {
+		tr.Value = tr.Block*100 + tr.LogIndex
+	}
+
+	event, _ := core.ParseEvent(TransferDecl)
+	from, _ := core.EncodeTopic(event.Inputs[0].Type, tr.From)
+	to, _ := core.EncodeTopic(event.Inputs[1].Type, tr.To)
+	data, _ := core.EncodeArguments(event.Inputs[2:], []any{tr.Value})
+	return core.Log{
+		Address:          tr.Address,
+		Topics:           []any{event.Topic().Hex(), from.Hex(), to.Hex()},
+		Data:             "0x" + hex.EncodeToString(data),
+		BlockNumber:      core.Uint64ToHexQty(tr.Block),
+		BlockHash:        BlockHash(tr.Block),
+		TransactionHash:  fmt.Sprintf("0x%062x%02x", tr.Block, tr.LogIndex),
+		TransactionIndex: core.Uint64ToHexQty(tr.LogIndex),
+		LogIndex:         core.Uint64ToHexQty(tr.LogIndex),
+	}
+}
+
+// TransferLog is the default Transfer log at block and logIndex.
+func TransferLog(block, logIndex uint64) core.Log {
+	return Transfer{Block: block, LogIndex: logIndex}.Log()
+}
+
+// BlockHash is the hash of block n on the canonical chain of the fixtures.
+func BlockHash(n uint64) string {
+	return fmt.Sprintf("0x%064x", n)
+}
+
+// Batch is a batch of chain 1 over [from..to] with a TransferLog at each block of blocks.
+func Batch(from, to uint64, blocks ...uint64) core.Batch {
+	b := core.Batch{ChainId: "1", FromBlock: from, ToBlock: to}
+	for _, n := range blocks {
+		b.Logs = append(b.Logs, TransferLog(n, 0))
+	}
+	return b
+}
+
+// RPC serves a TransferLog per block on a canonical chain up to its head.
+type RPC struct {
+	head uint64
+
+	mu sync.Mutex
+	// firstLog is the lowest block requested by eth_getLogs
+	firstLog uint64
+}
+
+// NewRPC creates the RPC of a chain at head.
+func NewRPC(head uint64) *RPC {
+	return &RPC{head: head, firstLog: head + 1}
+}
+
+// FirstLog returns the lowest block requested by eth_getLogs, head+1 before any request.
+func (r *RPC) FirstLog() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.firstLog
+}
+
+func (r *RPC) Head(ctx context.Context) (string, error) {
+	return core.Uint64ToHexQty(r.head), nil
+}
+
+func (r *RPC) GetBlock(ctx context.Context, number string) (core.Block, error) {
+	n, _ := core.HexQtyToUint64(number)
+	return core.Block{Number: number, Hash: BlockHash(n), ParentHash: BlockHash(n - 1), Timestamp: "0x0"}, nil
+}
+
+func (r *RPC) GetLogs(ctx context.Context, filter core.Filter) ([]core.Log, error) {
+	from, _ := core.HexQtyToUint64(filter.FromBlock)
+	to, _ := core.HexQtyToUint64(filter.ToBlock)
+	r.mu.Lock()
+	if from < r.firstLog {
+		r.firstLog = from
+	}
+	r.mu.Unlock()
+
+	var logs []core.Log
+	for n := from; n <= to; n++ {
+		logs = append(logs, TransferLog(n, 0))
+	}
+	return logs, nil
+}
+
+func (r *RPC) GetBlockReceipts(ctx context.Context, number string) ([]core.Receipt, error) {
+	return nil, nil
+}
@@ -0,0 +1,578 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type TypeKind int
+
+const (
+	UintKind TypeKind = iota
+	IntKind
+	BoolKind
+	AddressKind
+	FixedBytesKind // bytes1..bytes32
+	BytesKind
+	StringKind
+	SliceKind // T[]
+	ArrayKind // T[k]
+	TupleKind
+)
+
+// Type is a parsed Solidity ABI type.
+type Type struct {
+	Kind TypeKind
+	// Size is the bit size of ints, the byte size of fixed bytes, the length of arrays
+	Size int
+	// Elem is the element type of slices and arrays
+	Elem *Type
+	// Components are the fields of a tuple
+	Components []Argument
+}
+
+// Argument is a named, possibly indexed, event or function parameter.
+type Argument struct {
+	Name    string
+	Type    Type
+	Indexed bool
+}
+
+// Event is a parsed event declaration.
+type Event struct {
+	Name      string
+	Inputs    []Argument
+	Anonymous bool
+}
+
+// Function is a parsed function declaration.
+type Function struct {
+	Name    string
+	Inputs  []Argument
+	Outputs []Argument
+}
+
+// ParseType parses a type like "uint256", "address[]" or "(address,uint256)[2]".
+func ParseType(s string) (Type, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Type{}, fmt.Errorf("empty type")
+	}
+
+	var t Type
+	rest := ""
+	if strings.HasPrefix(s, "tuple(") {
+		s = s[len("tuple"):]
+	}
+	if s[0] == '(' {
+		end, err := closingParen(s)
+		if err != nil {
+			return Type{}, err
+		}
+		components, err := parseArguments(s[1:end])
+		if err != nil {
+			return Type{}, err
+		}
+		t = Type{Kind: TupleKind, Components: components}
+		rest = s[end+1:]
+	} else {
+		base := s
+		if i := strings.IndexByte(s, '['); i >= 0 {
+			base, rest = s[:i], s[i:]
+		}
+		var err error
+		if t, err = parseElementaryType(base); err != nil {
+			return Type{}, err
+		}
+	}
+
+	// Array suffixes apply left to right, uint256[2][] is a slice of uint256[2]
+	for rest != "" {
+		if rest[0] != '[' {
+			return Type{}, fmt.Errorf("invalid type %q", s)
+		}
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			return Type{}, fmt.Errorf("invalid type %q: unclosed array", s)
+		}
+		elem := t
+		if end == 1 {
+			t = Type{Kind: SliceKind, Elem: &elem}
+		} else {
+			n, err := strconv.Atoi(rest[1:end])
+			if err != nil || n <= 0 {
+				return Type{}, fmt.Errorf("invalid array length in %q", s)
+			}
+			t = Type{Kind: ArrayKind, Size: n, Elem: &elem}
+		}
+		rest = rest[end+1:]
+	}
+
+	return t, nil
+}
+
+func parseElementaryType(s string) (Type, error) {
+	switch s {
+	case "address":
+		return Type{Kind: AddressKind, Size: 20}, nil
+	case "bool":
+		return Type{Kind: BoolKind}, nil
+	case "string":
+		return Type{Kind: StringKind}, nil
+	case "bytes":
+		return Type{Kind: BytesKind}, nil
+	case "uint":
+		return Type{Kind: UintKind, Size: 256}, nil
+	case "int":
+		return Type{Kind: IntKind, Size: 256}, nil
+	}
+
+	parseSize := func(prefix string, min, max, step int) (int, bool) {
+		n, err := strconv.Atoi(strings.TrimPrefix(s, prefix))
+		return n, err == nil && n >= min && n <= max && n%step == 0
+	}
+	switch {
+	case strings.HasPrefix(s, "uint"):
+		if n, ok := parseSize("uint", 8, 256, 8); ok {
+			return Type{Kind: UintKind, Size: n}, nil
+		}
+	case strings.HasPrefix(s, "int"):
+		if n, ok := parseSize("int", 8, 256, 8); ok {
+			return Type{Kind: IntKind, Size: n}, nil
+		}
+	case strings.HasPrefix(s, "bytes"):
+		if n, ok := parseSize("bytes", 1, 32, 1); ok {
+			return Type{Kind: FixedBytesKind, Size: n}, nil
+		}
+	}
+	return Type{}, fmt.Errorf("unsupported type %q", s)
+}
+
+// String returns the canonical form used in signatures.
+func (t Type) String() string {
+	switch t.Kind {
+	case UintKind:
+		return "uint" + strconv.Itoa(t.Size)
+	case IntKind:
+		return "int" + strconv.Itoa(t.Size)
+	case BoolKind:
+		return "bool"
+	case AddressKind:
+		return "address"
+	case FixedBytesKind:
+		return "bytes" + strconv.Itoa(t.Size)
+	case BytesKind:
+		return "bytes"
+	case StringKind:
+		return "string"
+	case SliceKind:
+		return t.Elem.String() + "[]"
+	case ArrayKind:
+		return t.Elem.String() + "[" + strconv.Itoa(t.Size) + "]"
+	case TupleKind:
+		types := make([]string, len(t.Components))
+		for i, c := range t.Components {
+			types[i] = c.Type.String()
+		}
+		return "(" + strings.Join(types, ",") + ")"
+	}
+	return ""
+}
+
+// IsDynamic reports whether values of t are encoded in the tail.
+func (t Type) IsDynamic() bool {
+	switch t.Kind {
+	case BytesKind, StringKind, SliceKind:
+		return true
+	case ArrayKind:
+		return t.Elem.IsDynamic()
+	case TupleKind:
+		for _, c := range t.Components {
+			if c.Type.IsDynamic() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// headSize is the number of bytes t takes in the head of an encoding.
+func (t Type) headSize() int {
+	if t.IsDynamic() {
+		return 32
+	}
+	switch t.Kind {
+	case ArrayKind:
+		return t.Size * t.Elem.headSize()
+	case TupleKind:
+		size := 0
+		for _, c := range t.Components {
+			size += c.Type.headSize()
+		}
+		return size
+	}
+	return 32
+}
+
+// ParseEvent parses a declaration like "Transfer(address indexed from, address indexed to, uint256 value)".
+// The "event" keyword, parameter names and the "anonymous" suffix are optional.
+func ParseEvent(signature string) (Event, error) {
+	name, inputs, rest, err := parseDeclaration("event", signature)
+	if err != nil {
+		return Event{}, err
+	}
+
+	event := Event{Name: name, Inputs: inputs}
+	switch rest {
+	case "":
+	case "anonymous":
+		event.Anonymous = true
+	default:
+		return Event{}, fmt.Errorf("invalid event signature %q: unexpected %q", signature, rest)
+	}
+	return event, nil
+}
+
+// Signature returns the canonical signature, e.g. "Transfer(address,address,uint256)".
+func (e Event) Signature() string {
+	return canonicalSignature(e.Name, e.Inputs)
+}
+
+// Topic returns the topic0 of the event.
+func (e Event) Topic() Hash {
+	var h Hash
+	copy(h[:], Keccak256([]byte(e.Signature())))
+	return h
+}
+
+// IndexedCount returns the number of indexed parameters, the topics of a log after topic0.
+func (e Event) IndexedCount() int {
+	n := 0
+	for _, in := range e.Inputs {
+		if in.Indexed {
+			n++
+		}
+	}
+	return n
+}
+
+// EventKey tells apart events sharing a topic0 by their number of indexed parameters, like ERC20 and ERC721 Transfer.
+type EventKey struct {
+	Topic   Hash
+	Indexed int
+}
+
+// Key returns the key of the logs the event emits.
+func (e Event) Key() EventKey {
+	return EventKey{Topic: e.Topic(), Indexed: e.IndexedCount()}
+}
+
+// LogEventKey returns the key of the events a log may come from, false when it has no valid topic0.
+func LogEventKey(l Log) (EventKey, bool) {
+	if len(l.Topics) == 0 {
+		return EventKey{}, false
+	}
+	s, ok := l.Topics[0].(string)
+	if !ok {
+		return EventKey{}, false
+	}
+	topic, err := ParseHash(s)
+	if err != nil {
+		return EventKey{}, false
+	}
+	return EventKey{Topic: topic, Indexed: len(l.Topics) - 1}, true
+}
+
+// ParseFunction parses a declaration like "transfer(address to, uint256 amount) returns (bool)".
+// The "function" keyword, parameter names, modifiers like "external view" and the returns clause are optional.
+func ParseFunction(signature string) (Function, error) {
+	name, inputs, rest, err := parseDeclaration("function", signature)
+	if err != nil {
+		return Function{}, err
+	}
+
+	fn := Function{Name: name, Inputs: inputs}
+	for rest != "" {
+		if strings.HasPrefix(rest, "returns") {
+			outputs := strings.TrimSpace(rest[len("returns"):])
+			if outputs == "" || outputs[0] != '(' {
+				return Function{}, fmt.Errorf("invalid function signature %q: expected returns list", signature)
+			}
+			close, err := closingParen(outputs)
+			if err != nil {
+				return Function{}, fmt.Errorf("invalid function signature %q: %w", signature, err)
+			}
+			if fn.Outputs, err = parseArguments(outputs[1:close]); err != nil {
+				return Function{}, fmt.Errorf("invalid function signature %q: %w", signature, err)
+			}
+			rest = strings.TrimSpace(outputs[close+1:])
+			continue
+		}
+
+		word, tail, _ := strings.Cut(rest, " ")
+		switch word {
+		case "external", "public", "internal", "private", "view", "pure", "payable", "nonpayable", "virtual", "override", "constant":
+		default:
+			return Function{}, fmt.Errorf("invalid function signature %q: unexpected %q", signature, word)
+		}
+		rest = strings.TrimSpace(tail)
+	}
+	return fn, nil
+}
+
+// Signature returns the canonical signature, e.g. "transfer(address,uint256)".
+func (f Function) Signature() string {
+	return canonicalSignature(f.Name, f.Inputs)
+}
+
+// Selector returns the first 4 bytes of the keccak256 of the signature, prefixing the calldata.
+func (f Function) Selector() [4]byte {
+	var sel [4]byte
+	copy(sel[:], Keccak256([]byte(f.Signature())))
+	return sel
+}
+
+// CustomError is a parsed Solidity custom error declaration.
+type CustomError struct {
+	Name   string
+	Inputs []Argument
+}
+
+// ParseError parses a declaration like "error InsufficientBalance(uint256 available, uint256 required)".
+// The "error" keyword and parameter names are optional.
+func ParseError(signature string) (CustomError, error) {
+	name, inputs, rest, err := parseDeclaration("error", signature)
+	if err != nil {
+		return CustomError{}, err
+	}
+	if rest != "" {
+		return CustomError{}, fmt.Errorf("invalid error signature %q: unexpected %q", signature, rest)
+	}
+	return CustomError{Name: name, Inputs: inputs}, nil
+}
+
+// Signature returns the canonical signature, e.g. "InsufficientBalance(uint256,uint256)".
+func (e CustomError) Signature() string {
+	return canonicalSignature(e.Name, e.Inputs)
+}
+
+// Selector returns the first 4 bytes of the keccak256 of the signature, prefixing the revert data.
+func (e CustomError) Selector() [4]byte {
+	var sel [4]byte
+	copy(sel[:], Keccak256([]byte(e.Signature())))
+	return sel
+}
+
+// ABI is a set of declarations, see ParseABI.
+type ABI struct {
+	Events    []Event
+	Functions []Function
+	Errors    []CustomError
+}
+
+// ParseABI parses a human-readable ABI, one fragment per entry as in ethers:
+//
+//	ParseABI([]string{
+//	    "event Transfer(address indexed from, address indexed to, uint256 value)",
+//	    "function balanceOf(address owner) view returns (uint256)",
+//	    "function swap(tuple(address tokenIn, uint24 fee)[] path) payable",
+//	    "error InsufficientBalance(uint256 available, uint256 required)",
+//	})
+//
+// Constructor, fallback and receive fragments have no selector and are skipped.
+func ParseABI(fragments []string) (ABI, error) {
+	var abi ABI
+	for _, fragment := range fragments {
+		fragment = strings.TrimSpace(fragment)
+		keyword, _, _ := strings.Cut(fragment, " ")
+		if i := strings.IndexByte(keyword, '('); i >= 0 {
+			keyword = keyword[:i]
+		}
+
+		switch keyword {
+		case "event":
+			event, err := ParseEvent(fragment)
+			if err != nil {
+				return ABI{}, err
+			}
+			abi.Events = append(abi.Events, event)
+		case "function":
+			fn, err := ParseFunction(fragment)
+			if err != nil {
+				return ABI{}, err
+			}
+			abi.Functions = append(abi.Functions, fn)
+		case "error":
+			e, err := ParseError(fragment)
+			if err != nil {
+				return ABI{}, err
+			}
+			abi.Errors = append(abi.Errors, e)
+		case "constructor", "fallback", "receive":
+		default:
+			return ABI{}, fmt.Errorf("invalid ABI fragment %q: expected event, function or error", fragment)
+		}
+	}
+	return abi, nil
+}
+
+// CanonicalSignature returns the signature an event, function or error declaration is hashed from,
+// e.g. "Transfer(address,address,uint256)" for "event Transfer(address indexed from, address indexed to, uint256 value)".
+func CanonicalSignature(declaration string) (string, error) {
+	s := strings.TrimSpace(declaration)
+	if strings.HasPrefix(s, "function ") {
+		fn, err := ParseFunction(s)
+		return fn.Signature(), err
+	}
+	if strings.HasPrefix(s, "error ") {
+		e, err := ParseError(s)
+		return e.Signature(), err
+	}
+	if event, err := ParseEvent(s); err == nil {
+		return event.Signature(), nil
+	}
+	fn, err := ParseFunction(s)
+	return fn.Signature(), err
+}
+
+// parseDeclaration parses "[kind] name(parameters) rest", rest being returned trimmed and without a trailing ";".
+func parseDeclaration(kind string, signature string) (string, []Argument, string, error) {
+	s := strings.TrimSpace(signature)
+	s = strings.TrimSpace(strings.TrimPrefix(s, kind+" "))
+
+	open := strings.IndexByte(s, '(')
+	if open <= 0 {
+		return "", nil, "", fmt.Errorf("invalid %s signature %q", kind, signature)
+	}
+	end, err := closingParen(s[open:])
+	if err != nil {
+		return "", nil, "", fmt.Errorf("invalid %s signature %q: %w", kind, signature, err)
+	}
+	end += open
+
+	name := strings.TrimSpace(s[:open])
+	if !isIdentifier(name) {
+		return "", nil, "", fmt.Errorf("invalid %s name %q", kind, name)
+	}
+	inputs, err := parseArguments(s[open+1 : end])
+	if err != nil {
+		return "", nil, "", fmt.Errorf("invalid %s signature %q: %w", kind, signature, err)
+	}
+	if kind != "event" {
+		for _, in := range inputs {
+			if in.Indexed {
+				return "", nil, "", fmt.Errorf("invalid %s signature %q: only event parameters can be indexed", kind, signature)
+			}
+		}
+	}
+
+	rest := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s[end+1:]), ";"))
+	return name, inputs, rest, nil
+}
+
+func canonicalSignature(name string, inputs []Argument) string {
+	types := make([]string, len(inputs))
+	for i, in := range inputs {
+		types[i] = in.Type.String()
+	}
+	return name + "(" + strings.Join(types, ",") + ")"
+}
+
+// parseArguments parses a comma separated parameter list, each being "type [indexed] [name]".
+func parseArguments(s string) ([]Argument, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var args []Argument
+	for _, part := range splitTopLevel(s) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("empty parameter in %q", s)
+		}
+
+		// The type token runs up to the first space outside of parentheses
+		typeEnd := len(part)
+		depth := 0
+		for i, r := range part {
+			if r == '(' {
+				depth++
+			} else if r == ')' {
+				depth--
+			} else if r == ' ' && depth == 0 {
+				typeEnd = i
+				break
+			}
+		}
+
+		t, err := ParseType(part[:typeEnd])
+		if err != nil {
+			return nil, err
+		}
+		arg := Argument{Type: t}
+		for _, word := range strings.Fields(part[typeEnd:]) {
+			switch {
+			case word == "indexed" && !arg.Indexed && arg.Name == "":
+				arg.Indexed = true
+			case word == "memory" || word == "calldata" || word == "storage":
+			case arg.Name == "" && isIdentifier(word):
+				arg.Name = word
+			default:
+				return nil, fmt.Errorf("invalid parameter %q", part)
+			}
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// splitTopLevel splits on commas outside of parentheses.
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// closingParen returns the index of the parenthesis closing s[0].
+func closingParen(s string) (int, error) {
+	depth := 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unbalanced parentheses in %q", s)
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		letter := r == '_' || r == '$' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		if !letter && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var erc20ABI = []string{
+	"constructor(string name, string symbol)",
+	"event Transfer(address indexed from, address indexed to, uint256 value)",
+	"event Approval(address indexed owner, address indexed spender, uint256 value)",
+	"function balanceOf(address owner) view returns (uint256)",
+	"function transfer(address to, uint256 amount) returns (bool)",
+	"function multiSwap(tuple(address tokenIn, uint24 fee)[] path, bytes data) payable",
+	"error Error(string message)",
+	"error Panic(uint256 code)",
+	"error InsufficientBalance(uint256 available, uint256 required)",
+}
+
+func TestParseABI(t *testing.T) {
+	abi, err := ParseABI(erc20ABI)
+	assert.NoError(t, err)
+	assert.Len(t, abi.Events, 2)
+	assert.Len(t, abi.Functions, 3)
+	assert.Len(t, abi.Errors, 3)
+
+	assert.Equal(t, transferTopic, abi.Events[0].Topic().Hex())
+	assert.Equal(t, "multiSwap((address,uint24)[],bytes)", abi.Functions[2].Signature())
+	path := abi.Functions[2].Inputs[0]
+	assert.Equal(t, "path", path.Name)
+	assert.Equal(t, "tokenIn", path.Type.Elem.Components[0].Name)
+
+	assert.Equal(t, [4]byte{0x08, 0xc3, 0x79, 0xa0}, abi.Errors[0].Selector())
+	assert.Equal(t, [4]byte{0x4e, 0x48, 0x7b, 0x71}, abi.Errors[1].Selector())
+
+	_, err = ParseABI([]string{"Transfer(address,address,uint256)"})
+	assert.Error(t, err)
+	_, err = ParseABI([]string{"function transfer(address indexed to, uint256 amount)"})
+	assert.Error(t, err)
+	_, err = ParseABI([]string{"error Failed(uint256) returns (bool)"})
+	assert.Error(t, err)
+}
+
+func TestCanonicalSignature(t *testing.T) {
+	cases := map[string]string{
+		"event Transfer(address indexed from, address indexed to, uint256 value)":         "Transfer(address,address,uint256)",
+		"Transfer(address indexed from, address indexed to, uint256 value)":               "Transfer(address,address,uint256)",
+		"function swap(tuple(address a, uint b) p, bytes calldata data) external":         "swap((address,uint256),bytes)",
+		"exactInput((bytes path, address recipient, uint256 amountIn)) returns (uint256)": "exactInput((bytes,address,uint256))",
+		"error Unauthorized(address caller)":                                              "Unauthorized(address)",
+	}
+	for declaration, expected := range cases {
+		sig, err := CanonicalSignature(declaration)
+		assert.NoError(t, err, declaration)
+		assert.Equal(t, expected, sig)
+	}
+
+	// Names and indexed keywords are no longer hashed into the topic
+	assert.Equal(t, transferTopic, FunctionSignatureToTopic("Transfer(address indexed from, address indexed to, uint256 value)"))
+	assert.Equal(t, transferTopic, FunctionSignatureToTopic("Transfer(address, address, uint256)"))
+	assert.Equal(t, []string{transferTopic}, ConvertToTopics([]string{"event Transfer(address indexed from, address indexed to, uint256 value)"}))
+}
+
+func TestDecoder_RegisterABI(t *testing.T) {
+	abi, _ := ParseABI(erc20ABI)
+	decoder := NewDecoder()
+	assert.NoError(t, decoder.RegisterABI(abi))
+
+	ev, err := decoder.Decode(Log{
+		Topics: []any{transferTopic, addressTopic(testPoolA), addressTopic(testPoolB)},
+		Data:   "0x" + "000000000000000000000000000000000000000000000000000000000000002a",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(42), ev.Args["value"])
+
+	input, _ := hexToBytes(transferInput(testPoolA, 5))
+	call, err := decoder.DecodeCall(input)
+	assert.NoError(t, err)
+	assert.Equal(t, "transfer", call.Name)
+	assert.Equal(t, big.NewInt(5), call.Args["amount"])
+}
+
+func TestEventKey(t *testing.T) {
+	erc20, err := ParseEvent("event Transfer(address indexed from, address indexed to, uint256 value)")
+	assert.NoError(t, err)
+	erc721, err := ParseEvent("event Transfer(address indexed from, address indexed to, uint256 indexed tokenId)")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, erc20.IndexedCount())
+	assert.NotEqual(t, erc20.Key(), erc721.Key(), "ERC20 and ERC721 Transfer share topic0")
+
+	l := Log{Topics: []any{erc721.Topic().Hex(), addressTopic(testPoolA), addressTopic(testPoolB), addressTopic(testPoolA)}}
+	key, ok := LogEventKey(l)
+	assert.True(t, ok)
+	assert.Equal(t, erc721.Key(), key)
+
+	_, ok = LogEventKey(Log{})
+	assert.False(t, ok)
+	_, ok = LogEventKey(Log{Topics: []any{"0xddf252ad"}})
+	assert.False(t, ok)
+}
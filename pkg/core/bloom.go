@@ -0,0 +1,87 @@
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// BloomByteLength is the size of the logsBloom in blocks and receipts.
+const BloomByteLength = 256
+
+// Bloom is the 2048-bit filter of every log address and topic in a receipt or block.
+type Bloom [BloomByteLength]byte
+
+// ParseBloom decodes a 0x-prefixed logsBloom.
+func ParseBloom(s string) (Bloom, error) {
+	var b Bloom
+	raw, err := hexToBytes(s)
+	if err != nil {
+		return b, err
+	}
+	if len(raw) != BloomByteLength {
+		return b, fmt.Errorf("invalid bloom length %d, expected %d", len(raw), BloomByteLength)
+	}
+	copy(b[:], raw)
+	return b, nil
+}
+
+// Add sets the 3 bits derived from keccak256(data).
+func (b *Bloom) Add(data []byte) {
+	hash := Keccak256(data)
+	for i := 0; i < 6; i += 2 {
+		bit := (uint(hash[i])<<8 | uint(hash[i+1])) & 2047
+		b[BloomByteLength-1-bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// Test reports whether data may be in the bloom. False positives are possible, false negatives are not.
+func (b Bloom) Test(data []byte) bool {
+	var probe Bloom
+	probe.Add(data)
+	for i := range probe {
+		if b[i]&probe[i] != probe[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Or merges other into b.
+func (b *Bloom) Or(other Bloom) {
+	for i := range b {
+		b[i] |= other[i]
+	}
+}
+
+func (b Bloom) String() string {
+	return "0x" + hex.EncodeToString(b[:])
+}
+
+// LogsBloom builds the bloom of the given logs the same way a node does.
+func LogsBloom(logs []Log) (Bloom, error) {
+	var b Bloom
+	for _, l := range logs {
+		tl, err := l.Typed()
+		if err != nil {
+			return b, err
+		}
+		b.Add(tl.Address[:])
+		for _, t := range tl.Topics {
+			b.Add(t[:])
+		}
+	}
+	return b, nil
+}
+
+// logInBloom reports whether the log address and all of its topics may be in b.
+func logInBloom(b Bloom, l TypedLog) bool {
+	if !b.Test(l.Address[:]) {
+		return false
+	}
+	for _, t := range l.Topics {
+		if !b.Test(t[:]) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,5 +1,424 @@
 package core
 
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// Decoder decodes logs of the registered events, calldata of the registered functions and revert data of the registered errors.
 type Decoder struct {
-	
-}
\ No newline at end of file
+	events    map[Hash][]Event
+	functions map[[4]byte]Function
+	errors    map[[4]byte]CustomError
+}
+
+// DecodedEvent is a log decoded against its event declaration.
+//
+// Values are decoded as:
+//   - uintN, intN: *big.Int
+//   - address: AddressBytes
+//   - bool: bool
+//   - bytesN, bytes: []byte
+//   - string: string
+//   - arrays, slices and tuples: []any
+//   - indexed string, bytes, arrays and tuples: Hash, only their keccak256 is in the topic
+type DecodedEvent struct {
+	Name string
+	// Canonical signature, e.g. "Transfer(address,address,uint256)"
+	Signature string
+	// Values in declaration order
+	Values []any
+	// Args by parameter name, unnamed parameters are keyed arg0, arg1...
+	Args map[string]any
+	// Raw log
+	Log Log
+}
+
+// DecodedCall is calldata decoded against its function declaration, values are decoded as in DecodedEvent.
+type DecodedCall struct {
+	Name string
+	// Canonical signature, e.g. "transfer(address,uint256)"
+	Signature string
+	Selector  [4]byte
+	// Values in declaration order
+	Values []any
+	// Args by parameter name, unnamed parameters are keyed arg0, arg1...
+	Args map[string]any
+}
+
+func NewDecoder() *Decoder {
+	return &Decoder{
+		events:    make(map[Hash][]Event),
+		functions: make(map[[4]byte]Function),
+		errors:    make(map[[4]byte]CustomError),
+	}
+}
+
+// Register parses and adds an event declaration, see ParseEvent.
+func (d *Decoder) Register(signature string) (Event, error) {
+	event, err := ParseEvent(signature)
+	if err != nil {
+		return Event{}, err
+	}
+	return d.addEvent(event)
+}
+
+// RegisterABI adds the events, functions and errors of a human-readable ABI, see ParseABI.
+// Anonymous events are skipped since logs can't be matched to them.
+func (d *Decoder) RegisterABI(abi ABI) error {
+	for _, event := range abi.Events {
+		if event.Anonymous {
+			continue
+		}
+		if _, err := d.addEvent(event); err != nil {
+			return err
+		}
+	}
+	for _, fn := range abi.Functions {
+		d.addFunction(fn)
+	}
+	for _, e := range abi.Errors {
+		d.addError(e)
+	}
+	return nil
+}
+
+func (d *Decoder) addEvent(event Event) (Event, error) {
+	if event.Anonymous {
+		return Event{}, fmt.Errorf("anonymous event %s can't be matched by topic", event.Name)
+	}
+	topic := event.Topic()
+	for _, registered := range d.events[topic] {
+		if registered.IndexedCount() == event.IndexedCount() {
+			return registered, nil
+		}
+	}
+	d.events[topic] = append(d.events[topic], event)
+	return event, nil
+}
+
+// Decode decodes a log with the first registered event matching its topic0 and topic count.
+// Events sharing topic0, like ERC20 and ERC721 Transfer, are told apart by their indexed parameters.
+func (d *Decoder) Decode(l Log) (*DecodedEvent, error) {
+	tl, err := l.Typed()
+	if err != nil {
+		return nil, err
+	}
+	return d.decode(l, tl)
+}
+
+// decode is Decode on a log already parsed into tl.
+func (d *Decoder) decode(l Log, tl TypedLog) (*DecodedEvent, error) {
+	if len(tl.Topics) == 0 {
+		return nil, fmt.Errorf("log has no topic")
+	}
+	topic := tl.Topics[0]
+
+	events, ok := d.events[topic]
+	if !ok {
+		return nil, fmt.Errorf("no event registered for topic %s", topic.Hex())
+	}
+	for _, event := range events {
+		if event.IndexedCount()+1 == len(tl.Topics) {
+			return event.decodeLog(l, tl)
+		}
+	}
+	return nil, fmt.Errorf("no event registered for topic %s with %d topics", topic.Hex(), len(tl.Topics))
+}
+
+// RegisterFunction parses and adds a function declaration, see ParseFunction.
+func (d *Decoder) RegisterFunction(signature string) (Function, error) {
+	fn, err := ParseFunction(signature)
+	if err != nil {
+		return Function{}, err
+	}
+	return d.addFunction(fn), nil
+}
+
+func (d *Decoder) addFunction(fn Function) Function {
+	if registered, ok := d.functions[fn.Selector()]; ok {
+		return registered
+	}
+	d.functions[fn.Selector()] = fn
+	return fn
+}
+
+// DecodeCall decodes calldata with the registered function matching its 4-byte selector.
+func (d *Decoder) DecodeCall(input []byte) (*DecodedCall, error) {
+	if len(input) < 4 {
+		return nil, fmt.Errorf("calldata too short for a selector")
+	}
+	var sel [4]byte
+	copy(sel[:], input)
+	fn, ok := d.functions[sel]
+	if !ok {
+		return nil, fmt.Errorf("no function registered for selector 0x%x", sel)
+	}
+	return fn.DecodeCall(input)
+}
+
+// DecodeLog decodes the topics and data of a log emitted by e.
+func (e Event) DecodeLog(l Log) (*DecodedEvent, error) {
+	tl, err := l.Typed()
+	if err != nil {
+		return nil, err
+	}
+	return e.decodeLog(l, tl)
+}
+
+// decodeLog is DecodeLog on a log already parsed into tl.
+func (e Event) decodeLog(l Log, tl TypedLog) (*DecodedEvent, error) {
+	topics := tl.Topics
+	if !e.Anonymous {
+		if len(topics) == 0 || topics[0] != e.Topic() {
+			return nil, fmt.Errorf("log is not a %s event", e.Signature())
+		}
+		topics = topics[1:]
+	}
+	if len(topics) != e.IndexedCount() {
+		return nil, fmt.Errorf("%s expects %d indexed topics, log has %d", e.Signature(), e.IndexedCount(), len(topics))
+	}
+
+	var dataArgs []Argument
+	for _, in := range e.Inputs {
+		if !in.Indexed {
+			dataArgs = append(dataArgs, in)
+		}
+	}
+	dataValues, err := DecodeArguments(dataArgs, tl.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s data: %w", e.Signature(), err)
+	}
+
+	ev := &DecodedEvent{
+		Name:      e.Name,
+		Signature: e.Signature(),
+		Values:    make([]any, len(e.Inputs)),
+		Args:      make(map[string]any, len(e.Inputs)),
+		Log:       l,
+	}
+	for i, in := range e.Inputs {
+		var value any
+		if in.Indexed {
+			topic := topics[0]
+			topics = topics[1:]
+			if in.Type.IsDynamic() || in.Type.Kind == ArrayKind || in.Type.Kind == TupleKind {
+				value = topic
+			} else if value, err = decodeValue(in.Type, topic[:], 0); err != nil {
+				return nil, fmt.Errorf("failed to decode indexed %s: %w", in.Name, err)
+			}
+		} else {
+			value = dataValues[0]
+			dataValues = dataValues[1:]
+		}
+
+		ev.Values[i] = value
+		name := in.Name
+		if name == "" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		ev.Args[name] = value
+	}
+	return ev, nil
+}
+
+// DecodeCall decodes calldata of f, selector included.
+func (f Function) DecodeCall(input []byte) (*DecodedCall, error) {
+	sel := f.Selector()
+	if len(input) < 4 || [4]byte(input[:4]) != sel {
+		return nil, fmt.Errorf("calldata is not a %s call", f.Signature())
+	}
+	values, err := DecodeArguments(f.Inputs, input[4:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s calldata: %w", f.Signature(), err)
+	}
+
+	call := &DecodedCall{
+		Name:      f.Name,
+		Signature: f.Signature(),
+		Selector:  sel,
+		Values:    values,
+		Args:      make(map[string]any, len(values)),
+	}
+	for i, in := range f.Inputs {
+		name := in.Name
+		if name == "" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		call.Args[name] = values[i]
+	}
+	return call, nil
+}
+
+// DecodeArguments decodes ABI encoded data, e.g. log data or call return data.
+func DecodeArguments(args []Argument, data []byte) ([]any, error) {
+	types := make([]Type, len(args))
+	for i, a := range args {
+		types[i] = a.Type
+	}
+	return decodeTuple(types, data, 0)
+}
+
+// decodeTuple decodes consecutive values whose head starts at offset, dynamic offsets are relative to it.
+func decodeTuple(types []Type, data []byte, offset int) ([]any, error) {
+	values := make([]any, len(types))
+	head := offset
+	for i, t := range types {
+		if t.IsDynamic() {
+			rel, err := readSize(data, head)
+			if err != nil {
+				return nil, err
+			}
+			if values[i], err = decodeValue(t, data, offset+rel); err != nil {
+				return nil, err
+			}
+		} else {
+			var err error
+			if values[i], err = decodeValue(t, data, head); err != nil {
+				return nil, err
+			}
+		}
+		head += t.headSize()
+	}
+	return values, nil
+}
+
+// decodeValue decodes one value whose encoding starts at offset.
+func decodeValue(t Type, data []byte, offset int) (any, error) {
+	switch t.Kind {
+	case UintKind, IntKind, BoolKind, AddressKind, FixedBytesKind:
+		word, err := readWord(data, offset)
+		if err != nil {
+			return nil, err
+		}
+		return decodeWord(t, word)
+
+	case BytesKind, StringKind:
+		length, err := readSize(data, offset)
+		if err != nil {
+			return nil, err
+		}
+		start := offset + 32
+		if start+length > len(data) {
+			return nil, fmt.Errorf("%s of length %d overflows data", t, length)
+		}
+		b := append([]byte{}, data[start:start+length]...)
+		if t.Kind == StringKind {
+			return string(b), nil
+		}
+		return b, nil
+
+	case SliceKind:
+		length, err := readSize(data, offset)
+		if err != nil {
+			return nil, err
+		}
+		if length > len(data) {
+			return nil, fmt.Errorf("%s of length %d overflows data", t, length)
+		}
+		return decodeTuple(repeatType(*t.Elem, length), data, offset+32)
+
+	case ArrayKind:
+		return decodeTuple(repeatType(*t.Elem, t.Size), data, offset)
+
+	case TupleKind:
+		types := make([]Type, len(t.Components))
+		for i, c := range t.Components {
+			types[i] = c.Type
+		}
+		return decodeTuple(types, data, offset)
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// decodeWord decodes a static elementary value.
+func decodeWord(t Type, word []byte) (any, error) {
+	switch t.Kind {
+	case UintKind:
+		v := new(big.Int).SetBytes(word)
+		if v.BitLen() > t.Size {
+			return nil, fmt.Errorf("value overflows %s", t)
+		}
+		return v, nil
+	case IntKind:
+		v := new(big.Int).SetBytes(word)
+		if word[0]&0x80 != 0 {
+			v.Sub(v, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		bound := new(big.Int).Lsh(big.NewInt(1), uint(t.Size-1))
+		if v.Cmp(bound) >= 0 || v.Cmp(new(big.Int).Neg(bound)) < 0 {
+			return nil, fmt.Errorf("value overflows %s", t)
+		}
+		return v, nil
+	case BoolKind:
+		v := new(big.Int).SetBytes(word)
+		if v.BitLen() > 1 {
+			return nil, fmt.Errorf("invalid bool %s", v)
+		}
+		return v.Sign() == 1, nil
+	case AddressKind:
+		var a AddressBytes
+		copy(a[:], word[12:])
+		return a, nil
+	case FixedBytesKind:
+		return append([]byte{}, word[:t.Size]...), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+func repeatType(t Type, n int) []Type {
+	types := make([]Type, n)
+	for i := range types {
+		types[i] = t
+	}
+	return types
+}
+
+func readWord(data []byte, offset int) ([]byte, error) {
+	if offset < 0 || offset+32 > len(data) {
+		return nil, fmt.Errorf("data too short: need 32 bytes at offset %d, have %d", offset, len(data))
+	}
+	return data[offset : offset+32], nil
+}
+
+// readSize reads a word used as an offset or length, it must fit the data.
+func readSize(data []byte, offset int) (int, error) {
+	word, err := readWord(data, offset)
+	if err != nil {
+		return 0, err
+	}
+	for _, b := range word[:24] {
+		if b != 0 {
+			return 0, fmt.Errorf("offset or length too large at %d", offset)
+		}
+	}
+	n := binary.BigEndian.Uint64(word[24:])
+	if n > uint64(len(data)) {
+		return 0, fmt.Errorf("offset or length %d out of bounds at %d", n, offset)
+	}
+	return int(n), nil
+}
+
+// JSONValue converts a decoded value to a JSON friendly form, for sinks and APIs:
+// integers as decimal strings, bytes as 0x hex, addresses and hashes as hex, arrays and tuples as lists.
+func JSONValue(v any) any {
+	switch x := v.(type) {
+	case *big.Int:
+		return x.String()
+	case []byte:
+		return "0x" + hex.EncodeToString(x)
+	case AddressBytes:
+		return x.Hex()
+	case Hash:
+		return x.Hex()
+	case []any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = JSONValue(e)
+		}
+		return out
+	}
+	return v
+}
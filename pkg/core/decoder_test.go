@@ -0,0 +1,296 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	complexEvent = "event Complex(address indexed sender, int256 amount, string memo, uint256[] ids, (address a, uint64 n) pair, bytes4[2] tags, bool flag)"
+	complexTopic = "0x389680ddfc9a48aa3ab211768a48e4fff065f9e02d5425539fce1fdc10bd21fa"
+	// Packed with go-ethereum accounts/abi
+	complexData = "0xfffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffb000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000001400000000000000000000000008ad599c3a0ff1de082011efddc58f1908eb6e6d80000000000000000000000000000000000000000000000000000000000000007010203040000000000000000000000000000000000000000000000000000000005060708000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000001000000000000000000000000000000000000000000000000000000000000000568656c6c6f000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002"
+
+	transferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+)
+
+func addressTopic(addr string) string {
+	return "0x" + strings.Repeat("0", 24) + strings.TrimPrefix(addr, "0x")
+}
+
+func TestParseFunction(t *testing.T) {
+	fn, err := ParseFunction("function transfer(address to, uint256 amount) external returns (bool)")
+	assert.NoError(t, err)
+	assert.Equal(t, "transfer(address,uint256)", fn.Signature())
+	assert.Equal(t, [4]byte{0xa9, 0x05, 0x9c, 0xbb}, fn.Selector())
+	assert.Len(t, fn.Outputs, 1)
+
+	fn, err = ParseFunction("swap((address,uint24) params, bytes calldata data) payable")
+	assert.NoError(t, err)
+	assert.Equal(t, "swap((address,uint24),bytes)", fn.Signature())
+
+	_, err = ParseFunction("transfer(address,uint256) returns")
+	assert.Error(t, err)
+	_, err = ParseFunction("transfer(address,uint256) mutable")
+	assert.Error(t, err)
+
+	input, _ := hexToBytes(transferInput(testOther, 5))
+	call, err := fn.DecodeCall(input)
+	assert.Error(t, err)
+	assert.Nil(t, call)
+}
+
+func TestParseEvent(t *testing.T) {
+	event, err := ParseEvent(complexEvent)
+	assert.NoError(t, err)
+	assert.Equal(t, "Complex", event.Name)
+	assert.Equal(t, "Complex(address,int256,string,uint256[],(address,uint64),bytes4[2],bool)", event.Signature())
+	assert.Equal(t, complexTopic, event.Topic().Hex())
+	assert.True(t, event.Inputs[0].Indexed)
+	assert.Equal(t, "sender", event.Inputs[0].Name)
+	assert.Equal(t, "pair", event.Inputs[4].Name)
+	assert.Equal(t, "n", event.Inputs[4].Type.Components[1].Name)
+
+	transfer, err := ParseEvent("Transfer(address indexed from,address indexed to,uint256 value)")
+	assert.NoError(t, err)
+	assert.Equal(t, transferTopic, transfer.Topic().Hex())
+
+	for _, invalid := range []string{
+		"Transfer",
+		"Transfer(address,address,uint257)",
+		"Transfer(address indexed indexed from)",
+		"Transfer(address,(uint256)",
+		"1Transfer(address)",
+	} {
+		_, err := ParseEvent(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseType(t *testing.T) {
+	typ, err := ParseType("(uint256,address[])[2][]")
+	assert.NoError(t, err)
+	assert.Equal(t, SliceKind, typ.Kind)
+	assert.Equal(t, ArrayKind, typ.Elem.Kind)
+	assert.Equal(t, "(uint256,address[])[2][]", typ.String())
+	assert.True(t, typ.Elem.IsDynamic())
+
+	typ, err = ParseType("tuple(uint8,bytes32)[3]")
+	assert.NoError(t, err)
+	assert.False(t, typ.IsDynamic())
+	assert.Equal(t, 6*32, typ.headSize())
+
+	typ, err = ParseType("uint")
+	assert.NoError(t, err)
+	assert.Equal(t, "uint256", typ.String())
+}
+
+func TestDecoder_ComplexEvent(t *testing.T) {
+	decoder := NewDecoder()
+	_, err := decoder.Register(complexEvent)
+	assert.NoError(t, err)
+
+	ev, err := decoder.Decode(Log{
+		Address: testOther,
+		Topics:  []any{complexTopic, addressTopic(testPoolA)},
+		Data:    complexData,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Complex", ev.Name)
+	assert.Equal(t, testPoolA, ev.Args["sender"].(AddressBytes).Hex())
+	assert.Equal(t, big.NewInt(-5), ev.Args["amount"])
+	assert.Equal(t, "hello", ev.Args["memo"])
+	assert.Equal(t, []any{big.NewInt(1), big.NewInt(2)}, ev.Args["ids"])
+
+	pair := ev.Args["pair"].([]any)
+	assert.Equal(t, testPoolA, pair[0].(AddressBytes).Hex())
+	assert.Equal(t, big.NewInt(7), pair[1])
+
+	assert.Equal(t, []any{[]byte{1, 2, 3, 4}, []byte{5, 6, 7, 8}}, ev.Args["tags"])
+	assert.Equal(t, true, ev.Args["flag"])
+	assert.Len(t, ev.Values, 7)
+}
+
+func TestDecoder_SharedTopic(t *testing.T) {
+	decoder := NewDecoder()
+	_, err := decoder.Register("Transfer(address indexed from, address indexed to, uint256 value)")
+	assert.NoError(t, err)
+	_, err = decoder.Register("Transfer(address indexed from, address indexed to, uint256 indexed tokenId)")
+	assert.NoError(t, err)
+
+	erc20, err := decoder.Decode(Log{
+		Topics: []any{transferTopic, addressTopic(testPoolA), addressTopic(testPoolB)},
+		Data:   "0x00000000000000000000000000000000000000000000000000000000000f4240",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1000000), erc20.Args["value"])
+
+	erc721, err := decoder.Decode(Log{
+		Topics: []any{transferTopic, addressTopic(testPoolA), addressTopic(testPoolB), fmt.Sprintf("0x%064x", 42)},
+		Data:   "0x",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(42), erc721.Args["tokenId"])
+
+	_, err = decoder.Decode(Log{Topics: []any{transferTopic}, Data: "0x"})
+	assert.Error(t, err)
+}
+
+func TestDecodeArguments_Malformed(t *testing.T) {
+	event, _ := ParseEvent(complexEvent)
+	var dataArgs []Argument
+	for _, in := range event.Inputs[1:] {
+		dataArgs = append(dataArgs, in)
+	}
+
+	full, _ := hexToBytes(complexData)
+	_, err := DecodeArguments(dataArgs, full[:len(full)-32])
+	assert.Error(t, err, "truncated tail")
+
+	// Offset of memo pointing past the data
+	corrupt := append([]byte{}, full...)
+	corrupt[63] = 0xff
+	_, err = DecodeArguments(dataArgs, corrupt)
+	assert.Error(t, err)
+
+	// uint8 overflow
+	uint8Type, _ := ParseType("uint8")
+	word := make([]byte, 32)
+	word[30] = 1
+	_, err = DecodeArguments([]Argument{{Type: uint8Type}}, word)
+	assert.Error(t, err)
+}
+
+func TestProcessorOn_HandlersInBlockOrder(t *testing.T) {
+	value := func(n int64) string { return fmt.Sprintf("0x%064x", n) }
+	transfer := func(block uint64, index uint64, amount int64) Log {
+		l := factoryTestLog(testOther, transferTopic, block, index, value(amount))
+		l.Topics = append(l.Topics, addressTopic(testPoolA), addressTopic(testPoolB))
+		return l
+	}
+	srv := newFactoryServer(20, []Log{
+		transfer(2, 0, 10),
+		transfer(2, 1, 20),
+		transfer(9, 0, 30),
+		transfer(17, 3, 40),
+		// Unrelated event goes to the logs channel
+		factoryTestLog(testOther, complexTopic, 18, 0, "0x"),
+	})
+	defer srv.Close()
+
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:          4,
+		FetcherConcurrency: 3,
+		LogsBufferSize:     10,
+	})
+
+	var mu sync.Mutex
+	var amounts []int64
+	var blocks []uint64
+	err := processor.On("1", "Transfer(address indexed from, address indexed to, uint256 value)", func(ctx context.Context, ev *DecodedEvent, dctx *DecodeContext) error {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, testPoolB, ev.Args["to"].(AddressBytes).Hex())
+		assert.Equal(t, "1", dctx.ChainId)
+		amounts = append(amounts, ev.Args["value"].(*big.Int).Int64())
+		blocks = append(blocks, dctx.BlockNumber)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Error(t, processor.On("2", "Transfer(address,address,uint256)", nil))
+	assert.Error(t, processor.On("1", "Transfer(address,address,uint257)", nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go processor.Run(ctx)
+
+	logsCh, _ := processor.Logs("1")
+	select {
+	case l := <-logsCh:
+		assert.Equal(t, complexTopic, l.Topics[0])
+	case <-ctx.Done():
+		t.Fatal("Test timeout")
+	}
+
+	mu.Lock()
+	assert.Equal(t, []int64{10, 20, 30, 40}, amounts)
+	assert.Equal(t, []uint64{2, 2, 9, 17}, blocks)
+	mu.Unlock()
+
+	assert.Error(t, processor.On("1", "Approval(address indexed owner, address indexed spender, uint256 value)", nil), "chain is running")
+	processor.Stop(ctx)
+}
+
+func TestProcessorOn_HandlerErrors(t *testing.T) {
+	transfer := factoryTestLog(testOther, transferTopic, 2, 0, fmt.Sprintf("0x%064x", 1))
+	transfer.Topics = append(transfer.Topics, addressTopic(testPoolA), addressTopic(testPoolB))
+
+	run := func(mode HandlerErrorMode, failures int) (int, error) {
+		srv := newFactoryServer(3, []Log{transfer})
+		defer srv.Close()
+
+		retry := DefaultRetryConfig()
+		retry.InitialBackoff = time.Millisecond
+		retry.EnableJitter = false
+
+		processor := NewProcessor()
+		processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+			RangeSize:     5,
+			HandlerErrors: mode,
+			RetryConfig:   &retry,
+		})
+
+		var calls int32
+		processor.On("1", "Transfer(address indexed from, address indexed to, uint256 value)", func(ctx context.Context, ev *DecodedEvent, dctx *DecodeContext) error {
+			if atomic.AddInt32(&calls, 1) <= int32(failures) {
+				return errors.New("database unavailable")
+			}
+			return nil
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		runErr := make(chan error, 1)
+		go func() { runErr <- processor.Run(ctx) }()
+
+		// Stop once the handler succeeded, a failed chain returns on its own
+		for {
+			select {
+			case err := <-runErr:
+				return int(atomic.LoadInt32(&calls)), err
+			case <-time.After(10 * time.Millisecond):
+				if atomic.LoadInt32(&calls) > int32(failures) {
+					processor.Stop(ctx)
+				}
+			}
+		}
+	}
+
+	// Stop: the first failure stops the chain
+	calls, err := run(HandlerErrorStop, 1)
+	assert.Equal(t, 1, calls)
+	var handlerErr *HandlerError
+	assert.True(t, errors.As(err, &handlerErr))
+	assert.Equal(t, "Transfer(address,address,uint256)", handlerErr.Event)
+
+	// Retry: two failures are absorbed by the 3 attempts
+	calls, err = run(HandlerErrorRetry, 2)
+	assert.Equal(t, 3, calls)
+	assert.NoError(t, err)
+
+	// Retry: attempts exhausted
+	calls, err = run(HandlerErrorRetry, 5)
+	assert.Equal(t, 3, calls)
+	assert.ErrorContains(t, err, "database unavailable")
+}
@@ -0,0 +1,402 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+// EncodeArguments ABI encodes values, e.g. call arguments.
+// Values use the DecodedEvent types, and also accept:
+//   - uintN, intN: any Go integer
+//   - address: hex string or Address
+//   - bytesN: Hash or a [N]byte array
+//   - arrays, slices and tuples: any Go slice or array
+func EncodeArguments(args []Argument, values []any) ([]byte, error) {
+	if len(args) != len(values) {
+		return nil, fmt.Errorf("expected %d values, got %d", len(args), len(values))
+	}
+	types := make([]Type, len(args))
+	for i, a := range args {
+		types[i] = a.Type
+	}
+	return encodeTuple(types, values)
+}
+
+// EncodeCall encodes calldata of f, selector included.
+func (f Function) EncodeCall(values ...any) ([]byte, error) {
+	data, err := EncodeArguments(f.Inputs, values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s arguments: %w", f.Signature(), err)
+	}
+	sel := f.Selector()
+	return append(sel[:], data...), nil
+}
+
+// DecodeOutput decodes the return data of a call to f.
+func (f Function) DecodeOutput(data []byte) ([]any, error) {
+	values, err := DecodeArguments(f.Outputs, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s output: %w", f.Signature(), err)
+	}
+	return values, nil
+}
+
+// encodeTuple encodes consecutive values, the tail of dynamic values follows the heads.
+func encodeTuple(types []Type, values []any) ([]byte, error) {
+	headSize := 0
+	for _, t := range types {
+		headSize += t.headSize()
+	}
+
+	var head, tail []byte
+	for i, t := range types {
+		enc, err := encodeValue(t, values[i])
+		if err != nil {
+			return nil, err
+		}
+		if t.IsDynamic() {
+			head = append(head, encodeSize(headSize+len(tail))...)
+			tail = append(tail, enc...)
+		} else {
+			head = append(head, enc...)
+		}
+	}
+	return append(head, tail...), nil
+}
+
+// encodeValue encodes one value, in place for static types and as its tail for dynamic ones.
+func encodeValue(t Type, v any) ([]byte, error) {
+	switch t.Kind {
+	case UintKind, IntKind:
+		n, err := toBigInt(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", t, err)
+		}
+		return encodeInt(t, n)
+
+	case BoolKind:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("invalid bool: %T", v)
+		}
+		word := make([]byte, 32)
+		if b {
+			word[31] = 1
+		}
+		return word, nil
+
+	case AddressKind:
+		var a AddressBytes
+		switch x := v.(type) {
+		case AddressBytes:
+			a = x
+		case string, Address:
+			var err error
+			if a, err = ParseAddressBytes(fmt.Sprint(x)); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("invalid address: %T", v)
+		}
+		word := make([]byte, 32)
+		copy(word[12:], a[:])
+		return word, nil
+
+	case FixedBytesKind:
+		b, ok := toBytes(v)
+		if !ok || len(b) != t.Size {
+			return nil, fmt.Errorf("invalid %s: %T of length %d", t, v, len(b))
+		}
+		return padRight(b), nil
+
+	case BytesKind, StringKind:
+		var b []byte
+		switch x := v.(type) {
+		case []byte:
+			b = x
+		case string:
+			b = []byte(x)
+		default:
+			return nil, fmt.Errorf("invalid %s: %T", t, v)
+		}
+		return append(encodeSize(len(b)), padRight(b)...), nil
+
+	case SliceKind, ArrayKind, TupleKind:
+		values, ok := toValues(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s: %T", t, v)
+		}
+		var types []Type
+		switch t.Kind {
+		case SliceKind:
+			types = repeatType(*t.Elem, len(values))
+		case ArrayKind:
+			types = repeatType(*t.Elem, t.Size)
+		case TupleKind:
+			types = make([]Type, len(t.Components))
+			for i, c := range t.Components {
+				types[i] = c.Type
+			}
+		}
+		if len(values) != len(types) {
+			return nil, fmt.Errorf("invalid %s: expected %d values, got %d", t, len(types), len(values))
+		}
+		enc, err := encodeTuple(types, values)
+		if err != nil {
+			return nil, err
+		}
+		if t.Kind == SliceKind {
+			enc = append(encodeSize(len(values)), enc...)
+		}
+		return enc, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// encodeInt encodes an integer in two's complement, checking it fits t.
+func encodeInt(t Type, n *big.Int) ([]byte, error) {
+	if t.Kind == UintKind {
+		if n.Sign() < 0 || n.BitLen() > t.Size {
+			return nil, fmt.Errorf("value %s overflows %s", n, t)
+		}
+		return n.FillBytes(make([]byte, 32)), nil
+	}
+
+	bound := new(big.Int).Lsh(big.NewInt(1), uint(t.Size-1))
+	if n.Cmp(bound) >= 0 || n.Cmp(new(big.Int).Neg(bound)) < 0 {
+		return nil, fmt.Errorf("value %s overflows %s", n, t)
+	}
+	if n.Sign() >= 0 {
+		return n.FillBytes(make([]byte, 32)), nil
+	}
+	twos := new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 256), n)
+	return twos.FillBytes(make([]byte, 32)), nil
+}
+
+func toBigInt(v any) (*big.Int, error) {
+	switch x := v.(type) {
+	case *big.Int:
+		if x == nil {
+			return nil, fmt.Errorf("nil *big.Int")
+		}
+		return x, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Int).SetUint64(rv.Uint()), nil
+	}
+	return nil, fmt.Errorf("unsupported integer %T", v)
+}
+
+// toBytes accepts []byte, Hash and byte arrays.
+func toBytes(v any) ([]byte, bool) {
+	switch x := v.(type) {
+	case []byte:
+		return x, true
+	case Hash:
+		return x[:], true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Array || rv.Type().Elem().Kind() != reflect.Uint8 {
+		return nil, false
+	}
+	b := make([]byte, rv.Len())
+	reflect.Copy(reflect.ValueOf(b), rv)
+	return b, true
+}
+
+// toValues accepts []any and any other slice or array.
+func toValues(v any) ([]any, bool) {
+	if values, ok := v.([]any); ok {
+		return values, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	values := make([]any, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values, true
+}
+
+func encodeSize(n int) []byte {
+	return new(big.Int).SetUint64(uint64(n)).FillBytes(make([]byte, 32))
+}
+
+// padRight pads b with zeros to a multiple of 32 bytes.
+func padRight(b []byte) []byte {
+	padded := make([]byte, (len(b)+31)/32*32)
+	copy(padded, b)
+	return padded
+}
+
+// EncodeTopic encodes the value of an indexed parameter as it appears in a log topic.
+// Static values are their ABI word. Strings and bytes are the keccak256 of their content,
+// arrays and tuples the keccak256 of their elements encoded in place, each padded to 32 bytes.
+func EncodeTopic(t Type, v any) (Hash, error) {
+	var h Hash
+	switch t.Kind {
+	case BytesKind, StringKind:
+		b, ok := toBytes(v)
+		if s, isString := v.(string); isString {
+			b, ok = []byte(s), true
+		}
+		if !ok {
+			return h, fmt.Errorf("invalid %s: %T", t, v)
+		}
+		copy(h[:], Keccak256(b))
+	case SliceKind, ArrayKind, TupleKind:
+		enc, err := encodeInPlace(t, v)
+		if err != nil {
+			return h, err
+		}
+		copy(h[:], Keccak256(enc))
+	default:
+		word, err := encodeValue(t, v)
+		if err != nil {
+			return h, err
+		}
+		copy(h[:], word)
+	}
+	return h, nil
+}
+
+// encodeInPlace is the encoding of indexed arrays and tuples: no offsets nor lengths, every value padded.
+func encodeInPlace(t Type, v any) ([]byte, error) {
+	switch t.Kind {
+	case BytesKind, StringKind:
+		enc, err := encodeValue(t, v)
+		if err != nil {
+			return nil, err
+		}
+		// Drop the length word
+		return enc[32:], nil
+	case SliceKind, ArrayKind, TupleKind:
+		values, ok := toValues(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s: %T", t, v)
+		}
+		var types []Type
+		switch t.Kind {
+		case SliceKind:
+			types = repeatType(*t.Elem, len(values))
+		case ArrayKind:
+			types = repeatType(*t.Elem, t.Size)
+		case TupleKind:
+			types = make([]Type, len(t.Components))
+			for i, c := range t.Components {
+				types[i] = c.Type
+			}
+		}
+		if len(values) != len(types) {
+			return nil, fmt.Errorf("invalid %s: expected %d values, got %d", t, len(types), len(values))
+		}
+		var enc []byte
+		for i, et := range types {
+			b, err := encodeInPlace(et, values[i])
+			if err != nil {
+				return nil, err
+			}
+			enc = append(enc, b...)
+		}
+		return enc, nil
+	}
+	return encodeValue(t, v)
+}
+
+// TopicFilter is a positional eth_getLogs topic filter.
+// A position matches any of its hashes, an empty position matches any topic.
+type TopicFilter [][]Hash
+
+// OneOf matches an indexed parameter against several values in Event.TopicFilter.
+type OneOf []any
+
+// TopicFilter builds the filter of e for the values of its indexed parameters, in declaration order.
+// A nil value, or a missing trailing one, matches anything. For instance Transfer logs to 0xabc:
+//
+//	transfer, _ := ParseEvent("Transfer(address indexed from, address indexed to, uint256 value)")
+//	topics, _ := transfer.TopicFilter(nil, "0xabc...")
+func (e Event) TopicFilter(values ...any) (TopicFilter, error) {
+	var indexed []Argument
+	for _, in := range e.Inputs {
+		if in.Indexed {
+			indexed = append(indexed, in)
+		}
+	}
+	if len(values) > len(indexed) {
+		return nil, fmt.Errorf("%s has %d indexed parameters, got %d values", e.Signature(), len(indexed), len(values))
+	}
+
+	var filter TopicFilter
+	if !e.Anonymous {
+		filter = append(filter, []Hash{e.Topic()})
+	}
+	for i, v := range values {
+		var choices []any
+		switch x := v.(type) {
+		case nil:
+		case OneOf:
+			choices = x
+		default:
+			choices = []any{x}
+		}
+
+		hashes := make([]Hash, len(choices))
+		for j, c := range choices {
+			h, err := EncodeTopic(indexed[i].Type, c)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s: %w", indexed[i].Name, err)
+			}
+			hashes[j] = h
+		}
+		filter = append(filter, hashes)
+	}
+
+	// Trailing wildcards are implied
+	for len(filter) > 0 && len(filter[len(filter)-1]) == 0 {
+		filter = filter[:len(filter)-1]
+	}
+	return filter, nil
+}
+
+// Matches reports whether the topics of a log satisfy the filter.
+func (f TopicFilter) Matches(l Log) bool {
+	if len(l.Topics) < len(f) {
+		return false
+	}
+	for i, choices := range f {
+		if len(choices) == 0 {
+			continue
+		}
+		s, _ := l.Topics[i].(string)
+		topic, err := ParseHash(s)
+		if err != nil || !containsHash(choices, topic) {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalJSON writes the eth_getLogs form: null for any topic, a hash, or a list of hashes.
+func (f TopicFilter) MarshalJSON() ([]byte, error) {
+	positions := make([]any, len(f))
+	for i, choices := range f {
+		switch len(choices) {
+		case 0:
+			positions[i] = nil
+		case 1:
+			positions[i] = choices[0].Hex()
+		default:
+			positions[i] = hexStrings(choices)
+		}
+	}
+	return json.Marshal(positions)
+}
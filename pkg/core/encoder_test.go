@@ -0,0 +1,123 @@
+package core
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeArguments_RoundTrip(t *testing.T) {
+	event, err := ParseEvent(complexEvent)
+	assert.NoError(t, err)
+	var args []Argument
+	for _, in := range event.Inputs {
+		if !in.Indexed {
+			args = append(args, in)
+		}
+	}
+
+	data, _ := hexToBytes(complexData)
+	values, err := DecodeArguments(args, data)
+	assert.NoError(t, err)
+	encoded, err := EncodeArguments(args, values)
+	assert.NoError(t, err)
+	assert.Equal(t, complexData, "0x"+hex.EncodeToString(encoded))
+
+	fn, _ := ParseFunction("mixed(int8 a, string[] b, (bytes x, uint16[2] y) c, bytes3 d)")
+	input, _ := hexToBytes(mixedInput)
+	call, err := fn.DecodeCall(input)
+	assert.NoError(t, err)
+	encoded, err = fn.EncodeCall(call.Values...)
+	assert.NoError(t, err)
+	assert.Equal(t, mixedInput, "0x"+hex.EncodeToString(encoded))
+}
+
+func TestEncodeArguments_GoValues(t *testing.T) {
+	fn, _ := ParseFunction("f(uint8 a, int32 b, address c, bytes32 d, uint256[] e, bytes2[2] f)")
+	addr, _ := ParseAddressBytes(testToken)
+	input, err := fn.EncodeCall(
+		uint8(7), int32(-1), Address(testToken), Hash{1}, []uint64{1, 2}, [2][2]byte{{1, 2}, {3, 4}},
+	)
+	assert.NoError(t, err)
+
+	call, err := fn.DecodeCall(input)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(7), call.Values[0])
+	assert.Equal(t, big.NewInt(-1), call.Values[1])
+	assert.Equal(t, addr, call.Values[2])
+	assert.Equal(t, Hash{1}.Bytes(), call.Values[3])
+	assert.Equal(t, []any{big.NewInt(1), big.NewInt(2)}, call.Values[4])
+	assert.Equal(t, []any{[]byte{1, 2}, []byte{3, 4}}, call.Values[5])
+
+	_, err = fn.EncodeCall(uint16(256), 0, testToken, Hash{}, []uint64{}, [2][2]byte{})
+	assert.Error(t, err)
+	_, err = fn.EncodeCall(0, 0, testToken, []byte{1}, []uint64{}, [2][2]byte{})
+	assert.Error(t, err)
+	_, err = fn.EncodeCall(0, 0, "0x1234", Hash{}, []uint64{}, [2][2]byte{})
+	assert.Error(t, err)
+}
+
+func TestEncodeTopic(t *testing.T) {
+	// Vectors from go-ethereum abi.MakeTopics
+	cases := []struct {
+		typ      string
+		value    any
+		expected string
+	}{
+		{"address", testSender, "0x00000000000000000000000028c6c06298d514db089934071355e5743bf21d60"},
+		{"int256", big.NewInt(-2), "0xfffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffe"},
+		{"string", "hello", "0x1c8aff950685c2ed4bc3174f3472287b56d9517b9c948127319a09a7a36deac8"},
+		{"bool", true, "0x0000000000000000000000000000000000000000000000000000000000000001"},
+		{"bytes32", [32]byte{1, 2}, "0x0102000000000000000000000000000000000000000000000000000000000000"},
+		{"bytes", []byte{0xde, 0xad}, "0x3905d344717efd562447a4960eea941c1244adc31f53525d0ec1397ff6951c9c"},
+		{"uint64", uint64(1000), "0x00000000000000000000000000000000000000000000000000000000000003e8"},
+	}
+	for _, c := range cases {
+		typ, err := ParseType(c.typ)
+		assert.NoError(t, err)
+		topic, err := EncodeTopic(typ, c.value)
+		assert.NoError(t, err, c.typ)
+		assert.Equal(t, c.expected, topic.Hex(), c.typ)
+	}
+
+	// Arrays are hashed in place, every element padded
+	typ, _ := ParseType("uint8[2]")
+	topic, err := EncodeTopic(typ, []any{1, 2})
+	assert.NoError(t, err)
+	packed, _ := hexToBytes("0x" + "00000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002")
+	assert.Equal(t, Keccak256(packed), topic.Bytes())
+}
+
+func TestEvent_TopicFilter(t *testing.T) {
+	transfer, _ := ParseEvent("Transfer(address indexed from, address indexed to, uint256 value)")
+
+	filter, err := transfer.TopicFilter(nil, testPoolA)
+	assert.NoError(t, err)
+	assert.Len(t, filter, 3)
+	assert.Empty(t, filter[1])
+
+	b, err := json.Marshal(Filter{FromBlock: "0x1", ToBlock: "0x2", TopicSets: filter})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"fromBlock":"0x1","toBlock":"0x2","topics":["`+transferTopic+`",null,"`+addressTopic(testPoolA)+`"]}`, string(b))
+
+	assert.True(t, filter.Matches(Log{Topics: []any{transferTopic, addressTopic(testPoolB), addressTopic(testPoolA)}}))
+	assert.False(t, filter.Matches(Log{Topics: []any{transferTopic, addressTopic(testPoolA), addressTopic(testPoolB)}}))
+
+	filter, err = transfer.TopicFilter(OneOf{testPoolA, testPoolB})
+	assert.NoError(t, err)
+	assert.Len(t, filter, 2)
+	b, _ = json.Marshal(filter)
+	assert.JSONEq(t, `["`+transferTopic+`",["`+addressTopic(testPoolA)+`","`+addressTopic(testPoolB)+`"]]`, string(b))
+
+	_, err = transfer.TopicFilter(nil, nil, nil)
+	assert.Error(t, err)
+	_, err = transfer.TopicFilter("0x1234")
+	assert.Error(t, err)
+
+	// Plain filters are unchanged
+	b, _ = json.Marshal(Filter{FromBlock: "0x1", ToBlock: "0x2", Topics: []string{transferTopic}})
+	assert.JSONEq(t, `{"fromBlock":"0x1","toBlock":"0x2","topics":["`+transferTopic+`"]}`, string(b))
+}
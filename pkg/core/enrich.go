@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// enrich reports whether logs of this chain get a DecodeContext attached, at least block level once handlers are registered.
+func (c *chainState) enrich() bool {
+	return c.handlersEnrich || (c.opts.Enrichment != "" && c.opts.Enrichment != EnrichmentNone)
+}
+
+// enrichLogs attaches a DecodeContext to every log according to Options.Enrichment.
+// Headers and receipts the worker already fetched are reused, only the missing ones are requested.
+// Malformed logs are delivered without a context.
+func (p *Processor) enrichLogs(ctx context.Context, logs []fetchedLog, chain *chainState, headers map[uint64]Block, receipts map[uint64][]Receipt) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	contexts := make([]*DecodeContext, len(logs))
+	blockSet := make(map[uint64]struct{})
+	for i, l := range logs {
+		if l.invalid != nil {
+			continue
+		}
+		dctx := newDecodeContext(chain.chainInfo.ChainId, l.typed)
+		dctx.state = chain.state
+		contexts[i] = dctx
+		blockSet[dctx.BlockNumber] = struct{}{}
+	}
+
+	var missing []uint64
+	for blockNum := range blockSet {
+		if _, ok := headers[blockNum]; !ok {
+			missing = append(missing, blockNum)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+		fetched, err := p.getHeadersByNumber(ctx, missing, chain)
+		if err != nil {
+			return err
+		}
+		for i, block := range fetched {
+			headers[missing[i]] = block
+		}
+	}
+
+	byTx := make(map[string]Receipt)
+	if chain.opts.Enrichment == EnrichmentReceipt {
+		for blockNum := range blockSet {
+			blockReceipts, ok := receipts[blockNum]
+			if !ok {
+				var err error
+				blockReceipts, err = chain.chainInfo.RPC.GetBlockReceipts(ctx, Uint64ToHexQty(blockNum))
+				if err != nil {
+					return fmt.Errorf("failed to get receipts for block %d: %w", blockNum, err)
+				}
+			}
+			for _, r := range blockReceipts {
+				byTx[strings.ToLower(r.TransactionHash)] = r
+			}
+		}
+	}
+
+	for i := range logs {
+		dctx := contexts[i]
+		if dctx == nil {
+			continue
+		}
+
+		ts, err := HexQtyToUint64(headers[dctx.BlockNumber].Timestamp)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp for block %d: %w", dctx.BlockNumber, err)
+		}
+		dctx.BlockTimestamp = time.Unix(int64(ts), 0).UTC()
+
+		if chain.opts.Enrichment == EnrichmentReceipt {
+			r, ok := byTx[strings.ToLower(dctx.TransactionHash)]
+			if !ok {
+				return fmt.Errorf("no receipt for transaction %s in block %d", dctx.TransactionHash, dctx.BlockNumber)
+			}
+			if err := applyReceipt(dctx, r); err != nil {
+				return err
+			}
+		}
+
+		logs[i].Context = dctx
+		logs[i].typed.Context = dctx
+	}
+
+	return nil
+}
+
+// newDecodeContext holds the position fields of a log.
+func newDecodeContext(chainId string, tl TypedLog) *DecodeContext {
+	return &DecodeContext{
+		ChainId:          chainId,
+		BlockNumber:      tl.BlockNumber,
+		BlockHash:        tl.BlockHash.Hex(),
+		TransactionHash:  tl.TransactionHash.Hex(),
+		TransactionIndex: tl.TransactionIndex,
+		LogIndex:         tl.LogIndex,
+	}
+}
+
+func applyReceipt(dctx *DecodeContext, r Receipt) error {
+	tr, err := r.Typed()
+	if err != nil {
+		return fmt.Errorf("invalid receipt %s: %w", r.TransactionHash, err)
+	}
+
+	dctx.From = tr.From.Hex()
+	if tr.To != nil {
+		dctx.To = tr.To.Hex()
+	}
+	// Pre-Byzantium receipts carry a state root instead of status, they only exist for successful txs with logs
+	dctx.Success = tr.Root != nil || tr.Status == 1
+	dctx.GasUsed = tr.GasUsed
+	dctx.EffectiveGasPrice = tr.EffectiveGasPrice
+
+	return nil
+}
@@ -13,13 +13,48 @@ type HTTPError struct {
 type RPCError struct  {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
+	// Data is the error data of the node, the revert data of a failed eth_call
 	Data    any    `json:"data,omitempty"`
 }
 
+// ChainError is a chain failure published on Processor.Errors.
+type ChainError struct {
+	ChainId string `json:"chainId"`
+	Err error `json:"-"`
+	// Restarts the chain went through before this failure
+	Restarts int `json:"restarts"`
+	// WillRestart tells whether the RestartPolicy brings the chain back
+	WillRestart bool `json:"willRestart"`
+}
+
+// HandlerError is returned when an On, OnBlock or OnInterval handler fails.
+type HandlerError struct {
+	// Event signature, empty for block handlers
+	Event string `json:"event"`
+	BlockNumber uint64 `json:"blockNumber"`
+	TransactionHash string `json:"transactionHash,omitempty"`
+	LogIndex string `json:"logIndex,omitempty"`
+	Err error `json:"-"`
+	// retryable is set under HandlerErrorRetry
+	retryable bool
+}
+
 type ReorgError struct {
 
 }
 
+// SlowConsumerError ends a SlowConsumerDisconnect subscription whose buffer was full.
+type SlowConsumerError struct {
+	ChainId string `json:"chainId"`
+	BufferSize int `json:"bufferSize"`
+}
+
+// IntegrityError is returned when provider data doesn't match the block header commitments.
+type IntegrityError struct {
+	BlockNumber uint64 `json:"blockNumber"`
+	Reason string `json:"reason"`
+}
+
 // We need to implement the Error function to follow the error interface
 func (e *HTTPError) Error() string {
     return fmt.Sprintf("http error %d: %s", e.StatusCode, e.Message)
@@ -29,8 +64,41 @@ func (e *RPCError) Error() string {
     return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
 }
 
+func (e *ChainError) Error() string {
+    return fmt.Sprintf("chain %s failed: %v", e.ChainId, e.Err)
+}
+
+func (e *ChainError) Unwrap() error {
+    return e.Err
+}
+
+func (e *HandlerError) Error() string {
+    if e.Event == "" {
+        return fmt.Sprintf("block handler failed at block %d: %v", e.BlockNumber, e.Err)
+    }
+    return fmt.Sprintf("handler for %s failed on log %s/%s: %v", e.Event, e.TransactionHash, e.LogIndex, e.Err)
+}
+
+func (e *HandlerError) Unwrap() error {
+    return e.Err
+}
+
+func (e *SlowConsumerError) Error() string {
+    return fmt.Sprintf("subscriber of chain %s disconnected: buffer of %d logs is full", e.ChainId, e.BufferSize)
+}
+
+func (e *IntegrityError) Error() string {
+    return fmt.Sprintf("integrity check failed at block %d: %s", e.BlockNumber, e.Reason)
+}
+
 // Helper function to check if the error is retriable
 func isRetryableError(err error) bool {
+	// Handler errors are retried as configured, whatever they wrap
+	var handlerErr *HandlerError
+	if errors.As(err, &handlerErr) {
+		return handlerErr.retryable
+	}
+
 	// Try to extract HTTPError
 	var httpErr *HTTPError
 	if errors.As(err, &httpErr) {
@@ -54,5 +122,11 @@ func isRetryableError(err error) bool {
 		}
 	}
 
+	// Providers behind load balancers may serve a lagging node, give them another chance
+	var integrityErr *IntegrityError
+	if errors.As(err, &integrityErr) {
+		return true
+	}
+
 	return false
 }
\ No newline at end of file
@@ -0,0 +1,332 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// Factory discovers child contracts from a creation event, e.g. a Uniswap PoolCreated.
+// Children are indexed from their creation block onward, factory events are emitted too.
+type Factory struct {
+	// Address of the factory contract
+	Address string
+	// Event is the creation event, a signature like "PoolCreated(address,address,uint24,int24,address)" or its topic hash
+	Event string
+	// ChildTopics are the events indexed on the children, signatures or hashes
+	// Leave empty to use Options.Topics
+	ChildTopics []string
+	// Children extracts the addresses created by one factory event
+	Children func(Log) ([]string, error)
+}
+
+// ChildContract is a contract discovered through a Factory.
+type ChildContract struct {
+	Address string `json:"address"`
+	Factory string `json:"factory"`
+	// Block of the creation event
+	BlockNumber uint64 `json:"blockNumber"`
+}
+
+// factoryState is the parsed form of a Factory.
+type factoryState struct {
+	Factory
+	address     AddressBytes
+	event       Hash
+	childTopics []Hash
+}
+
+type childState struct {
+	factory     AddressBytes
+	blockNumber uint64
+}
+
+// factoryWindow is what a worker fetched for the factories of one window.
+type factoryWindow struct {
+	// events are the creation events of every factory
+	events []fetchedLog
+	// children are the logs of the children known when the window was fetched
+	children []fetchedLog
+	// known is the child set the worker used, the arbiter backfills the others
+	known map[AddressBytes]struct{}
+}
+
+// newFactoryStates validates the configured factories.
+func newFactoryStates(factories []Factory, topics []Hash) ([]factoryState, error) {
+	states := make([]factoryState, len(factories))
+	for i, f := range factories {
+		if f.Children == nil {
+			return nil, fmt.Errorf("factory %s has no Children function", f.Address)
+		}
+		addr, err := ParseAddressBytes(f.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid factory address %q: %w", f.Address, err)
+		}
+		event, err := ParseHash(ConvertToTopics([]string{f.Event})[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid factory event %q: %w", f.Event, err)
+		}
+
+		childTopics := topics
+		if len(f.ChildTopics) > 0 {
+			childTopics = make([]Hash, len(f.ChildTopics))
+			for j, topic := range ConvertToTopics(f.ChildTopics) {
+				if childTopics[j], err = ParseHash(topic); err != nil {
+					return nil, fmt.Errorf("invalid child topic %q: %w", f.ChildTopics[j], err)
+				}
+			}
+		}
+
+		states[i] = factoryState{Factory: f, address: addr, event: event, childTopics: childTopics}
+	}
+	return states, nil
+}
+
+// staticSources reports whether Options.Addresses and Topics are fetched.
+// With factories, an empty address list means no static contract instead of any contract.
+func (c *chainState) staticSources() bool {
+	return len(c.factories) == 0 || len(c.addresses) > 0
+}
+
+// Children returns the contracts discovered by the factories of a chain.
+func (p *Processor) Children(chainId string) ([]ChildContract, error) {
+	chain, err := p.chain(chainId)
+	if err != nil {
+		return nil, err
+	}
+	return chain.childContracts(), nil
+}
+
+// childContracts lists the known children ordered by creation block.
+func (c *chainState) childContracts() []ChildContract {
+	c.childrenMu.RLock()
+	defer c.childrenMu.RUnlock()
+
+	children := make([]ChildContract, 0, len(c.children))
+	for addr, child := range c.children {
+		children = append(children, ChildContract{
+			Address:     addr.Hex(),
+			Factory:     child.factory.Hex(),
+			BlockNumber: child.blockNumber,
+		})
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].BlockNumber != children[j].BlockNumber {
+			return children[i].BlockNumber < children[j].BlockNumber
+		}
+		return children[i].Address < children[j].Address
+	})
+	return children
+}
+
+// restoreChildren replaces the child set with the one of a stored cursor.
+func (c *chainState) restoreChildren(children []ChildContract) error {
+	c.childrenMu.Lock()
+	defer c.childrenMu.Unlock()
+
+	c.children = make(map[AddressBytes]childState, len(children))
+	for _, child := range children {
+		addr, err := ParseAddressBytes(child.Address)
+		if err != nil {
+			return fmt.Errorf("invalid stored child %q: %w", child.Address, err)
+		}
+		factory, err := ParseAddressBytes(child.Factory)
+		if err != nil {
+			return fmt.Errorf("invalid stored factory %q: %w", child.Factory, err)
+		}
+		c.children[addr] = childState{factory: factory, blockNumber: child.BlockNumber}
+	}
+	return nil
+}
+
+// dropChildrenAfter forgets children created on an orphaned branch.
+func (c *chainState) dropChildrenAfter(ancestor uint64) {
+	c.childrenMu.Lock()
+	defer c.childrenMu.Unlock()
+
+	for addr, child := range c.children {
+		if child.blockNumber > ancestor {
+			delete(c.children, addr)
+		}
+	}
+}
+
+// childrenOf returns the known children of a factory.
+func (c *chainState) childrenOf(factory AddressBytes) map[AddressBytes]childState {
+	c.childrenMu.RLock()
+	defer c.childrenMu.RUnlock()
+
+	children := make(map[AddressBytes]childState)
+	for addr, child := range c.children {
+		if child.factory == factory {
+			children[addr] = child
+		}
+	}
+	return children
+}
+
+// fetchFactoryWindow fetches the factory events of a window and the logs of the children known so far.
+func (p *Processor) fetchFactoryWindow(ctx context.Context, from uint64, to uint64, chain *chainState) (*factoryWindow, error) {
+	fw := &factoryWindow{known: make(map[AddressBytes]struct{})}
+
+	for _, f := range chain.factories {
+		events, err := p.getLogsFor(ctx, from, to, chain, []AddressBytes{f.address}, []Hash{f.event})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get events of factory %s: %w", f.address.Hex(), err)
+		}
+		fw.events = append(fw.events, events...)
+
+		children := chain.childrenOf(f.address)
+		if len(children) == 0 {
+			continue
+		}
+		addrs := make([]AddressBytes, 0, len(children))
+		for addr := range children {
+			addrs = append(addrs, addr)
+			fw.known[addr] = struct{}{}
+		}
+
+		logs, err := p.getLogsFor(ctx, from, to, chain, addrs, f.childTopics)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get logs of %d children of factory %s: %w", len(addrs), f.address.Hex(), err)
+		}
+		fw.children = append(fw.children, logs...)
+	}
+
+	return fw, nil
+}
+
+// resolveFactoryWindow registers the children created in a window and returns every log of the window in order.
+// Called by the arbiter in block order, children the worker didn't know yet are backfilled from their creation block.
+func (p *Processor) resolveFactoryWindow(ctx context.Context, from uint64, to uint64, chain *chainState, logs []fetchedLog, fw *factoryWindow) ([]fetchedLog, error) {
+	sortLogs(fw.events)
+
+	for _, f := range chain.factories {
+		for _, event := range fw.events {
+			if event.typed.Address != f.address || !hasTopic0(event.typed, f.event) {
+				continue
+			}
+			addrs, err := f.Children(event.Log)
+			if err != nil {
+				return nil, fmt.Errorf("failed to extract children from factory %s event in tx %s: %w", f.address.Hex(), event.TransactionHash, err)
+			}
+			if err := chain.addChildren(f.address, addrs, event.typed.BlockNumber); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	extra := append(append([]fetchedLog{}, fw.events...), fw.children...)
+
+	// Children discovered after the worker fetched the window
+	for _, f := range chain.factories {
+		var missing []AddressBytes
+		firstBlock := to
+		for addr, child := range chain.childrenOf(f.address) {
+			if _, ok := fw.known[addr]; ok {
+				continue
+			}
+			missing = append(missing, addr)
+			if child.blockNumber < firstBlock {
+				firstBlock = child.blockNumber
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		if firstBlock < from {
+			firstBlock = from
+		}
+
+		log.Printf("Backfilling %d children of factory %s from block %d to block %d", len(missing), f.address.Hex(), firstBlock, to)
+		backfill, err := p.getLogsFor(ctx, firstBlock, to, chain, missing, f.childTopics)
+		if err != nil {
+			return nil, fmt.Errorf("failed to backfill children of factory %s: %w", f.address.Hex(), err)
+		}
+		extra = append(extra, backfill...)
+	}
+
+	if chain.enrich() && len(extra) > 0 {
+		if err := p.enrichLogs(ctx, extra, chain, make(map[uint64]Block), make(map[uint64][]Receipt)); err != nil {
+			return nil, err
+		}
+	}
+
+	merged := dedupLogs(append(append([]fetchedLog{}, logs...), extra...))
+	sortLogs(merged)
+	return merged, nil
+}
+
+// addChildren registers new children, a child keeps its earliest creation block.
+func (c *chainState) addChildren(factory AddressBytes, addrs []string, blockNum uint64) error {
+	c.childrenMu.Lock()
+	defer c.childrenMu.Unlock()
+
+	for _, a := range addrs {
+		addr, err := ParseAddressBytes(a)
+		if err != nil {
+			return fmt.Errorf("invalid child address %q from factory %s: %w", a, factory.Hex(), err)
+		}
+		if existing, ok := c.children[addr]; ok && existing.blockNumber <= blockNum {
+			continue
+		}
+		c.children[addr] = childState{factory: factory, blockNumber: blockNum}
+	}
+	return nil
+}
+
+// getLogsFor fetches the logs of the given contracts and topic0s with eth_getLogs.
+// Several topic0s are sent as alternatives of the first position, like logsFilter.
+func (p *Processor) getLogsFor(ctx context.Context, from uint64, to uint64, chain *chainState, addrs []AddressBytes, topics []Hash) ([]fetchedLog, error) {
+	filter := Filter{
+		FromBlock: Uint64ToHexQty(from),
+		ToBlock:   Uint64ToHexQty(to),
+		Address:   hexStrings(addrs),
+	}
+	if len(topics) > 1 {
+		filter.TopicSets = TopicFilter{topics}
+	} else {
+		filter.Topics = hexStrings(topics)
+	}
+
+	raw, err := chain.chainInfo.RPC.GetLogs(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return parseLogs(raw)
+}
+
+func hasTopic0(l TypedLog, topic Hash) bool {
+	return len(l.Topics) > 0 && l.Topics[0] == topic
+}
+
+// dedupLogs drops logs fetched twice, e.g. a static address that is also a child.
+func dedupLogs(logs []fetchedLog) []fetchedLog {
+	type position struct {
+		hash   Hash
+		number uint64
+		index  uint64
+	}
+	seen := make(map[position]struct{}, len(logs))
+	out := logs[:0]
+	for _, l := range logs {
+		key := position{l.typed.BlockHash, l.typed.BlockNumber, l.typed.LogIndex}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, l)
+	}
+	return out
+}
+
+// sortLogs orders logs by block number and log index.
+func sortLogs(logs []fetchedLog) {
+	sort.SliceStable(logs, func(i, j int) bool {
+		if logs[i].typed.BlockNumber != logs[j].typed.BlockNumber {
+			return logs[i].typed.BlockNumber < logs[j].typed.BlockNumber
+		}
+		return logs[i].typed.LogIndex < logs[j].typed.LogIndex
+	})
+}
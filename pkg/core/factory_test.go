@@ -0,0 +1,308 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testFactory = "0x1f98431c8ad98523631ae4a59f267346ea31f984"
+	testPoolA   = "0x8ad599c3a0ff1de082011efddc58f1908eb6e6d8"
+	testPoolB   = "0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640"
+	testOther   = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+)
+
+func factoryTestLog(address string, topic string, block uint64, index uint64, data string) Log {
+	return Log{
+		Address:         address,
+		Topics:          []any{topic},
+		Data:            data,
+		BlockNumber:     Uint64ToHexQty(block),
+		BlockHash:       fmt.Sprintf("0x%064x", block),
+		TransactionHash: fmt.Sprintf("0x%062x%02x", block, index),
+		LogIndex:        Uint64ToHexQty(index),
+	}
+}
+
+// matchesPositionalTopics applies an eth_getLogs topic filter the way nodes do:
+// positions are ANDed, a position is null, a hash, or a list of alternative hashes.
+func matchesPositionalTopics(positions []json.RawMessage, l Log) bool {
+	for i, raw := range positions {
+		var choices []string
+		var one string
+		if err := json.Unmarshal(raw, &one); err == nil {
+			choices = []string{one}
+		} else {
+			json.Unmarshal(raw, &choices)
+		}
+		if len(choices) == 0 {
+			continue
+		}
+		if i >= len(l.Topics) {
+			return false
+		}
+		topic, _ := l.Topics[i].(string)
+		if !containsFold(choices, topic) {
+			return false
+		}
+	}
+	return true
+}
+
+func newFactoryServer(head uint64, logs []Log) *httptest.Server {
+	type rpcReq struct {
+		ID     uint              `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+
+	result := func(req rpcReq) any {
+		switch req.Method {
+		case "eth_blockNumber":
+			return Uint64ToHexQty(head)
+		case "eth_getBlockByNumber":
+			var number string
+			json.Unmarshal(req.Params[0], &number)
+			blockNum, _ := HexQtyToUint64(number)
+			return map[string]any{
+				"number":     number,
+				"hash":       fmt.Sprintf("0x%064x", blockNum),
+				"parentHash": fmt.Sprintf("0x%064x", blockNum-1),
+				"timestamp":  Uint64ToHexQty(1700000000 + blockNum*12),
+			}
+		case "eth_getLogs":
+			var filter struct {
+				FromBlock string            `json:"fromBlock"`
+				ToBlock   string            `json:"toBlock"`
+				Address   []string          `json:"address"`
+				Topics    []json.RawMessage `json:"topics"`
+			}
+			json.Unmarshal(req.Params[0], &filter)
+			from, _ := HexQtyToUint64(filter.FromBlock)
+			to, _ := HexQtyToUint64(filter.ToBlock)
+
+			matched := []Log{}
+			for _, l := range logs {
+				block, _ := HexQtyToUint64(l.BlockNumber)
+				if block < from || block > to {
+					continue
+				}
+				if len(filter.Address) > 0 && !containsFold(filter.Address, l.Address) {
+					continue
+				}
+				if !matchesPositionalTopics(filter.Topics, l) {
+					continue
+				}
+				matched = append(matched, l)
+			}
+			// Let workers run ahead of the arbiter
+			time.Sleep(5 * time.Millisecond)
+			return matched
+		case "eth_call":
+			// Returns the number of the pinned block as a uint256
+			var ref struct {
+				BlockHash string `json:"blockHash"`
+			}
+			json.Unmarshal(req.Params[1], &ref)
+			return ref.BlockHash
+		}
+		return nil
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body, _ := io.ReadAll(r.Body)
+
+		// Header batches
+		if len(body) > 0 && body[0] == '[' {
+			var reqs []rpcReq
+			json.Unmarshal(body, &reqs)
+			resps := make([]map[string]any, len(reqs))
+			for i, req := range reqs {
+				resps[i] = map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result(req)}
+			}
+			_ = json.NewEncoder(w).Encode(resps)
+			return
+		}
+
+		var req rpcReq
+		json.Unmarshal(body, &req)
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": result(req)})
+	}))
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFactory_DiscoversAndBackfillsChildren(t *testing.T) {
+	created := ConvertToTopics([]string{"PoolCreated(address,address,uint24,int24,address)"})[0]
+	swap := ConvertToTopics([]string{"Swap(address,address,int256,int256,uint160,uint128,int24)"})[0]
+	poolData := func(pool string) string {
+		return "0x" + strings.Repeat("0", 24) + strings.TrimPrefix(pool, "0x")
+	}
+
+	srv := newFactoryServer(30, []Log{
+		factoryTestLog(testFactory, created, 3, 0, poolData(testPoolA)),
+		factoryTestLog(testPoolA, swap, 5, 0, "0x"),
+		factoryTestLog(testPoolA, swap, 8, 0, "0x"),
+		factoryTestLog(testFactory, created, 12, 0, poolData(testPoolB)),
+		factoryTestLog(testPoolB, swap, 12, 1, "0x"),
+		factoryTestLog(testPoolA, swap, 15, 0, "0x"),
+		factoryTestLog(testPoolB, swap, 25, 0, "0x"),
+		// Not a child of the factory
+		factoryTestLog(testOther, swap, 6, 0, "0x"),
+	})
+	defer srv.Close()
+
+	store := &memoryCursorStore{}
+	processor := NewProcessor()
+	err := processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:          5,
+		FetcherConcurrency: 4,
+		LogsBufferSize:     10,
+		CursorStore:        store,
+		Factories: []Factory{{
+			Address:     testFactory,
+			Event:       "PoolCreated(address,address,uint24,int24,address)",
+			ChildTopics: []string{"Swap(address,address,int256,int256,uint160,uint128,int24)"},
+			Children: func(l Log) ([]string, error) {
+				data := strings.TrimPrefix(l.Data, "0x")
+				return []string{"0x" + data[len(data)-40:]}, nil
+			},
+		}},
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go processor.Run(ctx)
+
+	type position struct {
+		address string
+		block   string
+	}
+	expected := []position{
+		{testFactory, "0x3"},
+		{testPoolA, "0x5"},
+		{testPoolA, "0x8"},
+		{testFactory, "0xc"},
+		{testPoolB, "0xc"},
+		{testPoolA, "0xf"},
+		{testPoolB, "0x19"},
+	}
+
+	logsCh, _ := processor.Logs("1")
+	var got []position
+	for len(got) < len(expected) {
+		select {
+		case l := <-logsCh:
+			got = append(got, position{l.Address, l.BlockNumber})
+		case <-ctx.Done():
+			t.Fatalf("Test timeout, got %v", got)
+		}
+	}
+	assert.Equal(t, expected, got)
+
+	children, err := processor.Children("1")
+	assert.NoError(t, err)
+	assert.Equal(t, []ChildContract{
+		{Address: testPoolA, Factory: testFactory, BlockNumber: 3},
+		{Address: testPoolB, Factory: testFactory, BlockNumber: 12},
+	}, children)
+
+	assert.NoError(t, processor.Stop(ctx))
+	saved, _ := store.LoadCursor(ctx, "1")
+	assert.Equal(t, children, saved.Children)
+}
+
+func TestFactory_GetLogsForSeveralTopics(t *testing.T) {
+	swap := ConvertToTopics([]string{"Swap(address,address,int256,int256,uint160,uint128,int24)"})[0]
+	mint := ConvertToTopics([]string{"Mint(address,address,int24,int24,uint128,uint256,uint256)"})[0]
+	burn := ConvertToTopics([]string{"Burn(address,int24,int24,uint128,uint256,uint256)"})[0]
+	logs := newFactoryServer(30, []Log{
+		factoryTestLog(testPoolA, swap, 5, 0, "0x"),
+		factoryTestLog(testPoolA, burn, 6, 0, "0x"),
+		factoryTestLog(testPoolA, mint, 7, 0, "0x"),
+	})
+	defer logs.Close()
+	var topics []json.RawMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			Params []struct {
+				Topics []json.RawMessage `json:"topics"`
+			} `json:"params"`
+		}
+		json.Unmarshal(body, &req)
+		topics = req.Params[0].Topics
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+		logs.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	pool, _ := ParseAddressBytes(testPoolA)
+	swapHash, _ := ParseHash(swap)
+	mintHash, _ := ParseHash(mint)
+	chain := &chainState{chainInfo: ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}}
+	got, err := NewProcessor().getLogsFor(context.Background(), 1, 10, chain, []AddressBytes{pool}, []Hash{swapHash, mintHash})
+	assert.NoError(t, err)
+
+	// The node matches the topic0 alternatives, Burn is never fetched
+	if assert.Len(t, topics, 1) {
+		var alternatives []string
+		assert.NoError(t, json.Unmarshal(topics[0], &alternatives))
+		assert.Equal(t, []string{swap, mint}, alternatives)
+	}
+	var blocks []uint64
+	for _, l := range got {
+		blocks = append(blocks, l.typed.BlockNumber)
+	}
+	assert.Equal(t, []uint64{5, 7}, blocks)
+}
+
+func TestFactory_DropChildrenOnReorg(t *testing.T) {
+	chain := &chainState{children: make(map[AddressBytes]childState)}
+	factory, _ := ParseAddressBytes(testFactory)
+
+	assert.NoError(t, chain.addChildren(factory, []string{testPoolA}, 3))
+	assert.NoError(t, chain.addChildren(factory, []string{testPoolB}, 12))
+	assert.Error(t, chain.addChildren(factory, []string{"0x1234"}, 13))
+
+	chain.dropChildrenAfter(10)
+	assert.Equal(t, []ChildContract{{Address: testPoolA, Factory: testFactory, BlockNumber: 3}}, chain.childContracts())
+}
+
+func TestFactory_InvalidConfig(t *testing.T) {
+	processor := NewProcessor()
+
+	err := processor.AddChain(ChainInfo{ChainId: "1"}, &Options{
+		RangeSize: 5,
+		Factories: []Factory{{Address: testFactory, Event: "PoolCreated(address,address,uint24,int24,address)"}},
+	})
+	assert.Error(t, err)
+
+	err = processor.AddChain(ChainInfo{ChainId: "1"}, &Options{
+		RangeSize: 5,
+		Factories: []Factory{{
+			Address:  "0xabc",
+			Event:    "PoolCreated(address,address,uint24,int24,address)",
+			Children: func(Log) ([]string, error) { return nil, nil },
+		}},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,308 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// EventHandler processes one decoded event. Handlers of a chain are called in block order from its arbiter.
+type EventHandler func(ctx context.Context, ev *DecodedEvent, dctx *DecodeContext) error
+
+type HandlerErrorMode string
+
+const (
+	HandlerErrorStop  HandlerErrorMode = "stop"  // A failing handler stops the chain, see RestartPolicy
+	HandlerErrorRetry HandlerErrorMode = "retry" // Retry the handler with RetryConfig, then stop the chain
+)
+
+type eventHandler struct {
+	event Event
+	fn    EventHandler
+}
+
+// On registers a handler for an event of a chain, e.g.
+//
+//	p.On("1", "Transfer(address indexed from, address indexed to, uint256 value)", func(ctx context.Context, ev *DecodedEvent, dctx *DecodeContext) error {
+//	    value := ev.Args["value"].(*big.Int)
+//	    ...
+//	})
+//
+// The event topic is added to the chain topics, and block enrichment is turned on so dctx carries the block timestamp.
+// Logs consumed by a handler are not emitted on the logs channel. Handlers must be registered before the chain runs.
+func (p *Processor) On(chainId string, signature string, handler EventHandler) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	chain, exists := p.chains[chainId]
+	if !exists {
+		return fmt.Errorf("chain %s not found", chainId)
+	}
+	if chain.running() {
+		return fmt.Errorf("cannot register handler while chain %s is running", chainId)
+	}
+
+	if chain.decoder == nil {
+		chain.decoder = NewDecoder()
+		chain.handlers = make(map[EventKey][]eventHandler)
+	}
+	event, err := chain.decoder.Register(signature)
+	if err != nil {
+		return err
+	}
+	key := event.Key()
+	chain.handlers[key] = append(chain.handlers[key], eventHandler{event: event, fn: handler})
+
+	// An empty topic list already matches every event
+	topic := event.Topic()
+	if len(chain.topics) > 0 && !containsHash(chain.topics, topic) {
+		chain.topics = append(chain.topics, topic)
+	}
+	chain.handlersEnrich = true
+
+	return nil
+}
+
+// running reports whether a goroutine runs the chain. Must be called with p.mu held.
+func (c *chainState) running() bool {
+	if c.done == nil {
+		return false
+	}
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// dispatch calls the handlers registered for a log and reports whether it was consumed.
+func (p *Processor) dispatch(ctx context.Context, chain *chainState, l fetchedLog) (bool, error) {
+	if chain.decoder == nil || l.invalid != nil {
+		return false, nil
+	}
+
+	ev, err := chain.decoder.decode(l.Log, l.typed)
+	if err != nil {
+		// Not one of the registered events, or one sharing its topic with a different layout
+		return false, nil
+	}
+
+	// Decode matched topic0 and the topic count
+	key := EventKey{Topic: l.typed.Topics[0], Indexed: len(l.typed.Topics) - 1}
+
+	dctx := l.Context
+	if dctx == nil {
+		dctx = newDecodeContext(chain.chainInfo.ChainId, l.typed)
+		dctx.state = chain.state
+	}
+
+	for _, h := range chain.handlers[key] {
+		herr := HandlerError{
+			Event:           ev.Signature,
+			BlockNumber:     dctx.BlockNumber,
+			TransactionHash: l.TransactionHash,
+			LogIndex:        l.LogIndex,
+		}
+		if err := callHandler(ctx, chain, herr, func() error { return h.fn(ctx, ev, dctx) }); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// callHandler runs a handler according to Options.HandlerErrors, herr describes it on failure.
+func callHandler(ctx context.Context, chain *chainState, herr HandlerError, fn func() error) error {
+	retry := chain.opts.HandlerErrors == HandlerErrorRetry
+	call := func() error {
+		if err := fn(); err != nil {
+			failed := herr
+			failed.Err = err
+			failed.retryable = retry
+			return &failed
+		}
+		return nil
+	}
+
+	var err error
+	if retry {
+		err = RetryWithBackoff(ctx, *chain.opts.RetryConfig, call)
+	} else {
+		err = call()
+	}
+	if err != nil {
+		log.Printf("Handler failed: %v", err)
+	}
+	return err
+}
+
+func containsHash(hashes []Hash, h Hash) bool {
+	for _, v := range hashes {
+		if v == h {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockHandler processes one block header. It runs after the log handlers of the block.
+type BlockHandler func(ctx context.Context, block TypedBlock) error
+
+type blockHandler struct {
+	// every fires on block numbers divisible by it, 0 when interval is used
+	every uint64
+	// interval fires on the first block of each period of block time
+	interval time.Duration
+	fn       BlockHandler
+}
+
+// OnBlock registers a handler called for every block number divisible by every, 0 or 1 meaning every block.
+// Block handlers of a chain are called in block order, after the log handlers of the same block.
+// Handlers must be registered before the chain runs.
+func (p *Processor) OnBlock(chainId string, every uint64, handler BlockHandler) error {
+	if every == 0 {
+		every = 1
+	}
+	return p.addBlockHandler(chainId, &blockHandler{every: every, fn: handler})
+}
+
+// OnInterval registers a handler called on the first block of every interval of block time, e.g. hourly snapshots.
+// Periods are aligned on the unix epoch and compared with the previous block, so replays fire on the same blocks.
+func (p *Processor) OnInterval(chainId string, interval time.Duration, handler BlockHandler) error {
+	if interval < time.Second {
+		return fmt.Errorf("interval must be at least one second, got %s", interval)
+	}
+	return p.addBlockHandler(chainId, &blockHandler{interval: interval, fn: handler})
+}
+
+func (p *Processor) addBlockHandler(chainId string, h *blockHandler) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	chain, exists := p.chains[chainId]
+	if !exists {
+		return fmt.Errorf("chain %s not found", chainId)
+	}
+	if chain.running() {
+		return fmt.Errorf("cannot register handler while chain %s is running", chainId)
+	}
+
+	chain.blockHandlers = append(chain.blockHandlers, h)
+	return nil
+}
+
+// runHandlers calls the log and block handlers of a window in block order, and reports which logs were consumed.
+func (p *Processor) runHandlers(ctx context.Context, chain *chainState, from uint64, to uint64, logs []fetchedLog) ([]bool, error) {
+	consumed := make([]bool, len(logs))
+	dispatchLog := func(i int) error {
+		handled, err := p.dispatch(ctx, chain, logs[i])
+		consumed[i] = handled
+		return err
+	}
+
+	if len(chain.blockHandlers) == 0 {
+		for i := range logs {
+			if err := dispatchLog(i); err != nil {
+				return nil, err
+			}
+		}
+		return consumed, nil
+	}
+
+	headers, err := p.blockHandlerHeaders(ctx, chain, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	i := 0
+	for blockNum := from; blockNum <= to; blockNum++ {
+		for ; i < len(logs); i++ {
+			if logs[i].typed.BlockNumber > blockNum {
+				break
+			}
+			if err := dispatchLog(i); err != nil {
+				return nil, err
+			}
+		}
+
+		header, ok := headers[blockNum]
+		if !ok {
+			continue
+		}
+		parent, hasParent := headers[blockNum-1]
+		for _, h := range chain.blockHandlers {
+			if !h.fires(header, parent, hasParent) {
+				continue
+			}
+			herr := HandlerError{BlockNumber: blockNum}
+			if err := callHandler(ctx, chain, herr, func() error { return h.fn(ctx, header) }); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for ; i < len(logs); i++ {
+		if err := dispatchLog(i); err != nil {
+			return nil, err
+		}
+	}
+	return consumed, nil
+}
+
+// fires reports whether the handler is due on a block.
+// Interval handlers fire when the block starts a new period, the genesis block always does.
+func (h *blockHandler) fires(block TypedBlock, parent TypedBlock, hasParent bool) bool {
+	if h.every > 0 {
+		return block.Number%h.every == 0
+	}
+	if !hasParent {
+		return true
+	}
+	seconds := int64(h.interval / time.Second)
+	return block.Timestamp.Unix()/seconds != parent.Timestamp.Unix()/seconds
+}
+
+// blockHandlerHeaders fetches the headers the block handlers of a chain need for a window.
+func (p *Processor) blockHandlerHeaders(ctx context.Context, chain *chainState, from uint64, to uint64) (map[uint64]TypedBlock, error) {
+	var numbers []uint64
+	// Interval handlers compare every block with its parent
+	start := from
+	for _, h := range chain.blockHandlers {
+		if h.every == 0 && from > 0 {
+			start = from - 1
+			break
+		}
+	}
+	for blockNum := start; blockNum <= to; blockNum++ {
+		for _, h := range chain.blockHandlers {
+			if h.every == 0 || blockNum%h.every == 0 {
+				numbers = append(numbers, blockNum)
+				break
+			}
+		}
+	}
+	if len(numbers) == 0 {
+		return nil, nil
+	}
+
+	var blocks []Block
+	err := RetryWithBackoff(ctx, *chain.opts.RetryConfig, func() error {
+		var err error
+		blocks, err = p.getHeadersByNumber(ctx, numbers, chain)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[uint64]TypedBlock, len(blocks))
+	for i, block := range blocks {
+		typed, err := block.Typed()
+		if err != nil {
+			return nil, fmt.Errorf("invalid header of block %d: %w", numbers[i], err)
+		}
+		headers[numbers[i]] = typed
+	}
+	return headers, nil
+}
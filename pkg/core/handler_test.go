@@ -0,0 +1,142 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessorOnBlock_AfterLogHandlers(t *testing.T) {
+	transfer := func(block uint64) Log {
+		l := factoryTestLog(testOther, transferTopic, block, 0, fmt.Sprintf("0x%064x", block))
+		l.Topics = append(l.Topics, addressTopic(testPoolA), addressTopic(testPoolB))
+		return l
+	}
+	// Block n has timestamp 1700000000 + 12n, minute boundaries fall in blocks 4 and 9
+	srv := newFactoryServer(12, []Log{transfer(4), transfer(8)})
+	defer srv.Close()
+
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:          3,
+		FetcherConcurrency: 3,
+	})
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+
+	assert.NoError(t, processor.On("1", "Transfer(address indexed from, address indexed to, uint256 value)", func(ctx context.Context, ev *DecodedEvent, dctx *DecodeContext) error {
+		record(fmt.Sprintf("log@%d", dctx.BlockNumber))
+		return nil
+	}))
+	assert.NoError(t, processor.OnBlock("1", 4, func(ctx context.Context, block TypedBlock) error {
+		record(fmt.Sprintf("every4@%d", block.Number))
+		return nil
+	}))
+	assert.NoError(t, processor.OnInterval("1", time.Minute, func(ctx context.Context, block TypedBlock) error {
+		assert.Equal(t, time.Unix(int64(1700000000+12*block.Number), 0).UTC(), block.Timestamp)
+		record(fmt.Sprintf("minute@%d", block.Number))
+		return nil
+	}))
+	assert.Error(t, processor.OnInterval("1", time.Millisecond, nil))
+	assert.Error(t, processor.OnBlock("2", 1, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go processor.Run(ctx)
+
+	expected := []string{"log@4", "every4@4", "minute@4", "log@8", "every4@8", "minute@9", "every4@12"}
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(calls) >= len(expected)
+	}, 4*time.Second, 10*time.Millisecond)
+	processor.Stop(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, expected, calls)
+}
+
+func TestProcessorOnBlock_ErrorStopsChain(t *testing.T) {
+	srv := newFactoryServer(12, nil)
+	defer srv.Close()
+
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{RangeSize: 5})
+
+	var seen []uint64
+	processor.OnBlock("1", 1, func(ctx context.Context, block TypedBlock) error {
+		seen = append(seen, block.Number)
+		if block.Number == 3 {
+			return errors.New("snapshot failed")
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err := processor.Run(ctx)
+
+	var handlerErr *HandlerError
+	assert.True(t, errors.As(err, &handlerErr))
+	assert.Equal(t, uint64(3), handlerErr.BlockNumber)
+	assert.ErrorContains(t, err, "block handler failed at block 3")
+	assert.Equal(t, []uint64{1, 2, 3}, seen)
+}
+
+func TestProcessorOn_SeveralEvents(t *testing.T) {
+	log := func(topic string, block uint64) Log {
+		l := factoryTestLog(testOther, topic, block, 0, fmt.Sprintf("0x%064x", block))
+		l.Topics = append(l.Topics, addressTopic(testPoolA), addressTopic(testPoolB))
+		return l
+	}
+	// The server filters topics by position like a node, a flat list of topic0s would match nothing
+	srv := newFactoryServer(12, []Log{
+		log(transferTopic, 4),
+		log(testApprovalTopic, 6),
+		log(fmt.Sprintf("0x%064x", 1), 8),
+	})
+	defer srv.Close()
+
+	processor := NewProcessor()
+	opts := &Options{
+		RangeSize:      5,
+		LogsBufferSize: 16,
+		EndBlock:       12,
+		Topics:         []string{"Transfer(address,address,uint256)"},
+	}
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, opts)
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(ctx context.Context, ev *DecodedEvent, dctx *DecodeContext) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, fmt.Sprintf("%s@%d", ev.Name, dctx.BlockNumber))
+		return nil
+	}
+	assert.NoError(t, processor.On("1", "Transfer(address indexed from, address indexed to, uint256 value)", record))
+	assert.NoError(t, processor.On("1", "Approval(address indexed owner, address indexed spender, uint256 value)", record))
+
+	// Handlers enrich their chain only, the shared Options are untouched
+	assert.Empty(t, opts.Enrichment)
+	processor.AddChain(ChainInfo{ChainId: "2", RPC: NewHTTPRPC(srv.URL, 0)}, opts)
+	assert.False(t, processor.chains["2"].enrich())
+	processor.RemoveChain(context.Background(), "2")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, processor.Run(ctx))
+	assert.Equal(t, []string{"Transfer@4", "Approval@6"}, calls)
+}
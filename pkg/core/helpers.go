@@ -2,6 +2,8 @@ package core
 
 import (
 	"encoding/hex"
+	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
 
@@ -23,6 +25,19 @@ func HexQtyToUint64(s string) (uint64, error) {
 	return strconv.ParseUint(s, 10, 64)
 }
 
+// hexToBig parses a hex quantity that may not fit in uint64 (e.g. gas prices, uint256 values).
+func hexToBig(s string) (*big.Int, error) {
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if trimmed == "" {
+		return new(big.Int), nil
+	}
+	n, ok := new(big.Int).SetString(trimmed, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid hex quantity %q", s)
+	}
+	return n, nil
+}
+
 // Keccak256 computes the Keccak256 hash of input data
 func Keccak256(data []byte) []byte {
 	hash := sha3.NewLegacyKeccak256()
@@ -32,9 +47,14 @@ func Keccak256(data []byte) []byte {
 
 // FunctionSignatureTopic converts a funciton signature to its Keccak256
 // Example: "Transfer(address,address,uint256)" -> "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+// Declarations are canonicalized first, "Transfer(address indexed from, address indexed to, uint256 value)" gives the same topic.
 func FunctionSignatureToTopic(signature string) string {
 	// Remove all whitespaces
 	cleanSig := strings.ReplaceAll(signature, " ", "")
+	// Drop parameter names, indexed and tuple keywords
+	if canonical, err := CanonicalSignature(signature); err == nil {
+		cleanSig = canonical
+	}
 
 	// Hash the clean signature
 	hash := Keccak256([]byte(cleanSig))
@@ -0,0 +1,238 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type ChainStatus string
+
+const (
+	ChainStopped     ChainStatus = "stopped"     // Not started yet or stopped
+	ChainRunning     ChainStatus = "running"     // Started, head not known yet
+	ChainPaused      ChainStatus = "paused"      // Halted by PauseChain
+	ChainBackfilling ChainStatus = "backfilling" // More than one window behind the target
+	ChainAtTip       ChainStatus = "at-tip"      // Within one window of the target
+	ChainErrored     ChainStatus = "errored"     // Stopped on error
+	ChainRestarting  ChainStatus = "restarting"  // Failed, waiting for the RestartPolicy delay
+)
+
+// chainControl holds the lifecycle state of a chain, shared between runChain and the Processor API.
+type chainControl struct {
+	status ChainStatus
+	paused bool
+	// pauseCh is closed while the chain is paused
+	pauseCh chan struct{}
+	// resumeCh is closed when a paused chain is resumed
+	resumeCh chan struct{}
+	// removeCh is closed by RemoveChain
+	removeCh chan struct{}
+	// supervision state, see ChainHealth
+	lastErr   error
+	lastErrAt time.Time
+	restarts  int
+}
+
+func newChainControl() chainControl {
+	return chainControl{
+		status:   ChainStopped,
+		pauseCh:  make(chan struct{}),
+		resumeCh: make(chan struct{}),
+		removeCh: make(chan struct{}),
+	}
+}
+
+func (c *chainState) setStatus(status ChainStatus) {
+	c.controlMu.Lock()
+	defer c.controlMu.Unlock()
+	// Paused wins over progress updates of the batch being drained
+	if c.control.paused && (status == ChainBackfilling || status == ChainAtTip || status == ChainRunning) {
+		return
+	}
+	c.control.status = status
+}
+
+func (c *chainState) pauseSignal() <-chan struct{} {
+	c.controlMu.Lock()
+	defer c.controlMu.Unlock()
+	return c.control.pauseCh
+}
+
+// waitResume returns the channel to wait on when the chain is paused, nil otherwise.
+func (c *chainState) waitResume() <-chan struct{} {
+	c.controlMu.Lock()
+	defer c.controlMu.Unlock()
+	if !c.control.paused {
+		return nil
+	}
+	return c.control.resumeCh
+}
+
+func (c *chainState) removeSignal() <-chan struct{} {
+	c.controlMu.Lock()
+	defer c.controlMu.Unlock()
+	return c.control.removeCh
+}
+
+// removing reports whether RemoveChain was called on the chain.
+func (c *chainState) removing() bool {
+	select {
+	case <-c.removeSignal():
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *chainState) remove() {
+	c.controlMu.Lock()
+	defer c.controlMu.Unlock()
+	select {
+	case <-c.control.removeCh:
+	default:
+		close(c.control.removeCh)
+	}
+}
+
+// PauseChain halts fetching on one chain once its in-flight windows are committed.
+// Other chains are not affected.
+func (p *Processor) PauseChain(chainId string) error {
+	chain, err := p.chain(chainId)
+	if err != nil {
+		return err
+	}
+
+	chain.controlMu.Lock()
+	defer chain.controlMu.Unlock()
+	if chain.control.paused {
+		return nil
+	}
+	chain.control.paused = true
+	chain.control.status = ChainPaused
+	chain.control.resumeCh = make(chan struct{})
+	close(chain.control.pauseCh)
+	return nil
+}
+
+// ResumeChain restarts fetching on a paused chain.
+func (p *Processor) ResumeChain(chainId string) error {
+	chain, err := p.chain(chainId)
+	if err != nil {
+		return err
+	}
+
+	chain.controlMu.Lock()
+	defer chain.controlMu.Unlock()
+	if !chain.control.paused {
+		return nil
+	}
+	chain.control.paused = false
+	chain.control.status = ChainRunning
+	chain.control.pauseCh = make(chan struct{})
+	close(chain.control.resumeCh)
+	return nil
+}
+
+// State returns the lifecycle status of a chain.
+func (p *Processor) State(chainId string) (ChainStatus, error) {
+	chain, err := p.chain(chainId)
+	if err != nil {
+		return "", err
+	}
+
+	chain.controlMu.Lock()
+	defer chain.controlMu.Unlock()
+	return chain.control.status, nil
+}
+
+// Stop gracefully stops a running processor.
+// Chains stop planning new windows, commit the in-flight ones, persist their cursor and return.
+// Sinks are flushed once every chain stopped. When ctx expires first the run is cancelled and Stop returns the ctx error.
+func (p *Processor) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.isRunning {
+		p.mu.Unlock()
+		return nil
+	}
+	done := p.runDone
+	cancel := p.cancel
+	p.stopOnce.Do(func() { close(p.stopCh) })
+	p.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		cancel()
+		<-done
+		return fmt.Errorf("graceful stop interrupted: %w", ctx.Err())
+	}
+
+	return p.flushSinks(ctx)
+}
+
+// stopping reports whether Stop was called on the current run.
+func (p *Processor) stopping() bool {
+	select {
+	case <-p.stopSignal():
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Processor) stopSignal() <-chan struct{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.stopCh
+}
+
+// flushSinks flushes every distinct sink of every chain.
+func (p *Processor) flushSinks(ctx context.Context) error {
+	p.mu.RLock()
+	var sinks []Sink
+	seen := make(map[Sink]bool)
+	for _, chain := range p.chains {
+		for _, sink := range chain.opts.Sinks {
+			if !seen[sink] {
+				seen[sink] = true
+				sinks = append(sinks, sink)
+			}
+		}
+	}
+	p.mu.RUnlock()
+
+	for _, sink := range sinks {
+		if err := sink.Flush(ctx); err != nil {
+			return fmt.Errorf("sink flush failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// flushChainSinks flushes the sinks of one chain, a ChainFlusher only for that chain.
+func (p *Processor) flushChainSinks(ctx context.Context, chain *chainState) error {
+	for _, sink := range chain.opts.Sinks {
+		if s, ok := sink.(ChainFlusher); ok {
+			if err := s.FlushChain(ctx, chain.chainInfo.ChainId); err != nil {
+				return fmt.Errorf("sink flush failed: %w", err)
+			}
+			continue
+		}
+		if err := sink.Flush(ctx); err != nil {
+			return fmt.Errorf("sink flush failed: %w", err)
+		}
+	}
+	return nil
+}
+
+func (p *Processor) chain(chainId string) (*chainState, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	chain, exists := p.chains[chainId]
+	if !exists {
+		return nil, fmt.Errorf("chain %s not found", chainId)
+	}
+	return chain, nil
+}
@@ -0,0 +1,358 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memorySink struct {
+	mu        sync.Mutex
+	batches   []Batch
+	rollbacks []uint64
+	flushes   int
+}
+
+func (s *memorySink) Write(ctx context.Context, batch Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, batch)
+	return nil
+}
+
+func (s *memorySink) Rollback(ctx context.Context, chainId string, ancestor uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rollbacks = append(s.rollbacks, ancestor)
+	return nil
+}
+
+func (s *memorySink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushes++
+	return nil
+}
+
+func (s *memorySink) written() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.batches)
+}
+
+type memoryCursorStore struct {
+	mu      sync.Mutex
+	cursors map[string]Cursor
+}
+
+func (s *memoryCursorStore) LoadCursor(ctx context.Context, chainId string) (*Cursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.cursors[chainId]
+	if !ok {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+func (s *memoryCursorStore) SaveCursor(ctx context.Context, cursor Cursor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cursors == nil {
+		s.cursors = make(map[string]Cursor)
+	}
+	s.cursors[cursor.ChainId] = cursor
+	return nil
+}
+
+// newChainServer serves a linear chain of the given height with no logs.
+func newChainServer(head uint64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		var result any
+		switch req.Method {
+		case "eth_blockNumber":
+			result = Uint64ToHexQty(head)
+		case "eth_getBlockByNumber":
+			blockNum, _ := HexQtyToUint64(fmt.Sprintf("%s", req.Params[0]))
+			result = map[string]any{
+				"number":     req.Params[0],
+				"hash":       fmt.Sprintf("hash-%d", blockNum),
+				"parentHash": fmt.Sprintf("hash-%d", blockNum-1),
+			}
+		case "eth_getLogs":
+			result = []any{}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": result})
+	}))
+}
+
+func TestLifecycle_PauseResumeStop(t *testing.T) {
+	srv := newChainServer(1000)
+	defer srv.Close()
+
+	sink := &memorySink{}
+	store := &memoryCursorStore{}
+
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:          10,
+		FetcherConcurrency: 1,
+		LogsBufferSize:     10,
+		Sinks:              []Sink{sink},
+		CursorStore:        store,
+	})
+
+	state, err := processor.State("1")
+	assert.NoError(t, err)
+	assert.Equal(t, ChainStopped, state)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- processor.Run(ctx) }()
+
+	assert.Eventually(t, func() bool { return sink.written() > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, processor.PauseChain("1"))
+	state, _ = processor.State("1")
+	assert.Equal(t, ChainPaused, state)
+
+	// In-flight windows are drained, then nothing more is written
+	time.Sleep(200 * time.Millisecond)
+	paused := sink.written()
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, paused, sink.written())
+
+	// The cursor is persisted when the chain parks
+	saved, _ := store.LoadCursor(ctx, "1")
+	if assert.NotNil(t, saved) {
+		assert.Equal(t, uint64(paused*10), saved.BlockNumber)
+		assert.NotEmpty(t, saved.WindowHashes)
+	}
+
+	assert.NoError(t, processor.ResumeChain("1"))
+	assert.Eventually(t, func() bool { return sink.written() > paused }, 5*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, processor.Stop(ctx))
+	assert.NoError(t, <-runErr)
+
+	state, _ = processor.State("1")
+	assert.Equal(t, ChainStopped, state)
+	assert.Equal(t, 1, sink.flushes)
+
+	// Batches are contiguous and the final cursor matches the last one
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	for i := 1; i < len(sink.batches); i++ {
+		assert.Equal(t, sink.batches[i-1].ToBlock+1, sink.batches[i].FromBlock)
+	}
+	last := sink.batches[len(sink.batches)-1]
+	assert.Equal(t, last.ToBlock, last.Cursor.BlockNumber)
+
+	saved, _ = store.LoadCursor(ctx, "1")
+	assert.Equal(t, last.ToBlock, saved.BlockNumber)
+}
+
+func TestLifecycle_ResumeFromCursorStore(t *testing.T) {
+	srv := newChainServer(100)
+	defer srv.Close()
+
+	sink := &memorySink{}
+	store := &memoryCursorStore{}
+	store.SaveCursor(context.Background(), Cursor{
+		ChainId:      "1",
+		BlockNumber:  49,
+		WindowHashes: []WindowHash{{BlockNumber: 49, Hash: "hash-49"}},
+	})
+
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:          10,
+		FetcherConcurrency: 2,
+		LogsBufferSize:     10,
+		Sinks:              []Sink{sink},
+		CursorStore:        store,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go processor.Run(ctx)
+
+	assert.Eventually(t, func() bool { return sink.written() > 0 }, 4*time.Second, 10*time.Millisecond)
+	assert.NoError(t, processor.Stop(ctx))
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	assert.Equal(t, uint64(50), sink.batches[0].FromBlock)
+	assert.Empty(t, sink.rollbacks)
+}
+
+func TestLifecycle_EndBlock(t *testing.T) {
+	srv := newChainServer(100)
+	defer srv.Close()
+
+	sink := &memorySink{}
+	store := &memoryCursorStore{}
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:          10,
+		FetcherConcurrency: 2,
+		LogsBufferSize:     10,
+		EndBlock:           35,
+		Sinks:              []Sink{sink},
+		CursorStore:        store,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Run returns once the chain committed EndBlock
+	assert.NoError(t, processor.Run(ctx))
+	assert.NoError(t, ctx.Err())
+
+	status, err := processor.State("1")
+	assert.NoError(t, err)
+	assert.Equal(t, ChainStopped, status)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	assert.Equal(t, uint64(35), sink.batches[len(sink.batches)-1].ToBlock)
+	assert.Equal(t, 1, sink.flushes)
+	cursor, _ := store.LoadCursor(ctx, "1")
+	assert.Equal(t, uint64(35), cursor.BlockNumber)
+}
+
+// chainFlusherSink is a memorySink shared by chains that records which chain was flushed.
+type chainFlusherSink struct {
+	memorySink
+	flushedChains []string
+}
+
+func (s *chainFlusherSink) FlushChain(ctx context.Context, chainId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushedChains = append(s.flushedChains, chainId)
+	return nil
+}
+
+func TestLifecycle_EndBlockFlushesOwnChain(t *testing.T) {
+	srv := newChainServer(100)
+	defer srv.Close()
+
+	sink := &chainFlusherSink{}
+	processor := NewProcessor()
+	for _, chain := range []struct {
+		id       string
+		endBlock uint64
+	}{{"1", 15}, {"2", 35}} {
+		processor.AddChain(ChainInfo{ChainId: chain.id, RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+			RangeSize:      10,
+			LogsBufferSize: 10,
+			EndBlock:       chain.endBlock,
+			Sinks:          []Sink{sink},
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, processor.Run(ctx))
+
+	// Each chain flushed its own state, the sink was never flushed whole
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	assert.ElementsMatch(t, []string{"1", "2"}, sink.flushedChains)
+	assert.Zero(t, sink.flushes)
+}
+
+func TestLifecycle_UnknownChain(t *testing.T) {
+	processor := NewProcessor()
+
+	assert.Error(t, processor.PauseChain("1"))
+	assert.Error(t, processor.ResumeChain("1"))
+	_, err := processor.State("1")
+	assert.Error(t, err)
+	assert.NoError(t, processor.Stop(context.Background()), "stopping an idle processor is a no-op")
+}
+
+func TestLifecycle_AddAndRemoveChainWhileRunning(t *testing.T) {
+	srv := newChainServer(1000)
+	defer srv.Close()
+
+	ethSink := &memorySink{}
+	polySink := &memorySink{}
+
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:      10,
+		LogsBufferSize: 10,
+		Sinks:          []Sink{ethSink},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- processor.Run(ctx) }()
+	assert.Eventually(t, func() bool { return ethSink.written() > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	// Hot add
+	assert.NoError(t, processor.AddChain(ChainInfo{ChainId: "137", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:      10,
+		LogsBufferSize: 10,
+		Sinks:          []Sink{polySink},
+	}))
+	assert.Eventually(t, func() bool { return polySink.written() > 0 }, 5*time.Second, 10*time.Millisecond)
+
+	polyLogs, err := processor.Logs("137")
+	assert.NoError(t, err)
+
+	// Hot remove closes the logs channel and leaves the other chain running
+	assert.NoError(t, processor.RemoveChain(ctx, "137"))
+	_, open := <-polyLogs
+	assert.False(t, open)
+	assert.Equal(t, 1, polySink.flushes)
+
+	_, err = processor.Logs("137")
+	assert.Error(t, err)
+
+	written := ethSink.written()
+	assert.Eventually(t, func() bool { return ethSink.written() > written }, 5*time.Second, 10*time.Millisecond)
+	state, _ := processor.State("1")
+	assert.NotEqual(t, ChainStopped, state)
+
+	// Removing the last chain ends the run
+	assert.NoError(t, processor.RemoveChain(ctx, "1"))
+	select {
+	case err := <-runErr:
+		assert.NoError(t, err)
+	case <-ctx.Done():
+		t.Fatal("Run did not return after its last chain was removed")
+	}
+
+	assert.Error(t, processor.RemoveChain(ctx, "1"))
+}
+
+func TestLifecycle_RemoveIdleChain(t *testing.T) {
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC("http://localhost", 0)}, &Options{RangeSize: 10})
+
+	logsCh, _ := processor.Logs("1")
+	assert.NoError(t, processor.RemoveChain(context.Background(), "1"))
+	_, open := <-logsCh
+	assert.False(t, open)
+}
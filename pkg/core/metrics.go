@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Metrics receives the processor and RPC measurements.
+// Implementations must be safe for concurrent use, they are called from every chain and fetcher.
+// See pkg/metrics/prometheus for a Prometheus implementation.
+type Metrics interface {
+	// SetCursor reports the last committed block of a chain
+	SetCursor(chainId string, block uint64)
+	// SetHead reports the head height the chain last saw
+	SetHead(chainId string, block uint64)
+	// WindowCommitted reports a committed window and the number of logs it emitted
+	WindowCommitted(chainId string, logs int)
+	// Reorg reports a reorg and the number of blocks rolled back
+	Reorg(chainId string, depth uint64)
+	// ReorgFallback reports a reorg whose ancestor couldn't be found, rolled back by the hard fallback
+	ReorgFallback(chainId string)
+	// RPCRequest reports a request to an endpoint and its outcome, err is nil on success
+	RPCRequest(method string, endpoint string, duration time.Duration, err error)
+	// RPCRetry reports a failed request being retried
+	RPCRetry(method string, endpoint string)
+}
+
+// nopMetrics is used when Options.Metrics is not set.
+type nopMetrics struct{}
+
+func (nopMetrics) SetCursor(string, uint64)                        {}
+func (nopMetrics) SetHead(string, uint64)                          {}
+func (nopMetrics) WindowCommitted(string, int)                     {}
+func (nopMetrics) Reorg(string, uint64)                            {}
+func (nopMetrics) ReorgFallback(string)                            {}
+func (nopMetrics) RPCRequest(string, string, time.Duration, error) {}
+func (nopMetrics) RPCRetry(string, string)                         {}
+
+// ErrorClass classifies an RPC error for metric labels.
+// It returns "http_<status>" for an HTTPError, "rpc_<code>" for an RPCError,
+// "context" for a cancelled or expired context and "transport" otherwise.
+func ErrorClass(err error) string {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return "http_" + strconv.Itoa(httpErr.StatusCode)
+	}
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return "rpc_" + strconv.Itoa(rpcErr.Code)
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return "context"
+	}
+	return "transport"
+}
+
+// SetMetrics reports the requests of the client to m.
+// AddChain sets Options.Metrics on an HTTPRPC that has none.
+func (r *HTTPRPC) SetMetrics(m Metrics) {
+	r.metrics = m
+}
+
+// observe reports a request, a failure is tagged so RetryWithBackoff can report its retry.
+func (r *HTTPRPC) observe(method string, start time.Time, err error) error {
+	if r.metrics == nil {
+		return err
+	}
+	r.metrics.RPCRequest(method, r.endpoint, time.Since(start), err)
+	if err == nil {
+		return nil
+	}
+	return &rpcFailure{method: method, endpoint: r.endpoint, metrics: r.metrics, err: err}
+}
+
+// rpcFailure carries the request a failure came from, it is transparent to errors.As and Error.
+type rpcFailure struct {
+	method   string
+	endpoint string
+	metrics  Metrics
+	err      error
+}
+
+func (e *rpcFailure) Error() string {
+	return e.err.Error()
+}
+
+func (e *rpcFailure) Unwrap() error {
+	return e.err
+}
+
+// reportRetry reports the retry of a failed request on the metrics of its client.
+func reportRetry(err error) {
+	var failure *rpcFailure
+	if errors.As(err, &failure) {
+		failure.metrics.RPCRetry(failure.method, failure.endpoint)
+	}
+}
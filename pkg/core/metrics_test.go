@@ -0,0 +1,181 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingMetrics keeps what the processor and RPC layer reported.
+type recordingMetrics struct {
+	mu       sync.Mutex
+	cursor   map[string]uint64
+	head     map[string]uint64
+	windows  int
+	logs     int
+	reorgs   []uint64
+	requests map[string]int
+	errors   []string
+	retries  map[string]int
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{
+		cursor:   make(map[string]uint64),
+		head:     make(map[string]uint64),
+		requests: make(map[string]int),
+		retries:  make(map[string]int),
+	}
+}
+
+func (m *recordingMetrics) SetCursor(chainId string, block uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cursor[chainId] = block
+}
+
+func (m *recordingMetrics) SetHead(chainId string, block uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.head[chainId] = block
+}
+
+func (m *recordingMetrics) WindowCommitted(chainId string, logs int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.windows++
+	m.logs += logs
+}
+
+func (m *recordingMetrics) Reorg(chainId string, depth uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reorgs = append(m.reorgs, depth)
+}
+
+func (m *recordingMetrics) ReorgFallback(chainId string) {}
+
+func (m *recordingMetrics) RPCRequest(method string, endpoint string, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[method]++
+	if err != nil {
+		m.errors = append(m.errors, ErrorClass(err))
+	}
+}
+
+func (m *recordingMetrics) RPCRetry(method string, endpoint string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries[method]++
+}
+
+func TestMetrics_Processor(t *testing.T) {
+	var logs []Log
+	for n := uint64(1); n <= 40; n++ {
+		logs = append(logs, factoryTestLog(testPoolA, testTransferTopic, n, 0, "0x"))
+	}
+	srv := newFactoryServer(100, logs)
+	defer srv.Close()
+
+	metrics := newRecordingMetrics()
+	rpc := NewHTTPRPC(srv.URL, 0)
+	processor := NewProcessor()
+	err := processor.AddChain(ChainInfo{ChainId: "1", RPC: rpc}, &Options{
+		RangeSize:          10,
+		FetcherConcurrency: 2,
+		LogsBufferSize:     64,
+		EndBlock:           40,
+		Metrics:            metrics,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, metrics, rpc.metrics, "the client reports to Options.Metrics")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, processor.Run(ctx))
+
+	assert.Equal(t, uint64(40), metrics.cursor["1"])
+	assert.Equal(t, uint64(100), metrics.head["1"])
+	assert.Equal(t, 4, metrics.windows)
+	assert.Equal(t, 40, metrics.logs)
+	assert.Equal(t, 4, metrics.requests["eth_getLogs"])
+	assert.GreaterOrEqual(t, metrics.requests["eth_blockNumber"], 1)
+	assert.Empty(t, metrics.errors)
+	assert.Empty(t, metrics.reorgs)
+}
+
+func TestMetrics_RPCRetries(t *testing.T) {
+	healthy := newChainServer(100)
+	defer healthy.Close()
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		healthy.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	metrics := newRecordingMetrics()
+	rpc := NewHTTPRPC(srv.URL, 0)
+	rpc.SetMetrics(metrics)
+
+	cfg := DefaultRetryConfig()
+	cfg.InitialBackoff = time.Millisecond
+	var head string
+	err := RetryWithBackoff(context.Background(), cfg, func() error {
+		var err error
+		head, err = rpc.Head(context.Background())
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "0x64", head)
+	assert.Equal(t, 2, metrics.requests["eth_blockNumber"])
+	assert.Equal(t, []string{"http_503"}, metrics.errors)
+	assert.Equal(t, 1, metrics.retries["eth_blockNumber"])
+
+	// Reported errors keep their message and type
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	rpc = NewHTTPRPC(failing.URL, 0)
+	rpc.SetMetrics(metrics)
+	_, err = rpc.GetBlocks(context.Background(), []string{"0x1", "0x2"})
+	assert.EqualError(t, err, "http error 400: 400 Bad Request")
+	var httpErr *HTTPError
+	assert.True(t, errors.As(err, &httpErr))
+
+	err = RetryWithBackoff(context.Background(), cfg, func() error {
+		_, err := rpc.GetBlocks(context.Background(), []string{"0x1"})
+		return err
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 2, metrics.requests["eth_getBlockByNumber"])
+	assert.Zero(t, metrics.retries["eth_getBlockByNumber"], "client errors are not retried")
+}
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&HTTPError{StatusCode: 429}, "http_429"},
+		{fmt.Errorf("wrapped: %w", &RPCError{Code: -32005}), "rpc_-32005"},
+		{fmt.Errorf("error fetching rpc: %w", context.DeadlineExceeded), "context"},
+		{errors.New("connection refused"), "transport"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ErrorClass(tt.err))
+	}
+}
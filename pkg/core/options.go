@@ -1,10 +1,28 @@
 package core
 
+import "go.opentelemetry.io/otel/trace"
+
 type FetchMode string
 
 const (
-	FetchModeLogs     FetchMode = "logs"     // Use eth_getlogs for efficiency
-	FetchModeReceipts FetchMode = "receipts" // Use eth_getBlockReceipts for reliability
+	FetchModeLogs         FetchMode = "logs"         // Use eth_getlogs for efficiency
+	FetchModeReceipts     FetchMode = "receipts"     // Use eth_getBlockReceipts for reliability
+	FetchModeTransactions FetchMode = "transactions" // Index calls with eth_getBlockByNumber full transactions
+)
+
+type TraceMethod string
+
+const (
+	TraceDebug  TraceMethod = "debug" // debug_traceBlockByNumber with the callTracer, geth and erigon
+	TraceParity TraceMethod = "trace" // trace_block, erigon, nethermind and reth
+)
+
+type EnrichmentLevel string
+
+const (
+	EnrichmentNone    EnrichmentLevel = "none"    // Emit raw logs
+	EnrichmentBlock   EnrichmentLevel = "block"   // Attach parsed positions and block timestamp
+	EnrichmentReceipt EnrichmentLevel = "receipt" // Also attach tx sender, receiver, status and gas from receipts
 )
 
 type Options struct {
@@ -38,14 +56,76 @@ type Options struct {
 	ReorgLookbackBlocks uint64
 	// Topics is the event for indexer to listen and get the log
 	Topics []string
+	// Addresses restricts indexing to logs emitted by these contracts.
+	// Leave empty to accept logs from any address.
+	Addresses []string
+	// Factories discover child contracts at runtime and index them from their creation block.
+	// With factories, an empty Addresses means no static contract instead of any contract.
+	// Factory and child logs are always fetched with eth_getLogs.
+	Factories []Factory
 	// FetchMode determines which RPC method to use for fetching logs
 	// - "logs": Uses eth_getLogs (default, more efficient)
 	// - "receipts": Uses eth_getBlockReceipts (more reliable, higher bandwidth)
+	// - "transactions": Emits Calls instead of logs, see Selectors and Traces
 	FetchMode FetchMode
+	// Selectors restricts transactions mode to calls of these functions, with Addresses restricting the callee.
+	// Entries are 4-byte selectors like "0xa9059cbb" or signatures like "transfer(address to, uint256 amount)",
+	// calldata of signatures is decoded into Call.Decoded. Failed transactions are matched too.
+	// Leave empty to accept any call.
+	Selectors []string
+	// Traces also matches internal calls in transactions mode, the RPC must implement TraceRPC.
+	// - "debug": debug_traceBlockByNumber with the callTracer
+	// - "trace": trace_block
+	// Leave empty to only match transactions.
+	Traces TraceMethod
+	// VerifyIntegrity checks provider responses against the block headers.
+	// - "receipts": recomputes the receipts root and logs bloom of every block
+	// - "logs": checks every log against its block logsBloom and re-reads from receipts the blocks whose bloom may hold
+	//   an address and topic pair none of their logs has. A dropped log sharing both with a returned log goes unnoticed
+	// Costs one extra header call per block, off by default.
+	VerifyIntegrity bool
+	// BloomPrescreen pulls block headers (batched when the RPC supports it) in receipts mode
+	// and only requests receipts for blocks whose logsBloom may match Topics and Addresses.
+	// Cuts bandwidth drastically on sparse contracts.
+	BloomPrescreen bool
+	// Enrichment attaches a DecodeContext to every emitted Log.
+	// - "none": raw logs (default)
+	// - "block": positions and block timestamp, one batched header call per window
+	// - "receipt": also tx from/to/status/gas, one receipts call per block with logs in logs mode
+	Enrichment EnrichmentLevel
+	// Errors are custom error declarations, e.g. "error InsufficientBalance(uint256 available, uint256 required)",
+	// decoding the revert data of failed state reads and traced calls into a RevertError.
+	// Error(string) and Panic(uint256) are always decoded.
+	Errors []string
+	// Multicall is the Multicall3 contract DecodeContext.Multicall aggregates through.
+	// Default: the canonical Multicall3 deployment
+	Multicall string
 	// RetryConfig manage how to handle retry on retriable errors.
 	// Use pointer since it nillable
 	// There is default settings
 	RetryConfig *RetryConfig
+	// HandlerErrors decides what a failing On handler does.
+	// - "stop": the chain fails, see RestartPolicy (default)
+	// - "retry": the handler is retried with RetryConfig before the chain fails
+	HandlerErrors HandlerErrorMode
+	// RestartPolicy decides whether the chain is restarted after a failure.
+	// Other chains keep running either way.
+	// Default: never restart
+	RestartPolicy *RestartPolicy
+	// Sinks receive every committed window in block order, and are rolled back on reorg.
+	Sinks []Sink
+	// CursorStore persists the chain position so a restart resumes where it stopped.
+	// The stored cursor takes precedence over StartBlock.
+	// A store that is also one of the Sinks gets the cursor through Batch.Cursor instead of SaveCursor.
+	CursorStore CursorStore
+	// Metrics receives the chain progress, reorgs and RPC requests.
+	// It is also set on an HTTPRPC client that has no metrics of its own.
+	// Default: no metrics
+	Metrics Metrics
+	// TracerProvider traces each window, its RPC calls, retry attempts, handlers, sink writes and log emission.
+	// Windows are children of the span in the Run context.
+	// Default: the global OpenTelemetry provider
+	TracerProvider trace.TracerProvider
 }
 
 type ChainInfo struct {
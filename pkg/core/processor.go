@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -26,9 +30,47 @@ type chainState struct {
 	// The number of block that we will fall back to in case we couldnt resolve reorg
 	hardFallbackBlocks uint64
 	// Storage to store the formatted topics
-	topics []string
+	topics []Hash
+	// Storage to store the parsed contract addresses
+	addresses []AddressBytes
+	// Handlers registered with On, keyed by topic and indexed count
+	decoder *Decoder
+	handlers map[EventKey][]eventHandler
+	// handlersEnrich gives handlers block enrichment when Options.Enrichment doesn't
+	handlersEnrich bool
+	// Handlers registered with OnBlock and OnInterval
+	blockHandlers []*blockHandler
+	// Parsed Options.Selectors, and the decoder of their signatures
+	selectors [][4]byte
+	callDecoder *Decoder
+	// callsCh receives the calls of transactions mode, nil in other modes
+	callsCh chan Call
+	// state backs DecodeContext.Call, nil when the RPC doesn't implement CallRPC
+	state *StateReader
+	// Parsed Options.Factories
+	factories []factoryState
+	// Children discovered by the factories, read by workers and written by the arbiter
+	childrenMu sync.RWMutex
+	children map[AddressBytes]childState
 	// options for processor
 	opts *Options
+	// cursorLoaded is set once the CursorStore was read, restarts keep the in-memory position
+	cursorLoaded bool
+	// lifecycle state, guarded by controlMu since it's read and written outside runChain
+	controlMu sync.Mutex
+	control chainControl
+	// cancel and done belong to the goroutine running the chain, guarded by Processor.mu
+	cancel context.CancelFunc
+	done chan struct{}
+	// Subscriptions receiving a copy of the emitted logs
+	subsMu sync.RWMutex
+	subs []*subscription
+	// logsClaimed is set once Logs was called, subscribed once Subscribe was.
+	// The logs channel is skipped for chains that only ever had subscribers
+	logsClaimed atomic.Bool
+	subscribed atomic.Bool
+	// tracer of Options.TracerProvider
+	tracer trace.Tracer
 }
 
 type Processor struct {
@@ -41,6 +83,20 @@ type Processor struct {
 	// isRunning track the processor state if it's running or stopped.
 	// False by default until the processor run.
 	isRunning bool
+	// stopCh is closed by Stop to drain the chains of the current run
+	stopCh chan struct{}
+	stopOnce *sync.Once
+	// cancel aborts the current run when a graceful Stop times out
+	cancel context.CancelFunc
+	// runDone is closed when the current run returned
+	runDone chan struct{}
+	// runCtx and group of the current run, chains added while running join them
+	runCtx context.Context
+	group *errgroup.Group
+	// active counts the chain goroutines of the current run
+	active int
+	// errorsCh streams chain failures, see Errors
+	errorsCh chan ChainError
 	// Mutex to access data safely
 	mu sync.RWMutex
 }
@@ -50,6 +106,9 @@ func NewProcessor() *Processor {
 		chains: make(map[string]*chainState),
 		logsCh: make(map[string]chan Log),
 		isRunning: false,
+		stopCh: make(chan struct{}),
+		stopOnce: &sync.Once{},
+		errorsCh: make(chan ChainError, errorsBufferSize),
 	}
 }
 
@@ -58,9 +117,9 @@ func (p *Processor) AddChain(chain ChainInfo, opts *Options) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	
-	if p.isRunning {
-        return fmt.Errorf("cannot add chain while processor is running")
-    }
+	if _, exists := p.chains[chain.ChainId]; exists && p.isRunning {
+		return fmt.Errorf("chain %s is already running", chain.ChainId)
+	}
 
 	cursor := opts.StartBlock; if cursor == 0 { cursor = 0 }
 
@@ -71,19 +130,73 @@ func (p *Processor) AddChain(chain ChainInfo, opts *Options) error {
 	if cap < 8 { cap = 8 }
 	if cap > 256 { cap = 256 }
 
-	topics := ConvertToTopics(opts.Topics)
+	topics := make([]Hash, len(opts.Topics))
+	for i, topic := range ConvertToTopics(opts.Topics) {
+		h, err := ParseHash(topic)
+		if err != nil {
+			return fmt.Errorf("invalid topic %q: %w", opts.Topics[i], err)
+		}
+		topics[i] = h
+	}
+
+	addresses := make([]AddressBytes, len(opts.Addresses))
+	for i, addr := range opts.Addresses {
+		a, err := ParseAddressBytes(addr)
+		if err != nil {
+			return err
+		}
+		addresses[i] = a
+	}
+
+	factories, err := newFactoryStates(opts.Factories, topics)
+	if err != nil {
+		return err
+	}
 
 	// Check if fetch mode exists, fallback to logs as default if not specified
 	if opts.FetchMode == "" {
 		opts.FetchMode = FetchModeLogs
 	}
 
+	selectors, callDecoder, err := newCallFilter(opts, chain.RPC)
+	if err != nil {
+		return err
+	}
+
 	// Check if retryconfig exists, use default if not specified
 	if opts.RetryConfig == nil {
 		defaultCfg := DefaultRetryConfig()
     	opts.RetryConfig = &defaultCfg
 	}
 
+	// Check if restart policy exists, failed chains stay down if not specified
+	if opts.RestartPolicy == nil {
+		defaultPolicy := DefaultRestartPolicy()
+		opts.RestartPolicy = &defaultPolicy
+	}
+
+	// Check if metrics exist, report the RPC client to them as well
+	if opts.Metrics == nil {
+		opts.Metrics = nopMetrics{}
+	} else if rpc, ok := chain.RPC.(*HTTPRPC); ok && rpc.metrics == nil {
+		rpc.SetMetrics(opts.Metrics)
+	}
+
+	// Check if a tracer provider exists, use the global one if not specified
+	if opts.TracerProvider == nil {
+		opts.TracerProvider = otel.GetTracerProvider()
+	}
+
+	var state *StateReader
+	if rpc, ok := chain.RPC.(CallRPC); ok {
+		state = NewStateReader(rpc, opts.Multicall)
+		for _, e := range opts.Errors {
+			if err := state.RegisterError(e); err != nil {
+				return err
+			}
+		}
+	}
+
 	chainState := &chainState{
 		chainInfo: chain,
 		opts: opts,
@@ -92,42 +205,136 @@ func (p *Processor) AddChain(chain ChainInfo, opts *Options) error {
 		storedWindowHash: make(map[uint64]string, cap),
 		hardFallbackBlocks: 1000,
 		topics: topics,
+		addresses: addresses,
+		selectors: selectors,
+		callDecoder: callDecoder,
+		state: state,
+		factories: factories,
+		children: make(map[AddressBytes]childState),
+		control: newChainControl(),
+		tracer: opts.TracerProvider.Tracer(TracerName),
 	}
 
 	p.chains[chain.ChainId] = chainState
 	p.logsCh[chain.ChainId] = make(chan Log, opts.LogsBufferSize)
+	if opts.FetchMode == FetchModeTransactions {
+		chainState.callsCh = make(chan Call, opts.LogsBufferSize)
+	}
+
+	// Join the current run, other chains are not touched
+	if p.isRunning {
+		p.startChain(chain.ChainId, chainState)
+	}
 
 	return nil
 }
 
+// RemoveChain stops a chain and removes it from the processor, running or not.
+// The chain commits its in-flight windows and saves its cursor, then its logs channel is closed and its sinks are flushed.
+// When ctx expires first the chain is cancelled and RemoveChain returns the ctx error.
+func (p *Processor) RemoveChain(ctx context.Context, chainId string) error {
+	p.mu.Lock()
+	chain, exists := p.chains[chainId]
+	if !exists {
+		p.mu.Unlock()
+		return fmt.Errorf("chain %s not found", chainId)
+	}
+	logsCh := p.logsCh[chainId]
+	delete(p.chains, chainId)
+	delete(p.logsCh, chainId)
+	cancel, done := chain.cancel, chain.done
+	p.mu.Unlock()
+
+	var err error
+	if done != nil {
+		chain.remove()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			cancel()
+			<-done
+			err = fmt.Errorf("graceful removal of chain %s interrupted: %w", chainId, ctx.Err())
+		}
+	}
+
+	// The chain goroutine exited, nothing sends on the channels anymore
+	close(logsCh)
+	chain.closeSubscriptions()
+	if chain.callsCh != nil {
+		close(chain.callsCh)
+	}
+	if err != nil {
+		return err
+	}
+
+	return p.flushChainSinks(ctx, chain)
+}
+
 func (p *Processor) GetChain(chainId string) ChainInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	return p.chains[chainId].chainInfo
 }
 
 func (p *Processor) Run(ctx context.Context) error{
-	p.isRunning = true
-    defer func() { p.isRunning = false }()
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	g := errgroup.Group{}
+	p.mu.Lock()
+	if p.isRunning {
+		p.mu.Unlock()
+		return fmt.Errorf("processor is already running")
+	}
+	p.isRunning = true
+	p.stopCh = make(chan struct{})
+	p.stopOnce = &sync.Once{}
+	p.cancel = cancel
+	p.runDone = make(chan struct{})
+	runDone := p.runDone
+	p.runCtx = runCtx
+	g := &errgroup.Group{}
+	p.group = g
+	p.active = 0
 	for chainId, chain := range p.chains {
-		id := chainId
-        c := chain
-		ch := p.logsCh[id]
-        
-		g.Go(func () error  {	
-			err := p.runChain(ctx, ch, c)
-			if err != nil {
-                log.Printf("Chain %s stopped: %v", id, err)
-                // Error logged but doesn't stop other chains
-            }
-            return err  
-		})
-
+		p.startChain(chainId, chain)
 	}
+	if p.active == 0 {
+		p.isRunning = false
+	}
+	p.mu.Unlock()
+
+    defer close(runDone)
 
 	return g.Wait()
 }
 
+// startChain runs a chain in the current run. Must be called with p.mu held.
+func (p *Processor) startChain(id string, c *chainState) {
+	ctx, cancel := context.WithCancel(p.runCtx)
+	done := make(chan struct{})
+	c.cancel = cancel
+	c.done = done
+	ch := p.logsCh[id]
+	p.active++
+
+	p.group.Go(func() error {
+		defer close(done)
+		defer cancel()
+
+		// Errors are reported by the supervisor and don't stop other chains
+		err := p.supervise(ctx, ch, c)
+
+		// The run ends with its last chain, chains added after that start a new run
+		p.mu.Lock()
+		p.active--
+		if p.active == 0 {
+			p.isRunning = false
+		}
+		p.mu.Unlock()
+		return err
+	})
+}
+
 // return the read-only channel
 func (p *Processor) Logs(chainId string) (<-chan Log, error) {
 	p.mu.RLock()
@@ -137,12 +344,50 @@ func (p *Processor) Logs(chainId string) (<-chan Log, error) {
     if !exists {
         return nil, fmt.Errorf("chain %s not found", chainId)
     }
+    p.chains[chainId].logsClaimed.Store(true)
     return ch, nil
 }
 
 func (p *Processor) runChain(ctx context.Context, logsCh chan Log, chain *chainState) error {
+	if err := p.loadCursor(ctx, chain); err != nil {
+		return err
+	}
+	chain.opts.Metrics.SetCursor(chain.chainInfo.ChainId, chain.cursor)
+
 outer:
 	for {		
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// Graceful stop, every planned window was committed by now
+		if p.stopping() || chain.removing() {
+			return p.saveCursor(ctx, chain, false)
+		}
+
+		// A bounded backfill ends once EndBlock is committed, Run returns after its last chain
+		if chain.opts.EndBlock > 0 && chain.cursor >= chain.opts.EndBlock {
+			if err := p.saveCursor(ctx, chain, false); err != nil {
+				return err
+			}
+			return p.flushChainSinks(ctx, chain)
+		}
+
+		// Wait while paused
+		if resume := chain.waitResume(); resume != nil {
+			chain.setStatus(ChainPaused)
+			if err := p.saveCursor(ctx, chain, false); err != nil {
+				return err
+			}
+			select {
+			case <-resume:
+			case <-p.stopSignal():
+			case <-chain.removeSignal():
+			case <-ctx.Done():
+			}
+			continue outer
+		}
+
 		rpcCtx, rpcCancel := context.WithCancel(ctx)
 
 		// compute for new head
@@ -163,6 +408,7 @@ outer:
 			rpcCancel()
 			return err
 		}
+		chain.opts.Metrics.SetHead(chain.chainInfo.ChainId, head)
 
 		// look for block confimation
 		var conf uint64
@@ -175,6 +421,15 @@ outer:
 		if head > conf {
 			target = head - conf
 		}
+		if chain.opts.EndBlock > 0 && target > chain.opts.EndBlock {
+			target = chain.opts.EndBlock
+		}
+
+		if target > chain.cursor + uint64(chain.opts.RangeSize) {
+			chain.setStatus(ChainBackfilling)
+		} else {
+			chain.setStatus(ChainAtTip)
+		}
 
 		n := chain.opts.FetcherConcurrency
 		if n <= 0 {
@@ -187,6 +442,9 @@ outer:
 			to uint64
 		}
 		jobs := make(chan blockRange ,n)
+		pauseCh := chain.pauseSignal()
+		stopCh := p.stopSignal()
+		removeCh := chain.removeSignal()
 		go func() {
 			defer close(jobs)
 			rs := uint64(chain.opts.RangeSize)
@@ -197,9 +455,26 @@ outer:
 					to = target
 				}
 
+				// Stop planning on pause or stop, planned windows are still committed
+				select {
+				case <-pauseCh:
+					return
+				case <-stopCh:
+					return
+				case <-removeCh:
+					return
+				default:
+				}
+
 				select {
 				case <-rpcCtx.Done():
 					return
+				case <-pauseCh:
+					return
+				case <-stopCh:
+					return
+				case <-removeCh:
+					return
 				case jobs <- blockRange{from, to}:
 				//log.Printf("planned job from block %d to block %d...\n", from, to)
 				}
@@ -214,7 +489,12 @@ outer:
 		type doneMsg struct {
 			from uint64
 			to uint64
-			logs []Log
+			logs []fetchedLog
+			calls []Call
+			factory *factoryWindow
+			// ctx carries the window span
+			ctx context.Context
+			span trace.Span
 		}
 		
 		doneCh := make(chan doneMsg, n)
@@ -223,25 +503,58 @@ outer:
 			go func(){
 				defer wg.Done()
 				for job := range jobs {
-					var logs []Log
+					var logs []fetchedLog
+					var calls []Call
+					var fw *factoryWindow
 					var err error
-					err = RetryWithBackoff(rpcCtx, *chain.opts.RetryConfig, func() error {	
-						switch chain.opts.FetchMode {
+					// The window span lasts until the arbiter commits or drops the window
+					wctx, span := chain.startSpan(rpcCtx, "indexer.window", AttrFromBlock.Int64(int64(job.from)), AttrToBlock.Int64(int64(job.to)))
+					fctx, fetchSpan := chain.startSpan(wctx, "indexer.fetch")
+					err = RetryWithBackoff(fctx, *chain.opts.RetryConfig, func() error {	
+						var headerCache map[uint64]Block
+						var receiptCache map[uint64][]Receipt
+						if chain.enrich() {
+							headerCache = make(map[uint64]Block)
+							receiptCache = make(map[uint64][]Receipt)
+						}
+
+						fetchMode := chain.opts.FetchMode
+						if !chain.staticSources() {
+							// Only factory sources
+							fetchMode = ""
+						}
+
+						switch fetchMode {
 						case FetchModeLogs:
-							filter := Filter{
-								FromBlock: Uint64ToHexQty(job.from),
-								ToBlock: Uint64ToHexQty(job.to),
-								Topics: chain.topics,
+							var raw []Log
+							raw, err = chain.chainInfo.RPC.GetLogs(fctx, chain.logsFilter(job.from, job.to))
+							if err == nil {
+								logs, err = parseLogs(raw)
+							}
+							if err == nil && chain.opts.VerifyIntegrity {
+								err = p.verifyLogsWindow(fctx, job.from, job.to, logs, chain)
 							}
-							logs, err = chain.chainInfo.RPC.GetLogs(rpcCtx, filter)
 
 						case FetchModeReceipts:
-							logs, err = p.fetchLogsFromReceipts(rpcCtx, job.from, job.to, chain)
+							logs, err = p.fetchLogsFromReceipts(fctx, job.from, job.to, chain, headerCache, receiptCache)
+
+						case FetchModeTransactions:
+							calls, err = p.fetchCalls(fctx, job.from, job.to, chain)
+						}
+
+						if err == nil && chain.enrich() {
+							err = p.enrichLogs(fctx, logs, chain, headerCache, receiptCache)
+						}
+
+						if err == nil && len(chain.factories) > 0 {
+							fw, err = p.fetchFactoryWindow(fctx, job.from, job.to, chain)
 						}
 
 						return err
 					})
+					endSpan(fetchSpan, err)
 						if err != nil {
+							endSpan(span, err)
 							log.Println("Error fetching logs: ", err)
 							select {
 							case errCh <- err:
@@ -253,8 +566,9 @@ outer:
 						//log.Printf("Here")
 						select {
 							case <-rpcCtx.Done():
+								span.End()
 								return
-							case doneCh <- doneMsg{from: job.from, to: job.to, logs: logs}:
+							case doneCh <- doneMsg{from: job.from, to: job.to, logs: logs, calls: calls, factory: fw, ctx: wctx, span: span}:
 								//log.Printf("sending log to arbiter from block %d to block %d...\n", job.from, job.to)
 						}
 			
@@ -275,9 +589,23 @@ outer:
 		go func() {
 			defer close(arbiterDone)
 			window := make(map[uint64]uint64)
-			windowLogs:= make(map[uint64][]Log)
+			windowLogs:= make(map[uint64][]fetchedLog)
+			windowCalls := make(map[uint64][]Call)
+			windowFactory := make(map[uint64]*factoryWindow)
+			windowCtx := make(map[uint64]context.Context)
+			windowSpan := make(map[uint64]trace.Span)
 			next := chain.cursor + 1
 
+			// End the spans of the windows left uncommitted, the workers exit once rpcCtx is cancelled
+			defer func() {
+				for _, span := range windowSpan {
+					span.End()
+				}
+				for dm := range doneCh {
+					dm.span.End()
+				}
+			}()
+
 			for {
 				select {
 				case <-rpcCtx.Done():
@@ -287,14 +615,20 @@ outer:
 					
 					window[dm.from] = dm.to
 					windowLogs[dm.from] = dm.logs
+					windowCalls[dm.from] = dm.calls
+					windowFactory[dm.from] = dm.factory
+					windowCtx[dm.from] = dm.ctx
+					windowSpan[dm.from] = dm.span
 
 					for end, ok2 := window[next]; ok2; end, ok2 = window[next] {
+						// wctx is rpcCtx with the window span
+						wctx, span := windowCtx[next], windowSpan[next]
 						
 						// Get start window blockhash and compare it with the stored blockhash
 						var block Block
-						err := RetryWithBackoff(ctx, *chain.opts.RetryConfig, func() error {
+						err := RetryWithBackoff(trace.ContextWithSpan(ctx, span), *chain.opts.RetryConfig, func() error {
 							var err error
-							block, err = chain.chainInfo.RPC.GetBlock(rpcCtx, Uint64ToHexQty(next))
+							block, err = chain.chainInfo.RPC.GetBlock(wctx, Uint64ToHexQty(next))
 							return err
 						})
 
@@ -321,43 +655,67 @@ outer:
 							log.Println("Hash mismatch, reorg happened...")
 							rpcCancel()
 							ancestor := p.handleReorg(ctx, chain)
+							span.AddEvent("reorg", trace.WithAttributes(attribute.Int64("indexer.ancestor", int64(ancestor))))
+							chain.opts.Metrics.Reorg(chain.chainInfo.ChainId, chain.cursor - ancestor)
 
 							chain.cursor = ancestor
+							chain.opts.Metrics.SetCursor(chain.chainInfo.ChainId, ancestor)
+							chain.dropChildrenAfter(ancestor)
+
+							// Sinks and the stored cursor must not keep data from the orphaned branch
+							err := p.rollbackSinks(ctx, chain, ancestor)
+							if err == nil {
+								err = p.saveCursor(ctx, chain, false)
+							}
+							if err != nil {
+								select { case errCh <- err: default: }
+							}
 							return
 
 						} else {
-							log.Printf("Processed log from block %d to block %d...\n", next, end)
-							// Commit logs to log channel
-							if logs := windowLogs[next]; len(logs) > 0 {
-								for _, l:= range logs {
-								select {
-								case <-rpcCtx.Done():
+							// Get the end block blockhash before committing, sinks persist it with the cursor
+							err = RetryWithBackoff(trace.ContextWithSpan(ctx, span), *chain.opts.RetryConfig, func() error {
+								var err error
+								block, err = chain.chainInfo.RPC.GetBlock(wctx, Uint64ToHexQty(end))
+								return err
+							})
+							if err != nil {
+								if rpcCtx.Err() != nil { return }        // batch was canceled; ignore
+								log.Println("Error getting window end block: ", err)
+								select { case errCh <- err: default: }
+								return
+							}
+
+							p.storeWindowHash(end, block.Hash, chain)
+
+							// Register new factory children in block order, and backfill the ones the worker missed
+							logs := windowLogs[next]
+							if fw := windowFactory[next]; fw != nil {
+								logs, err = p.resolveFactoryWindow(wctx, next, end, chain, logs, fw)
+								if err != nil {
+									if rpcCtx.Err() != nil { return }
+									select { case errCh <- err: default: }
 									return
-								case logsCh <- l:
-								}
 								}
 							}
+
+							err = p.commitWindow(wctx, chain, logsCh, next, end, logs, windowCalls[next])
+							endSpan(span, err)
+							delete(windowSpan, next)
+							if err != nil {
+								if rpcCtx.Err() != nil { return }
+								select { case errCh <- err: default: }
+								return
+							}
+							log.Printf("Processed log from block %d to block %d...\n", next, end)
 							
 							delete(windowLogs, next)
+							delete(windowCalls, next)
+							delete(windowFactory, next)
+							delete(windowCtx, next)
 							delete(window, next)	
-							chain.cursor = end
 							next = end + 1
 						}
-						
-						// Get the end block blockhash after committing
-						err = RetryWithBackoff(ctx, *chain.opts.RetryConfig, func() error {
-							var err error
-							block, err = chain.chainInfo.RPC.GetBlock(rpcCtx, Uint64ToHexQty(end))
-							return err
-						})
-						if err != nil {
-							if rpcCtx.Err() != nil { return }        // batch was canceled; ignore
-							log.Println("Error getting window end block: ", err)
-							select { case errCh <- err: default: }
-							return
-						}
-
-						p.storeWindowHash(end, block.Hash, chain)
 					}
 				}
 			}
@@ -369,9 +727,22 @@ outer:
 			case <-rpcCtx.Done():
 				<- done
 				<- arbiterDone
+				// A failed sink rollback has to stop the chain
+				select {
+				case err := <-errCh:
+					return err
+				default:
+				}
 				continue outer
 			case <-done:
 				<- arbiterDone
+				rpcCancel()
+				// The arbiter may have failed the last window after the workers finished
+				select {
+				case err := <-errCh:
+					return err
+				default:
+				}
 				continue outer
 			case err := <-errCh:
 				log.Println("Error received cancelling context")
@@ -390,6 +761,76 @@ outer:
 	}
 }
 
+// commitWindow writes a window to the sinks, emits its logs and calls and advances the cursor.
+func (p *Processor) commitWindow(ctx context.Context, chain *chainState, logsCh chan Log, from uint64, to uint64, logs []fetchedLog, calls []Call) error {
+	batch := Batch{
+		ChainId: chain.chainInfo.ChainId,
+		FromBlock: from,
+		ToBlock: to,
+		Logs: wireLogs(logs),
+		Calls: calls,
+		Cursor: chain.cursorAt(to),
+	}
+
+	// Handlers run first so sinks and the cursor never get ahead of them
+	hctx, span := chain.startSpan(ctx, "indexer.handlers", AttrLogs.Int(len(logs)))
+	consumed, err := p.runHandlers(hctx, chain, from, to, logs)
+	endSpan(span, err)
+	if err != nil {
+		return err
+	}
+
+	if err := p.writeSinks(ctx, chain, batch); err != nil {
+		return err
+	}
+
+	// Commit logs to log channel and subscriptions, the span shows the backpressure of their readers
+	_, span = chain.startSpan(ctx, "indexer.emit")
+	emitted, err := p.emitLogs(ctx, chain, logsCh, logs, consumed)
+	span.SetAttributes(AttrLogs.Int(emitted))
+	endSpan(span, err)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range calls {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case chain.callsCh <- c:
+		}
+	}
+
+	chain.cursor = to
+	chain.opts.Metrics.WindowCommitted(chain.chainInfo.ChainId, emitted)
+	chain.opts.Metrics.SetCursor(chain.chainInfo.ChainId, to)
+	return p.saveCursor(ctx, chain, true)
+}
+
+// emitLogs sends the logs the handlers didn't consume to the subscriptions and the logs channel, and returns how many were sent.
+func (p *Processor) emitLogs(ctx context.Context, chain *chainState, logsCh chan Log, logs []fetchedLog, consumed []bool) (int, error) {
+	feedLogs := chain.logsClaimed.Load() || !chain.subscribed.Load()
+	emitted := 0
+	for i, l := range logs {
+		if consumed[i] {
+			continue
+		}
+		emitted++
+		if err := chain.publish(ctx, l); err != nil {
+			return emitted, err
+		}
+		if !feedLogs {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return emitted, ctx.Err()
+		case logsCh <- l.Log:
+		}
+	}
+	return emitted, nil
+}
+
 // During ancestor lookup we start from the cursor window and get to the window head and compare to the previous window
 func (p *Processor) handleReorg(ctx context.Context, chain *chainState) uint64 {
 	ancestor := chain.cursor
@@ -399,6 +840,7 @@ func (p *Processor) handleReorg(ctx context.Context, chain *chainState) uint64 {
 
 		windowHeadBlock, err := chain.chainInfo.RPC.GetBlock(ctx, Uint64ToHexQty(ancestor + 1))
 		if err != nil {
+			chain.opts.Metrics.ReorgFallback(chain.chainInfo.ChainId)
 			return fallback
 		}
 		
@@ -416,12 +858,14 @@ func (p *Processor) handleReorg(ctx context.Context, chain *chainState) uint64 {
 
 		select{
 		case<- ctx.Done():
+			chain.opts.Metrics.ReorgFallback(chain.chainInfo.ChainId)
 			return fallback
 		default:
 		}
 	}
 	fallback := chain.cursor; if fallback > chain.hardFallbackBlocks { fallback -= chain.hardFallbackBlocks } else { fallback = 0 }
 	log.Println("Hard fallback triggered...")
+	chain.opts.Metrics.ReorgFallback(chain.chainInfo.ChainId)
 	if fallback <= 0 {
 		fallback = 0
 	}
@@ -458,30 +902,128 @@ func (p *Processor) dropWindowHash(after uint64, chain *chainState) {
 }
 
 // Helper function to get logs from receipts
-func(p *Processor) fetchLogsFromReceipts(ctx context.Context, from uint64, to uint64, chain *chainState) ([]Log, error){
-	var allLogs []Log
+// When non-nil, headers and receipts are filled with what was fetched so enrichment can reuse them.
+func(p *Processor) fetchLogsFromReceipts(ctx context.Context, from uint64, to uint64, chain *chainState, headerCache map[uint64]Block, receiptCache map[uint64][]Receipt) ([]fetchedLog, error){
+	var allLogs []fetchedLog
+
+	// Headers are needed upfront to skip blocks by bloom, otherwise only fetch them for verification
+	var headers []Block
+	if chain.opts.BloomPrescreen {
+		var err error
+		headers, err = p.getHeaders(ctx, from, to, chain)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	for blockNum := from; blockNum <= to; blockNum ++ {
 		s_blockNum := Uint64ToHexQty(blockNum)
+
+		var block Block
+		if headers != nil {
+			block = headers[blockNum - from]
+			// Headers with a malformed bloom are fetched anyway
+			if bloom, err := ParseBloom(block.LogsBloom); err == nil && !p.bloomMatchesFilter(bloom, chain) {
+				continue
+			}
+		}
+
 		receipts, err := chain.chainInfo.RPC.GetBlockReceipts(ctx, s_blockNum)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get receipts for block %d: %w", blockNum, err)
 		}
 
+		if chain.opts.VerifyIntegrity {
+			if headers == nil {
+				block, err = chain.chainInfo.RPC.GetBlock(ctx, s_blockNum)
+				if err != nil {
+					return nil, fmt.Errorf("failed to get block %d: %w", blockNum, err)
+				}
+			}
+			if err := VerifyReceipts(block, receipts); err != nil {
+				return nil, err
+			}
+		}
+
+		matched := false
 		for _, receipt := range receipts {
-			for _, log := range receipt.Logs {
-				if p.matchesTopicFilter(log, chain) {
+			logs, err := parseLogs(receipt.Logs)
+			if err != nil {
+				return nil, fmt.Errorf("invalid receipt %s: %w", receipt.TransactionHash, err)
+			}
+			for _, log := range logs {
+				if p.matchesTopicFilter(log.typed, chain) && p.matchesAddressFilter(log.typed, chain) {
                     allLogs = append(allLogs, log)
+					matched = true
                 }
 			}
 		}
+
+		if matched && receiptCache != nil {
+			receiptCache[blockNum] = receipts
+		}
+		if matched && headerCache != nil && block.Number != "" {
+			headerCache[blockNum] = block
+		}
 	}
 	return allLogs, nil
 }
 
+// getHeaders fetches the headers of blocks [from..to], in one batch when the RPC supports it.
+func (p *Processor) getHeaders(ctx context.Context, from uint64, to uint64, chain *chainState) ([]Block, error) {
+	numbers := make([]uint64, 0, to-from+1)
+	for blockNum := from; blockNum <= to; blockNum++ {
+		numbers = append(numbers, blockNum)
+	}
+	return p.getHeadersByNumber(ctx, numbers, chain)
+}
+
+// getHeadersByNumber fetches the headers of the given blocks, in one batch when the RPC supports it.
+func (p *Processor) getHeadersByNumber(ctx context.Context, blockNums []uint64, chain *chainState) ([]Block, error) {
+	numbers := make([]string, len(blockNums))
+	for i, blockNum := range blockNums {
+		numbers[i] = Uint64ToHexQty(blockNum)
+	}
+
+	if batch, ok := chain.chainInfo.RPC.(BatchRPC); ok {
+		headers, err := batch.GetBlocks(ctx, numbers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get headers for %d blocks: %w", len(numbers), err)
+		}
+		return headers, nil
+	}
+
+	headers := make([]Block, len(numbers))
+	for i, number := range numbers {
+		block, err := chain.chainInfo.RPC.GetBlock(ctx, number)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get block %d: %w", blockNums[i], err)
+		}
+		headers[i] = block
+	}
+	return headers, nil
+}
+
 // Checks if a log matches the configurated topic
-func(p *Processor) matchesTopicFilter(log Log, chain *chainState) bool {
+// logsFilter is the eth_getLogs filter of a window.
+// Nodes AND the topics by position, so several topic0s are sent as alternatives of the first position.
+func (c *chainState) logsFilter(from uint64, to uint64) Filter {
+	filter := Filter{
+		FromBlock: Uint64ToHexQty(from),
+		ToBlock: Uint64ToHexQty(to),
+		Address: hexStrings(c.addresses),
+	}
+	if len(c.topics) > 1 {
+		filter.TopicSets = TopicFilter{c.topics}
+	} else {
+		filter.Topics = hexStrings(c.topics)
+	}
+	return filter
+}
+
+func(p *Processor) matchesTopicFilter(log TypedLog, chain *chainState) bool {
 	// If there is no topic specified then its true by default
-	if len(chain.opts.Topics) == 0 {
+	if len(chain.topics) == 0 {
 		return true
 	}
 
@@ -492,19 +1034,107 @@ func(p *Processor) matchesTopicFilter(log Log, chain *chainState) bool {
 
 	// Match first topic (event signature)
     for _, filterTopic := range chain.topics {
-        if len(log.Topics) > 0 {
-            if logTopic, ok := log.Topics[0].(string); ok {
-                if logTopic == filterTopic {
-                    return true
-                }
-            }
-        }
+		if log.Topics[0] == filterTopic {
+			return true
+		}
     }
     
     return false
 }
 
+// Checks if a log was emitted by one of the configured addresses
+func(p *Processor) matchesAddressFilter(log TypedLog, chain *chainState) bool {
+	if len(chain.addresses) == 0 {
+		return true
+	}
 
+	for _, filterAddr := range chain.addresses {
+		if log.Address == filterAddr {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fetchedLog is a log of a window with its parsed form.
+// Logs are parsed once when fetched, the processor filters, orders and dispatches them on the typed form
+// and hands the wire form to sinks, subscribers and the logs channel.
+type fetchedLog struct {
+	Log
+	typed TypedLog
+	// invalid is why the log doesn't parse, such logs are delivered but never decoded
+	invalid error
+}
+
+// parseLogs parses fetched logs, only a log without a valid position fails the fetch.
+func parseLogs(logs []Log) ([]fetchedLog, error) {
+	parsed := make([]fetchedLog, len(logs))
+	for i, l := range logs {
+		tl, err := l.Typed()
+		if err != nil {
+			err = fmt.Errorf("invalid log %s/%s: %w", l.TransactionHash, l.LogIndex, err)
+			var ok bool
+			if tl, ok = parseMalformedLog(l); !ok {
+				return nil, err
+			}
+		}
+		parsed[i] = fetchedLog{Log: l, typed: tl, invalid: err}
+	}
+	return parsed, nil
+}
+
+// parseMalformedLog parses the fields of a malformed log one by one, the invalid ones are left zero so they match nothing.
+// Logs are ordered by their position, it reports false when that is invalid.
+func parseMalformedLog(l Log) (TypedLog, bool) {
+	var p fieldParser
+	tl := TypedLog{
+		BlockNumber: p.qty("blockNumber", l.BlockNumber),
+		LogIndex:    p.qty("logIndex", l.LogIndex),
+		Removed:     l.Removed,
+		Context:     l.Context,
+	}
+	if p.err != nil {
+		return tl, false
+	}
 
+	if addr, err := ParseAddressBytes(l.Address); err == nil {
+		tl.Address = addr
+	}
+	if h, err := ParseHash(l.BlockHash); err == nil {
+		tl.BlockHash = h
+	}
+	if h, err := ParseHash(l.TransactionHash); err == nil {
+		tl.TransactionHash = h
+	}
+	tl.Topics = make([]Hash, len(l.Topics))
+	for i, t := range l.Topics {
+		if s, ok := t.(string); ok {
+			if h, err := ParseHash(s); err == nil {
+				tl.Topics[i] = h
+			}
+		}
+	}
+	return tl, true
+}
 
+// wireLogs returns the wire form of fetched logs.
+func wireLogs(logs []fetchedLog) []Log {
+	if logs == nil {
+		return nil
+	}
+	wire := make([]Log, len(logs))
+	for i, l := range logs {
+		wire[i] = l.Log
+	}
+	return wire
+}
 
+// hexStrings formats typed values back to their wire form
+func hexStrings[T interface{ Hex() string }](values []T) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = v.Hex()
+	}
+	return out
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
@@ -1116,9 +1117,275 @@ func TestMultiChain_AddChainWhileRunning(t *testing.T) {
     go processor.Run(ctx)
     time.Sleep(100 * time.Millisecond) // Let it start
     
-    // Try to add chain while running
-    err := processor.AddChain(ChainInfo{ChainId: "137", RPC: NewHTTPRPC(srv.URL, 0)}, opts)
+    // Chains can be added while running, they start right away
+    err := processor.AddChain(ChainInfo{ChainId: "137", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{RangeSize: 2})
+    assert.NoError(t, err)
+    assert.Eventually(t, func() bool {
+        state, _ := processor.State("137")
+        return state == ChainRunning
+    }, time.Second, 10*time.Millisecond)
+
+    // A running chain can't be replaced
+    err = processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, opts)
     assert.Error(t, err)
     assert.Contains(t, err.Error(), "running")
 }
 
+
+func TestRunWithBloomPrescreen_SkipsEmptyBlocks(t *testing.T) {
+	var mu sync.Mutex
+	receiptCalls := map[string]int{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		body, _ := io.ReadAll(r.Body)
+
+		// Header batches
+		if len(body) > 0 && body[0] == '[' {
+			var reqs []struct {
+				ID     uint          `json:"id"`
+				Params []interface{} `json:"params"`
+			}
+			json.Unmarshal(body, &reqs)
+
+			resps := []map[string]any{}
+			for _, req := range reqs {
+				bloom := emptyBloom
+				// Only block 3 contains the transfer
+				if req.Params[0] == "0x3" {
+					bloom = testReceiptBloom
+				}
+				resps = append(resps, map[string]any{
+					"jsonrpc": "2.0",
+					"id":      req.ID,
+					"result": map[string]any{
+						"number":    req.Params[0],
+						"logsBloom": bloom,
+					},
+				})
+			}
+			_ = json.NewEncoder(w).Encode(resps)
+			return
+		}
+
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		json.Unmarshal(body, &req)
+
+		switch req.Method {
+		case "eth_blockNumber":
+			_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": "0x5"})
+
+		case "eth_getBlockByNumber":
+			blockNum, _ := HexQtyToUint64(fmt.Sprintf("%s", req.Params[0]))
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"jsonrpc": "2.0",
+				"id":      1,
+				"result": map[string]any{
+					"number":     req.Params[0],
+					"hash":       req.Params[0],
+					"parentHash": Uint64ToHexQty(blockNum - 1),
+				},
+			})
+
+		case "eth_getBlockReceipts":
+			mu.Lock()
+			receiptCalls[fmt.Sprintf("%s", req.Params[0])]++
+			mu.Unlock()
+			_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": testReceipts()})
+		}
+	}))
+	defer srv.Close()
+
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:          5,
+		FetcherConcurrency: 1,
+		LogsBufferSize:     10,
+		FetchMode:          FetchModeReceipts,
+		BloomPrescreen:     true,
+		Topics:             []string{"Transfer(address,address,uint256)"},
+		Addresses:          []string{"0xA0b86991c6218b36c1d19d4a2e9eb0ce3606eB48"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go processor.Run(ctx)
+
+	logsCh, _ := processor.Logs("1")
+	select {
+	case l := <-logsCh:
+		assert.Equal(t, "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", l.Address)
+	case <-ctx.Done():
+		t.Fatal("Test timeout")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]int{"0x3": 1}, receiptCalls)
+}
+
+func TestRunWithEnrichment_Receipt(t *testing.T) {
+	type rpcReq struct {
+		ID     uint          `json:"id"`
+		Method string        `json:"method"`
+		Params []interface{} `json:"params"`
+	}
+	result := func(req rpcReq) any {
+		switch req.Method {
+		case "eth_blockNumber":
+			return "0x2"
+
+		case "eth_getBlockByNumber":
+			blockNum, _ := HexQtyToUint64(fmt.Sprintf("%s", req.Params[0]))
+			return map[string]any{
+				"number":     req.Params[0],
+				"hash":       req.Params[0],
+				"parentHash": Uint64ToHexQty(blockNum - 1),
+				"timestamp":  Uint64ToHexQty(1700000000 + blockNum*12),
+			}
+
+		case "eth_getLogs":
+			return []map[string]any{
+				{
+					"address":          "0xabcabcabcabcabcabcabcabcabcabcabcabcabca",
+					"topics":           []any{"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"},
+					"data":             "0x",
+					"blockNumber":      "0x2",
+					"transactionHash":  "0x1111111111111111111111111111111111111111111111111111111111111111",
+					"transactionIndex": "0x3",
+					"blockHash":        "0x2222222222222222222222222222222222222222222222222222222222222222",
+					"logIndex":         "0x7",
+				},
+			}
+
+		case "eth_getBlockReceipts":
+			return []map[string]any{
+				{
+					"transactionHash":   "0x1111111111111111111111111111111111111111111111111111111111111111",
+					"from":              "0x5e5e5e5e5e5e5e5e5e5e5e5e5e5e5e5e5e5e5e5e",
+					"to":                "0xabcabcabcabcabcabcabcabcabcabcabcabcabca",
+					"status":            "0x0",
+					"gasUsed":           "0x5208",
+					"effectiveGasPrice": "0x3b9aca00",
+				},
+			}
+		}
+		return nil
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// Headers are requested in batches
+		body, _ := io.ReadAll(r.Body)
+		if len(body) > 0 && body[0] == '[' {
+			var reqs []rpcReq
+			json.Unmarshal(body, &reqs)
+			resps := []map[string]any{}
+			for _, req := range reqs {
+				resps = append(resps, map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result(req)})
+			}
+			_ = json.NewEncoder(w).Encode(resps)
+			return
+		}
+
+		var req rpcReq
+		json.Unmarshal(body, &req)
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result(req)})
+	}))
+	defer srv.Close()
+
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:          2,
+		FetcherConcurrency: 1,
+		LogsBufferSize:     10,
+		Enrichment:         EnrichmentReceipt,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go processor.Run(ctx)
+
+	logsCh, _ := processor.Logs("1")
+	var l Log
+	select {
+	case l = <-logsCh:
+	case <-ctx.Done():
+		t.Fatal("Test timeout")
+	}
+
+	assert.NotNil(t, l.Context)
+	assert.Equal(t, "1", l.Context.ChainId)
+	assert.Equal(t, uint64(2), l.Context.BlockNumber)
+	assert.Equal(t, uint64(3), l.Context.TransactionIndex)
+	assert.Equal(t, uint64(7), l.Context.LogIndex)
+	assert.Equal(t, time.Unix(1700000024, 0).UTC(), l.Context.BlockTimestamp)
+	assert.Equal(t, "0x5e5e5e5e5e5e5e5e5e5e5e5e5e5e5e5e5e5e5e5e", l.Context.From)
+	assert.Equal(t, "0xabcabcabcabcabcabcabcabcabcabcabcabcabca", l.Context.To)
+	assert.False(t, l.Context.Success)
+	assert.Equal(t, uint64(21000), l.Context.GasUsed)
+	assert.Equal(t, "1000000000", l.Context.EffectiveGasPrice.String())
+}
+
+func TestRunWithEnrichment_MalformedLog(t *testing.T) {
+	malformed := factoryTestLog(testPoolA, testTransferTopic, 3, 0, "0x")
+	malformed.Address = "0xabc"
+	srv := newFactoryServer(10, []Log{factoryTestLog(testPoolA, testTransferTopic, 2, 0, "0x"), malformed})
+	defer srv.Close()
+
+	sink := &memorySink{}
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:      5,
+		LogsBufferSize: 16,
+		EndBlock:       5,
+		Enrichment:     EnrichmentBlock,
+		Sinks:          []Sink{sink},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// The window commits, the malformed log is delivered without a context
+	assert.NoError(t, processor.Run(ctx))
+	if assert.Len(t, sink.batches, 1) && assert.Len(t, sink.batches[0].Logs, 2) {
+		assert.NotNil(t, sink.batches[0].Logs[0].Context)
+		assert.Equal(t, "0xabc", sink.batches[0].Logs[1].Address)
+		assert.Nil(t, sink.batches[0].Logs[1].Context)
+	}
+}
+
+func TestParseLogs(t *testing.T) {
+	valid := factoryTestLog(testPoolA, testTransferTopic, 5, 1, "0x")
+	malformed := Log{
+		Address:         "0xabc",
+		Topics:          []any{testTransferTopic, 42},
+		BlockNumber:     "0x6",
+		TransactionHash: "0xth1",
+		LogIndex:        "0x0",
+	}
+
+	logs, err := parseLogs([]Log{valid, malformed})
+	assert.NoError(t, err)
+	if assert.Len(t, logs, 2) {
+		assert.NoError(t, logs[0].invalid)
+		assert.Equal(t, valid, logs[0].Log)
+		assert.Equal(t, uint64(5), logs[0].typed.BlockNumber)
+
+		// Malformed logs keep their wire form and the fields that parse
+		assert.Error(t, logs[1].invalid)
+		assert.Equal(t, malformed, logs[1].Log)
+		assert.Equal(t, uint64(6), logs[1].typed.BlockNumber)
+		assert.Equal(t, AddressBytes{}, logs[1].typed.Address)
+		topic, _ := ParseHash(testTransferTopic)
+		assert.Equal(t, []Hash{topic, {}}, logs[1].typed.Topics)
+	}
+
+	// Logs are ordered by their position
+	_, err = parseLogs([]Log{{Address: testPoolA, BlockNumber: "six", LogIndex: "0x0"}})
+	assert.Error(t, err)
+}
@@ -6,6 +6,8 @@ import (
 	"log"
 	"math/rand"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type RetryConfig struct {
@@ -52,9 +54,12 @@ func RetryWithBackoff(ctx context.Context, config RetryConfig, fn func() error)
 	var lastErr error
 	backoff := config.InitialBackoff
 
+	tracer := childTracer(ctx)
 	for attempt := 0; attempt < config.MaxAttempts; attempt ++ {
-		// Execute function
+		// Execute function, traced as a child of the span in ctx
+		_, span := tracer.Start(ctx, "indexer.retry.attempt", trace.WithAttributes(AttrAttempt.Int(attempt+1)))
 		lastErr = fn()
+		endSpan(span, lastErr)
 
 		// If there is no error return nil
 		if lastErr == nil {
@@ -80,6 +85,7 @@ func RetryWithBackoff(ctx context.Context, config RetryConfig, fn func() error)
 
 		log.Printf("Retry attempt %d/%d failed: %v. Retrying in %v...",
 			attempt+1, config.MaxAttempts, lastErr, wait)
+		reportRetry(lastErr)
 
 		// Wait for context cancellation and backoff
 		select {
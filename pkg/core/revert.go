@@ -0,0 +1,192 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var (
+	revertErrorString = mustParseError("Error(string reason)")
+	revertPanic       = mustParseError("Panic(uint256 code)")
+)
+
+// panicReasons describes the Panic(uint256) codes emitted by the Solidity compiler.
+var panicReasons = map[uint64]string{
+	0x00: "generic compiler panic",
+	0x01: "assertion failed",
+	0x11: "arithmetic overflow or underflow",
+	0x12: "division or modulo by zero",
+	0x21: "invalid enum value",
+	0x22: "invalid storage byte array encoding",
+	0x31: "pop on empty array",
+	0x32: "array index out of bounds",
+	0x41: "out of memory",
+	0x51: "call to zero-initialized function",
+}
+
+// RevertError is decoded revert data of a failed call.
+type RevertError struct {
+	// Error name, "Error" for require and revert messages, "Panic" for compiler checks, empty when unknown
+	Name string
+	// Canonical signature, e.g. "InsufficientBalance(uint256,uint256)", empty when unknown
+	Signature string
+	// Reason of Error(string)
+	Reason string
+	// Code of Panic(uint256)
+	PanicCode *big.Int
+	// Values in declaration order
+	Values []any
+	// Args by parameter name, unnamed parameters are keyed arg0, arg1...
+	Args map[string]any
+	// Raw revert data
+	Data []byte
+	// cause is the error the revert data was read from
+	cause error
+}
+
+func (e *RevertError) Error() string {
+	switch {
+	case e.Name == "Error":
+		return "execution reverted: " + e.Reason
+	case e.Name == "Panic":
+		reason := "unknown panic"
+		if r, ok := panicReasons[e.PanicCode.Uint64()]; ok && e.PanicCode.IsUint64() {
+			reason = r
+		}
+		return fmt.Sprintf("execution reverted: panic 0x%x (%s)", e.PanicCode, reason)
+	case e.Name != "":
+		args := make([]string, len(e.Values))
+		for i, v := range e.Values {
+			args[i] = fmt.Sprint(v)
+		}
+		return fmt.Sprintf("execution reverted: %s(%s)", e.Name, strings.Join(args, ", "))
+	case len(e.Data) > 0:
+		return "execution reverted: 0x" + hex.EncodeToString(e.Data)
+	}
+	return "execution reverted"
+}
+
+func (e *RevertError) Unwrap() error {
+	return e.cause
+}
+
+// RegisterError parses and adds a custom error declaration, see ParseError.
+func (d *Decoder) RegisterError(signature string) (CustomError, error) {
+	e, err := ParseError(signature)
+	if err != nil {
+		return CustomError{}, err
+	}
+	return d.addError(e), nil
+}
+
+func (d *Decoder) addError(e CustomError) CustomError {
+	if registered, ok := d.errors[e.Selector()]; ok {
+		return registered
+	}
+	d.errors[e.Selector()] = e
+	return e
+}
+
+// DecodeRevert decodes revert data with Error(string), Panic(uint256) and the registered custom errors.
+// Data with an unknown selector gives a RevertError without a Name. A nil decoder only knows the builtin errors.
+func (d *Decoder) DecodeRevert(data []byte) (*RevertError, error) {
+	revert := &RevertError{Data: data}
+	if len(data) < 4 {
+		return revert, nil
+	}
+
+	sel := [4]byte(data[:4])
+	var decl CustomError
+	switch {
+	case sel == revertErrorString.Selector():
+		decl = revertErrorString
+	case sel == revertPanic.Selector():
+		decl = revertPanic
+	default:
+		if d == nil {
+			return revert, nil
+		}
+		var ok bool
+		if decl, ok = d.errors[sel]; !ok {
+			return revert, nil
+		}
+	}
+
+	values, err := DecodeArguments(decl.Inputs, data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s revert data: %w", decl.Signature(), err)
+	}
+	revert.Name = decl.Name
+	revert.Signature = decl.Signature()
+	revert.Values = values
+	revert.Args = make(map[string]any, len(values))
+	for i, in := range decl.Inputs {
+		name := in.Name
+		if name == "" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		revert.Args[name] = values[i]
+	}
+	switch decl.Name {
+	case "Error":
+		revert.Reason = values[0].(string)
+	case "Panic":
+		revert.PanicCode = values[0].(*big.Int)
+	}
+	return revert, nil
+}
+
+// RevertFromError decodes the revert data carried by an RPC error, e.g. a failed eth_call.
+// It reports false when err holds no revert data.
+func (d *Decoder) RevertFromError(err error) (*RevertError, bool) {
+	var revert *RevertError
+	if errors.As(err, &revert) {
+		return revert, true
+	}
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		return nil, false
+	}
+	data, ok := rpcErr.RevertData()
+	if !ok {
+		return nil, false
+	}
+	revert, decodeErr := d.DecodeRevert(data)
+	if decodeErr != nil {
+		// Keep the raw data when it doesn't match its declaration
+		revert = &RevertError{Data: data}
+	}
+	revert.cause = err
+	return revert, true
+}
+
+// RevertData returns the revert data of the error. Nodes put it in data as a hex string,
+// some providers nest it in an object.
+func (e *RPCError) RevertData() ([]byte, bool) {
+	return revertData(e.Data)
+}
+
+func revertData(data any) ([]byte, bool) {
+	switch x := data.(type) {
+	case string:
+		if !strings.HasPrefix(x, "0x") {
+			return nil, false
+		}
+		b, err := hexToBytes(x)
+		return b, err == nil
+	case map[string]any:
+		return revertData(x["data"])
+	}
+	return nil, false
+}
+
+func mustParseError(signature string) CustomError {
+	e, err := ParseError(signature)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// Error("Not enough")
+	errorStringData = "0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"000000000000000000000000000000000000000000000000000000000000000a" +
+		"4e6f7420656e6f75676800000000000000000000000000000000000000000000"
+	// Panic(0x11)
+	panicData = "0x4e487b710000000000000000000000000000000000000000000000000000000000000011"
+)
+
+// insufficientBalanceData encodes InsufficientBalance(uint256 available, uint256 required).
+func insufficientBalanceData(available, required uint64) string {
+	e, _ := ParseError("InsufficientBalance(uint256 available, uint256 required)")
+	sel := e.Selector()
+	return "0x" + hex.EncodeToString(sel[:]) + fmt.Sprintf("%064x%064x", available, required)
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hexToBytes(s)
+	assert.NoError(t, err)
+	return b
+}
+
+func TestDecoder_DecodeRevert(t *testing.T) {
+	decoder := NewDecoder()
+	_, err := decoder.RegisterError("error InsufficientBalance(uint256 available, uint256 required)")
+	assert.NoError(t, err)
+
+	revert, err := decoder.DecodeRevert(mustHex(t, errorStringData))
+	assert.NoError(t, err)
+	assert.Equal(t, "Error", revert.Name)
+	assert.Equal(t, "Not enough", revert.Reason)
+	assert.Equal(t, "execution reverted: Not enough", revert.Error())
+
+	revert, err = decoder.DecodeRevert(mustHex(t, panicData))
+	assert.NoError(t, err)
+	assert.Equal(t, "Panic", revert.Name)
+	assert.Equal(t, big.NewInt(0x11), revert.PanicCode)
+	assert.Equal(t, "execution reverted: panic 0x11 (arithmetic overflow or underflow)", revert.Error())
+
+	revert, err = decoder.DecodeRevert(mustHex(t, insufficientBalanceData(5, 9)))
+	assert.NoError(t, err)
+	assert.Equal(t, "InsufficientBalance", revert.Name)
+	assert.Equal(t, "InsufficientBalance(uint256,uint256)", revert.Signature)
+	assert.Equal(t, big.NewInt(5), revert.Args["available"])
+	assert.Equal(t, big.NewInt(9), revert.Args["required"])
+	assert.Equal(t, "execution reverted: InsufficientBalance(5, 9)", revert.Error())
+
+	// Unknown selector keeps the raw data
+	revert, err = NewDecoder().DecodeRevert(mustHex(t, insufficientBalanceData(5, 9)))
+	assert.NoError(t, err)
+	assert.Empty(t, revert.Name)
+	assert.Len(t, revert.Data, 68)
+
+	// Builtin errors decode without a decoder
+	var none *Decoder
+	revert, err = none.DecodeRevert(mustHex(t, panicData))
+	assert.NoError(t, err)
+	assert.Equal(t, "Panic", revert.Name)
+
+	// Truncated data
+	_, err = decoder.DecodeRevert(mustHex(t, errorStringData[:80]))
+	assert.Error(t, err)
+}
+
+func TestDecoder_RevertFromError(t *testing.T) {
+	decoder := NewDecoder()
+
+	revert, ok := decoder.RevertFromError(fmt.Errorf("wrapped: %w", &RPCError{Code: 3, Message: "execution reverted", Data: errorStringData}))
+	if assert.True(t, ok) {
+		assert.Equal(t, "Not enough", revert.Reason)
+		var rpcErr *RPCError
+		assert.True(t, errors.As(revert, &rpcErr))
+		assert.Equal(t, 3, rpcErr.Code)
+	}
+
+	// Some providers nest the data
+	revert, ok = decoder.RevertFromError(&RPCError{Code: -32000, Message: "execution reverted", Data: map[string]any{"data": panicData}})
+	if assert.True(t, ok) {
+		assert.Equal(t, "Panic", revert.Name)
+	}
+
+	_, ok = decoder.RevertFromError(&RPCError{Code: -32000, Message: "header not found"})
+	assert.False(t, ok)
+	_, ok = decoder.RevertFromError(errors.New("timeout"))
+	assert.False(t, ok)
+}
+
+func TestStateReader_CallRevert(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"error":{"code":3,"message":"execution reverted","data":%q}}`, insufficientBalanceData(1, 2))
+	}))
+	defer srv.Close()
+
+	reader := NewStateReader(NewHTTPRPC(srv.URL, 0), "")
+	assert.NoError(t, reader.RegisterError("InsufficientBalance(uint256 available, uint256 required)"))
+
+	_, err := reader.Call(context.Background(), BlockRef{Number: 1}, testToken, "balanceOf(address) view returns (uint256)", testSender)
+	var revert *RevertError
+	if assert.ErrorAs(t, err, &revert) {
+		assert.Equal(t, "InsufficientBalance", revert.Name)
+		assert.Equal(t, big.NewInt(2), revert.Args["required"])
+	}
+	// The RPC error, and its data, stay reachable
+	var rpcErr *RPCError
+	if assert.ErrorAs(t, err, &rpcErr) {
+		assert.Equal(t, insufficientBalanceData(1, 2), rpcErr.Data)
+	}
+}
+
+func TestTransactions_RevertReasons(t *testing.T) {
+	srv := newTransactionServer(4, []testTx{
+		{block: 2, to: testToken, input: transferInput(testSender, 7), failed: true, output: insufficientBalanceData(3, 7)},
+		{block: 3, to: testRouter, input: "0x38ed1739", calls: []map[string]any{
+			{"type": "CALL", "from": testRouter, "to": testToken, "input": transferInput(testSender, 1), "error": "execution reverted", "output": errorStringData},
+		}},
+	})
+	defer srv.Close()
+
+	processor := NewProcessor()
+	err := processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:      2,
+		LogsBufferSize: 10,
+		FetchMode:      FetchModeTransactions,
+		Addresses:      []string{testToken},
+		Traces:         TraceDebug,
+		Errors:         []string{"error InsufficientBalance(uint256 available, uint256 required)"},
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go processor.Run(ctx)
+
+	callsCh, _ := processor.Calls("1")
+	got := collectCalls(t, ctx, callsCh, 2)
+	assert.NoError(t, processor.Stop(ctx))
+
+	if assert.NotNil(t, got[0].Revert) {
+		assert.Equal(t, "InsufficientBalance", got[0].Revert.Name)
+		assert.Equal(t, big.NewInt(3), got[0].Revert.Args["available"])
+	}
+	assert.Equal(t, []int{0}, got[1].TraceAddress)
+	if assert.NotNil(t, got[1].Revert) {
+		assert.Equal(t, "Not enough", got[1].Revert.Reason)
+	}
+
+	err = processor.AddChain(ChainInfo{ChainId: "2", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize: 2,
+		FetchMode: FetchModeTransactions,
+		Errors:    []string{"error Broken(uint256"},
+	})
+	assert.True(t, err != nil && strings.Contains(err.Error(), "Broken"), err)
+}
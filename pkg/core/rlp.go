@@ -0,0 +1,73 @@
+package core
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Minimal RLP encoder used to rebuild consensus objects (receipts, trie nodes)
+// from their JSON-RPC form. Only encoding is supported, items are passed around
+// already encoded so lists can nest raw encodings (e.g. embedded trie nodes).
+
+// rlpEncodeBytes encodes b as an RLP string.
+func rlpEncodeBytes(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return []byte{b[0]}
+	}
+	return append(rlpHeader(0x80, len(b)), b...)
+}
+
+// rlpEncodeList wraps already encoded items into an RLP list.
+func rlpEncodeList(items ...[]byte) []byte {
+	size := 0
+	for _, item := range items {
+		size += len(item)
+	}
+	out := rlpHeader(0xc0, size)
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+// rlpEncodeUint encodes n as a big endian integer without leading zeroes.
+func rlpEncodeUint(n uint64) []byte {
+	if n == 0 {
+		return rlpEncodeBytes(nil)
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], n)
+	i := 0
+	for buf[i] == 0 {
+		i++
+	}
+	return rlpEncodeBytes(buf[i:])
+}
+
+func rlpHeader(offset byte, size int) []byte {
+	if size < 56 {
+		return []byte{offset + byte(size)}
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(size))
+	i := 0
+	for buf[i] == 0 {
+		i++
+	}
+	return append([]byte{offset + 55 + byte(8-i)}, buf[i:]...)
+}
+
+// hexToBytes decodes a 0x-prefixed hex DATA string. Odd length input is left padded.
+func hexToBytes(s string) ([]byte, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex data %q: %w", s, err)
+	}
+	return b, nil
+}
@@ -16,3 +16,30 @@ type RPC interface {
 	// Get block receipt for the current block number
 	GetBlockReceipts(ctx context.Context, blockNumber string) ([]Receipt, error)
 }
+
+// BatchRPC is implemented by clients able to send JSON-RPC batch requests.
+// The processor uses it when available and falls back to one call per block otherwise.
+type BatchRPC interface {
+	// Get block headers for several block numbers in a single round-trip.
+	// Blocks are returned in the same order as blockNumbers.
+	GetBlocks(ctx context.Context, blockNumbers []string) ([]Block, error)
+}
+
+// TransactionRPC is implemented by clients able to return blocks with their transactions.
+// It is required by the transactions fetch mode.
+type TransactionRPC interface {
+	// Get the block for the block number with full transaction objects
+	GetFullBlock(ctx context.Context, blockNumber string) (FullBlock, error)
+}
+
+// TraceRPC is implemented by clients able to trace the internal calls of a block.
+type TraceRPC interface {
+	// Get the call traces of every transaction of the block, transactions in block order and calls depth first
+	TraceBlock(ctx context.Context, blockNumber string, method TraceMethod) ([]Trace, error)
+}
+
+// CallRPC is implemented by clients able to execute eth_call, used to read contract state.
+type CallRPC interface {
+	// Execute a message call against the state at block, returns the hex return data
+	Call(ctx context.Context, msg CallMsg, block BlockRef) (string, error)
+}
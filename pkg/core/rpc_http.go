@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 )
@@ -17,6 +18,8 @@ type HTTPRPC struct{
 	rateLimit uint16
 	// http client
 	client *http.Client
+	// metrics receives the requests, nil disables reporting
+	metrics Metrics
 }
 
 
@@ -40,7 +43,13 @@ func NewHTTPRPC(endpoint string, rateLimit uint16) *HTTPRPC {
 	}
 }
 
-func(r *HTTPRPC) Head(ctx context.Context) (string, error) {
+func(r *HTTPRPC) Head(ctx context.Context) (head string, err error) {
+	ctx, span := r.startRPCSpan(ctx, "eth_blockNumber")
+	defer func(start time.Time) {
+		endSpan(span, err)
+		err = r.observe("eth_blockNumber", start, err)
+	}(time.Now())
+
 	body := map[string]interface{} {
 		"jsonrpc": "2.0",
 		"id": 1,
@@ -87,6 +96,7 @@ func(r *HTTPRPC) Head(ctx context.Context) (string, error) {
 		return "", &RPCError{
 			Code: resp.Error.Code,
 			Message: resp.Error.Message,
+			Data: resp.Error.Data,
 		}
 	}
 
@@ -95,7 +105,13 @@ func(r *HTTPRPC) Head(ctx context.Context) (string, error) {
 }
 
 // GetBlock returns the block header for now (second params is set to false)
-func(r *HTTPRPC) GetBlock(ctx context.Context, blockNumber string) (Block, error) {
+func(r *HTTPRPC) GetBlock(ctx context.Context, blockNumber string) (block Block, err error) {
+	ctx, span := r.startRPCSpan(ctx, "eth_getBlockByNumber")
+	defer func(start time.Time) {
+		endSpan(span, err)
+		err = r.observe("eth_getBlockByNumber", start, err)
+	}(time.Now())
+
 	body := map[string]interface{} {
 		"jsonrpc": "2.0",
 		"id": 1,
@@ -143,13 +159,20 @@ func(r *HTTPRPC) GetBlock(ctx context.Context, blockNumber string) (Block, error
 		return Block{}, &RPCError{
 			Code: resp.Error.Code,
 			Message: resp.Error.Message,
+			Data: resp.Error.Data,
 		}
 	}
 
 	return resp.Result, nil
 }
 
-func(r *HTTPRPC) GetLogs(ctx context.Context, filter Filter) ([]Log, error) {
+func(r *HTTPRPC) GetLogs(ctx context.Context, filter Filter) (logs []Log, err error) {
+	ctx, span := r.startRPCSpan(ctx, "eth_getLogs")
+	defer func(start time.Time) {
+		endSpan(span, err)
+		err = r.observe("eth_getLogs", start, err)
+	}(time.Now())
+
 	body := map[string]interface{} {
 		"jsonrpc": "2.0",
 		"id": 1,
@@ -193,13 +216,20 @@ func(r *HTTPRPC) GetLogs(ctx context.Context, filter Filter) ([]Log, error) {
 		return []Log{}, &RPCError{
 			Code: resp.Error.Code,
 			Message: resp.Error.Message,
+			Data: resp.Error.Data,
 		}
 	}
 
 	return resp.Result, nil
 }
 
-func(r *HTTPRPC) GetBlockReceipts(ctx context.Context, blockNumber string) ([]Receipt, error) {
+func(r *HTTPRPC) GetBlockReceipts(ctx context.Context, blockNumber string) (receipts []Receipt, err error) {
+	ctx, span := r.startRPCSpan(ctx, "eth_getBlockReceipts")
+	defer func(start time.Time) {
+		endSpan(span, err)
+		err = r.observe("eth_getBlockReceipts", start, err)
+	}(time.Now())
+
 	body := map[string]interface{} {
 		"jsonrpc": "2.0",
 		"id": 1,
@@ -242,8 +272,311 @@ func(r *HTTPRPC) GetBlockReceipts(ctx context.Context, blockNumber string) ([]Re
 		return []Receipt{}, &RPCError{
 			Code: resp.Error.Code,
 			Message: resp.Error.Message,
+			Data: resp.Error.Data,
+		}
+	}
+
+	return resp.Result, nil
+}
+
+// maxBatchSize bounds the number of calls in one JSON-RPC batch, most providers reject bigger batches.
+const maxBatchSize = 100
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID uint `json:"id"`
+	Method string `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// GetBlocks fetches block headers using JSON-RPC batch requests.
+func(r *HTTPRPC) GetBlocks(ctx context.Context, blockNumbers []string) ([]Block, error) {
+	params := make([][]interface{}, len(blockNumbers))
+	for i, blockNumber := range blockNumbers {
+		params[i] = []interface{}{blockNumber, false}
+	}
+
+	blocks := make([]Block, 0, len(blockNumbers))
+	for start := 0; start < len(params); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(params) {
+			end = len(params)
+		}
+
+		res, err := batchCall[Block](ctx, r, "eth_getBlockByNumber", params[start:end])
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, res...)
+	}
+
+	return blocks, nil
+}
+
+// batchCall sends one batch request calling method once per params entry.
+// Results are returned in params order regardless of the order the node answered in.
+func batchCall[T any](ctx context.Context, r *HTTPRPC, method string, params [][]interface{}) ([]T, error) {
+	ctx, span := r.startRPCSpan(ctx, method)
+	start := time.Now()
+	results, err := sendBatch[T](ctx, r, method, params)
+	endSpan(span, err)
+	return results, r.observe(method, start, err)
+}
+
+// sendBatch posts the batch request without reporting it.
+func sendBatch[T any](ctx context.Context, r *HTTPRPC, method string, params [][]interface{}) ([]T, error) {
+	reqs := make([]rpcRequest, len(params))
+	for i, p := range params {
+		reqs[i] = rpcRequest{
+			JSONRPC: "2.0",
+			ID: uint(i),
+			Method: method,
+			Params: p,
+		}
+	}
+
+	b, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", r.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("error creating http request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching rpc: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, &HTTPError{
+			StatusCode: res.StatusCode,
+			Message: res.Status,
+		}
+	}
+
+	var resps []rpcResponse[T]
+	if err := json.NewDecoder(res.Body).Decode(&resps); err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	results := make([]T, len(params))
+	seen := make([]bool, len(params))
+	for _, resp := range resps {
+		if resp.Error != nil {
+			return nil, &RPCError{
+				Code: resp.Error.Code,
+				Message: resp.Error.Message,
+				Data: resp.Error.Data,
+			}
+		}
+		if resp.ID >= uint(len(params)) {
+			return nil, fmt.Errorf("unexpected id %d in batch response", resp.ID)
+		}
+		results[resp.ID] = resp.Result
+		seen[resp.ID] = true
+	}
+	for i, ok := range seen {
+		if !ok {
+			return nil, fmt.Errorf("missing response for batch request %d", i)
 		}
 	}
 
+	return results, nil
+}
+
+// GetFullBlock returns the block with full transaction objects.
+func(r *HTTPRPC) GetFullBlock(ctx context.Context, blockNumber string) (FullBlock, error) {
+	return call[FullBlock](ctx, r, "eth_getBlockByNumber", []interface{}{blockNumber, true})
+}
+
+// Call executes eth_call at the referenced block.
+func(r *HTTPRPC) Call(ctx context.Context, msg CallMsg, block BlockRef) (string, error) {
+	var ref interface{} = Uint64ToHexQty(block.Number)
+	if block.Hash != "" {
+		ref = map[string]interface{}{"blockHash": block.Hash}
+	}
+	return call[string](ctx, r, "eth_call", []interface{}{msg, ref})
+}
+
+// callFrame is a frame of the debug callTracer output.
+type callFrame struct {
+	Type string `json:"type"`
+	From string `json:"from"`
+	To string `json:"to"`
+	Value string `json:"value"`
+	Gas string `json:"gas"`
+	GasUsed string `json:"gasUsed"`
+	Input string `json:"input"`
+	Output string `json:"output"`
+	Error string `json:"error"`
+	Calls []callFrame `json:"calls"`
+}
+
+// parityTrace is an entry of trace_block.
+type parityTrace struct {
+	Action struct {
+		CallType string `json:"callType"`
+		From string `json:"from"`
+		To string `json:"to"`
+		Value string `json:"value"`
+		Gas string `json:"gas"`
+		Input string `json:"input"`
+		Init string `json:"init"`
+	} `json:"action"`
+	Result *struct {
+		GasUsed string `json:"gasUsed"`
+		Output string `json:"output"`
+		Address string `json:"address"`
+	} `json:"result"`
+	Error string `json:"error"`
+	TraceAddress []int `json:"traceAddress"`
+	TransactionHash string `json:"transactionHash"`
+	TransactionPosition uint64 `json:"transactionPosition"`
+	Type string `json:"type"`
+}
+
+// TraceBlock returns the call traces of a block with debug_traceBlockByNumber or trace_block.
+func(r *HTTPRPC) TraceBlock(ctx context.Context, blockNumber string, method TraceMethod) ([]Trace, error) {
+	switch method {
+	case TraceDebug:
+		type txTrace struct {
+			TxHash string `json:"txHash"`
+			Result callFrame `json:"result"`
+		}
+		res, err := call[[]txTrace](ctx, r, "debug_traceBlockByNumber", []interface{}{
+			blockNumber,
+			map[string]interface{}{"tracer": "callTracer"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var traces []Trace
+		for i, tx := range res {
+			// Older clients don't return txHash, transactions are in block order anyway
+			traces = flattenCallFrame(traces, tx.Result, tx.TxHash, uint64(i), []int{})
+		}
+		return traces, nil
+
+	case TraceParity:
+		res, err := call[[]parityTrace](ctx, r, "trace_block", []interface{}{blockNumber})
+		if err != nil {
+			return nil, err
+		}
+
+		traces := make([]Trace, 0, len(res))
+		for _, pt := range res {
+			t := Trace{
+				TransactionHash: pt.TransactionHash,
+				TransactionIndex: pt.TransactionPosition,
+				TraceAddress: pt.TraceAddress,
+				From: pt.Action.From,
+				To: pt.Action.To,
+				Input: pt.Action.Input,
+				Value: pt.Action.Value,
+				Gas: pt.Action.Gas,
+				Error: pt.Error,
+			}
+			switch pt.Type {
+			case "call":
+				t.Type = strings.ToUpper(pt.Action.CallType)
+			case "create":
+				t.Type = "CREATE"
+				t.Input = pt.Action.Init
+			default:
+				// Block rewards and selfdestructs are not calls
+				continue
+			}
+			if pt.Result != nil {
+				t.GasUsed = pt.Result.GasUsed
+				t.Output = pt.Result.Output
+				if t.Type == "CREATE" {
+					t.To = pt.Result.Address
+				}
+			}
+			traces = append(traces, t)
+		}
+		return traces, nil
+	}
+	return nil, fmt.Errorf("unsupported trace method %q", method)
+}
+
+// flattenCallFrame appends a callTracer frame and its subcalls depth first, the parity trace order.
+func flattenCallFrame(traces []Trace, frame callFrame, txHash string, txIndex uint64, address []int) []Trace {
+	traces = append(traces, Trace{
+		TransactionHash: txHash,
+		TransactionIndex: txIndex,
+		TraceAddress: address,
+		Type: strings.ToUpper(frame.Type),
+		From: frame.From,
+		To: frame.To,
+		Input: frame.Input,
+		Output: frame.Output,
+		Value: frame.Value,
+		Gas: frame.Gas,
+		GasUsed: frame.GasUsed,
+		Error: frame.Error,
+	})
+	for i, sub := range frame.Calls {
+		child := append(append([]int{}, address...), i)
+		traces = flattenCallFrame(traces, sub, txHash, txIndex, child)
+	}
+	return traces
+}
+
+// call sends a single request and decodes its result.
+func call[T any](ctx context.Context, r *HTTPRPC, method string, params []interface{}) (T, error) {
+	ctx, span := r.startRPCSpan(ctx, method)
+	start := time.Now()
+	result, err := send[T](ctx, r, method, params)
+	endSpan(span, err)
+	return result, r.observe(method, start, err)
+}
+
+// send posts the request without reporting it.
+func send[T any](ctx context.Context, r *HTTPRPC, method string, params []interface{}) (T, error) {
+	var zero T
+	b, err := json.Marshal(rpcRequest{
+		JSONRPC: "2.0",
+		ID: 1,
+		Method: method,
+		Params: params,
+	})
+	if err != nil {
+		return zero, fmt.Errorf("error marshaling body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", r.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return zero, fmt.Errorf("error creating http request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return zero, fmt.Errorf("error fetching rpc: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return zero, &HTTPError{
+			StatusCode: res.StatusCode,
+			Message: res.Status,
+		}
+	}
+
+	var resp rpcResponse[T]
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return zero, fmt.Errorf("error reading response body: %w", err)
+	}
+	if resp.Error != nil {
+		return zero, resp.Error
+	}
+
 	return resp.Result, nil
 }
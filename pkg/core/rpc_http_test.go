@@ -345,4 +345,73 @@ func TestGetBlockReceipts_EmptyBlock(t *testing.T) {
 	receipt, err := rpc.GetBlockReceipts(ctx, "0x000")
 	assert.NoError(t, err)
 	assert.Len(t, receipt, 0)
-}
\ No newline at end of file
+}
+
+func TestGetBlocks_Batch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqs []struct {
+			ID     uint          `json:"id"`
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Answer in reverse order, the client has to match ids
+		resps := []map[string]any{}
+		for i := len(reqs) - 1; i >= 0; i-- {
+			resps = append(resps, map[string]any{
+				"jsonrpc": "2.0",
+				"id":      reqs[i].ID,
+				"result": map[string]any{
+					"number": reqs[i].Params[0],
+					"hash":   reqs[i].Params[0],
+				},
+			})
+		}
+		_ = json.NewEncoder(w).Encode(resps)
+	}))
+	defer srv.Close()
+
+	rpc := NewHTTPRPC(srv.URL, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	numbers := []string{}
+	for i := uint64(1); i <= 150; i++ {
+		numbers = append(numbers, Uint64ToHexQty(i))
+	}
+	blocks, err := rpc.GetBlocks(ctx, numbers)
+	assert.NoError(t, err)
+	assert.Len(t, blocks, 150)
+	for i, b := range blocks {
+		assert.Equal(t, numbers[i], b.Number)
+	}
+}
+
+func TestGetBlocks_RPCError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]map[string]any{
+			{
+				"jsonrpc": "2.0",
+				"id":      0,
+				"error": map[string]any{
+					"code":    -32005,
+					"message": "limit exceeded",
+				},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	rpc := NewHTTPRPC(srv.URL, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := rpc.GetBlocks(ctx, []string{"0x1"})
+	var rpcErr *RPCError
+	assert.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, -32005, rpcErr.Code)
+}
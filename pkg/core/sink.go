@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// Batch is one committed window of a chain, handed to sinks in block order.
+type Batch struct {
+	// Chain the logs were indexed from
+	ChainId string
+	// Inclusive block range covered by the batch, it may contain no logs
+	FromBlock uint64
+	ToBlock   uint64
+	// Logs in block order
+	Logs []Log
+	// Calls in block order, only in transactions mode
+	Calls []Call
+	// Cursor is the chain position once this batch is applied.
+	// Sinks that are also the CursorStore should persist it in the same transaction.
+	Cursor Cursor
+}
+
+// Sink receives committed windows from the processor.
+// Write is called by the arbiter in block order, a window is only emitted on the logs channel after every sink accepted it.
+type Sink interface {
+	// Write persists a committed window.
+	Write(ctx context.Context, batch Batch) error
+	// Rollback removes everything above ancestor after a reorg.
+	Rollback(ctx context.Context, chainId string, ancestor uint64) error
+	// Flush forces buffered writes to durable storage, called on graceful stop.
+	Flush(ctx context.Context) error
+}
+
+// ChainFlusher is implemented by sinks buffering several chains.
+// A chain that stops on its own, at EndBlock or removed, flushes only its state through it, other sinks are flushed whole.
+type ChainFlusher interface {
+	// FlushChain forces the buffered writes of one chain to durable storage.
+	FlushChain(ctx context.Context, chainId string) error
+}
+
+// CursorStore persists chain cursors so indexing resumes where it stopped.
+type CursorStore interface {
+	// LoadCursor returns the stored cursor, or nil when the chain was never indexed.
+	LoadCursor(ctx context.Context, chainId string) (*Cursor, error)
+	// SaveCursor stores the cursor, replacing the previous one.
+	SaveCursor(ctx context.Context, cursor Cursor) error
+}
+
+// cursorAt snapshots the chain position at height. Only called from the goroutine owning the chain.
+func (c *chainState) cursorAt(height uint64) Cursor {
+	hashes := make([]WindowHash, 0, len(c.windowOrder))
+	for _, end := range c.windowOrder {
+		hashes = append(hashes, WindowHash{BlockNumber: end, Hash: c.storedWindowHash[end]})
+	}
+	return Cursor{
+		ChainId:      c.chainInfo.ChainId,
+		BlockNumber:  height,
+		WindowHashes: hashes,
+		Children:     c.childContracts(),
+	}
+}
+
+// loadCursor restores the chain position from the CursorStore, once per chain.
+func (p *Processor) loadCursor(ctx context.Context, chain *chainState) error {
+	if chain.opts.CursorStore == nil || chain.cursorLoaded {
+		return nil
+	}
+
+	cursor, err := chain.opts.CursorStore.LoadCursor(ctx, chain.chainInfo.ChainId)
+	if err != nil {
+		return fmt.Errorf("failed to load cursor: %w", err)
+	}
+	chain.cursorLoaded = true
+	if cursor == nil {
+		return nil
+	}
+
+	log.Printf("Chain %s resuming from block %d", chain.chainInfo.ChainId, cursor.BlockNumber)
+	chain.cursor = cursor.BlockNumber
+	chain.windowOrder = nil
+	chain.storedWindowHash = make(map[uint64]string, chain.storedWindowHashCap)
+	for _, wh := range cursor.WindowHashes {
+		p.storeWindowHash(wh.BlockNumber, wh.Hash, chain)
+	}
+	return chain.restoreChildren(cursor.Children)
+}
+
+// saveCursor persists the chain position, skipped when the store already got it through Sink.Write.
+func (p *Processor) saveCursor(ctx context.Context, chain *chainState, afterWrite bool) error {
+	store := chain.opts.CursorStore
+	if store == nil {
+		return nil
+	}
+	if afterWrite {
+		for _, sink := range chain.opts.Sinks {
+			if s, ok := sink.(CursorStore); ok && s == store {
+				return nil
+			}
+		}
+	}
+
+	if err := store.SaveCursor(ctx, chain.cursorAt(chain.cursor)); err != nil {
+		return fmt.Errorf("failed to save cursor: %w", err)
+	}
+	return nil
+}
+
+// writeSinks hands a committed window to every sink of the chain.
+func (p *Processor) writeSinks(ctx context.Context, chain *chainState, batch Batch) error {
+	for _, sink := range chain.opts.Sinks {
+		sctx, span := chain.startSpan(ctx, "indexer.sink.write", AttrSink.String(fmt.Sprintf("%T", sink)), AttrLogs.Int(len(batch.Logs)))
+		err := sink.Write(sctx, batch)
+		endSpan(span, err)
+		if err != nil {
+			return fmt.Errorf("sink write failed for blocks %d-%d: %w", batch.FromBlock, batch.ToBlock, err)
+		}
+	}
+	return nil
+}
+
+// rollbackSinks removes data above the ancestor from every sink of the chain.
+func (p *Processor) rollbackSinks(ctx context.Context, chain *chainState, ancestor uint64) error {
+	for _, sink := range chain.opts.Sinks {
+		if err := sink.Rollback(ctx, chain.chainInfo.ChainId, ancestor); err != nil {
+			return fmt.Errorf("sink rollback to block %d failed: %w", ancestor, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// Multicall3 is the canonical Multicall3 deployment, at the same address on most EVM chains.
+const Multicall3 = "0xcA11bde05977b3631167028862bE2a173976CA11"
+
+// maxMulticallSize bounds the calls aggregated in one eth_call, providers cap the gas of eth_call.
+const maxMulticallSize = 500
+
+var aggregate3 = mustParseFunction("aggregate3((address target, bool allowFailure, bytes callData)[] calls) payable returns ((bool success, bytes returnData)[] returnData)")
+
+// StateCall is one read of a Multicall.
+type StateCall struct {
+	// The contract called
+	Target string
+	// Function declaration, its returns clause decodes the output, e.g. "balanceOf(address) view returns (uint256)"
+	Signature string
+	Args      []any
+}
+
+// StateResult is the outcome of a StateCall.
+type StateResult struct {
+	// Output decoded with the returns clause of the signature
+	Values []any
+	// Raw return data, the revert data when the call failed
+	ReturnData []byte
+	// Err is set when the call reverted or its output didn't decode, other calls are not affected
+	Err error
+}
+
+// StateReader reads contract state with eth_call at a given block.
+type StateReader struct {
+	rpc       CallRPC
+	multicall string
+	// decoder decodes revert data of failed calls
+	decoder *Decoder
+	mu      sync.Mutex
+	// Parsed signatures, handlers keep reading the same functions
+	functions map[string]Function
+}
+
+// NewStateReader creates a reader aggregating through the Multicall3 contract at multicall, "" being Multicall3.
+func NewStateReader(rpc CallRPC, multicall string) *StateReader {
+	if multicall == "" {
+		multicall = Multicall3
+	}
+	return &StateReader{
+		rpc:       rpc,
+		multicall: multicall,
+		decoder:   NewDecoder(),
+		functions: make(map[string]Function),
+	}
+}
+
+// RegisterError adds a custom error declaration used to decode reverts, see ParseError.
+func (r *StateReader) RegisterError(signature string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err := r.decoder.RegisterError(signature)
+	return err
+}
+
+func (r *StateReader) function(signature string) (Function, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if fn, ok := r.functions[signature]; ok {
+		return fn, nil
+	}
+	fn, err := ParseFunction(signature)
+	if err != nil {
+		return Function{}, err
+	}
+	r.functions[signature] = fn
+	return fn, nil
+}
+
+// Call calls a function of target at block and decodes its output.
+// A reverted call returns a *RevertError when the node sent the revert data.
+func (r *StateReader) Call(ctx context.Context, block BlockRef, target string, signature string, args ...any) ([]any, error) {
+	fn, err := r.function(signature)
+	if err != nil {
+		return nil, err
+	}
+	input, err := fn.EncodeCall(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.rpc.Call(ctx, CallMsg{To: target, Data: "0x" + hex.EncodeToString(input)}, block)
+	if err != nil {
+		r.mu.Lock()
+		revert, ok := r.decoder.RevertFromError(err)
+		r.mu.Unlock()
+		if ok {
+			err = revert
+		}
+		return nil, fmt.Errorf("eth_call %s on %s failed: %w", fn.Signature(), target, err)
+	}
+	output, err := hexToBytes(res)
+	if err != nil {
+		return nil, fmt.Errorf("invalid eth_call result: %w", err)
+	}
+	return fn.DecodeOutput(output)
+}
+
+// Multicall aggregates calls at block through Multicall3 aggregate3, in one eth_call per maxMulticallSize calls.
+// A failing call only sets the Err of its result.
+func (r *StateReader) Multicall(ctx context.Context, block BlockRef, calls []StateCall) ([]StateResult, error) {
+	fns := make([]Function, len(calls))
+	encoded := make([]any, len(calls))
+	for i, c := range calls {
+		fn, err := r.function(c.Signature)
+		if err != nil {
+			return nil, err
+		}
+		input, err := fn.EncodeCall(c.Args...)
+		if err != nil {
+			return nil, err
+		}
+		fns[i] = fn
+		encoded[i] = []any{c.Target, true, input}
+	}
+
+	results := make([]StateResult, 0, len(calls))
+	for start := 0; start < len(calls); start += maxMulticallSize {
+		end := start + maxMulticallSize
+		if end > len(calls) {
+			end = len(calls)
+		}
+
+		out, err := r.aggregate(ctx, block, encoded[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for i, o := range out {
+			pair := o.([]any)
+			res := StateResult{ReturnData: pair[1].([]byte)}
+			fn := fns[start+i]
+			if !pair[0].(bool) {
+				r.mu.Lock()
+				revert, err := r.decoder.DecodeRevert(res.ReturnData)
+				r.mu.Unlock()
+				if err != nil {
+					revert = &RevertError{Data: res.ReturnData}
+				}
+				res.Err = fmt.Errorf("call %s on %s failed: %w", fn.Signature(), calls[start+i].Target, revert)
+			} else {
+				res.Values, res.Err = fn.DecodeOutput(res.ReturnData)
+			}
+			results = append(results, res)
+		}
+	}
+	return results, nil
+}
+
+// aggregate sends one aggregate3 call and returns its (success, returnData) pairs.
+func (r *StateReader) aggregate(ctx context.Context, block BlockRef, calls []any) ([]any, error) {
+	input, err := aggregate3.EncodeCall(calls)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.rpc.Call(ctx, CallMsg{To: r.multicall, Data: "0x" + hex.EncodeToString(input)}, block)
+	if err != nil {
+		return nil, fmt.Errorf("multicall of %d calls failed: %w", len(calls), err)
+	}
+	output, err := hexToBytes(res)
+	if err != nil {
+		return nil, fmt.Errorf("invalid eth_call result: %w", err)
+	}
+	values, err := aggregate3.DecodeOutput(output)
+	if err != nil {
+		return nil, err
+	}
+
+	out := values[0].([]any)
+	if len(out) != len(calls) {
+		return nil, fmt.Errorf("multicall returned %d results for %d calls", len(out), len(calls))
+	}
+	return out, nil
+}
+
+// Call reads contract state at the block of the log, pinned by block hash when known.
+// The chain RPC must implement CallRPC.
+func (d *DecodeContext) Call(ctx context.Context, target string, signature string, args ...any) ([]any, error) {
+	if d.state == nil {
+		return nil, fmt.Errorf("state reads require an RPC implementing CallRPC")
+	}
+	return d.state.Call(ctx, d.blockRef(), target, signature, args...)
+}
+
+// Multicall aggregates state reads at the block of the log, see StateReader.Multicall.
+func (d *DecodeContext) Multicall(ctx context.Context, calls []StateCall) ([]StateResult, error) {
+	if d.state == nil {
+		return nil, fmt.Errorf("state reads require an RPC implementing CallRPC")
+	}
+	return d.state.Multicall(ctx, d.blockRef(), calls)
+}
+
+func (d *DecodeContext) blockRef() BlockRef {
+	return BlockRef{Number: d.BlockNumber, Hash: d.BlockHash}
+}
+
+func mustParseFunction(signature string) Function {
+	fn, err := ParseFunction(signature)
+	if err != nil {
+		panic(err)
+	}
+	return fn
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Vectors generated with go-ethereum accounts/abi
+const (
+	balanceOfInput = "0x70a0823100000000000000000000000028c6c06298d514db089934071355e5743bf21d60"
+	aggregateInput = "0x82ad56cb0000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000000000000000000400000000000000000000000000000000000000000000000000000000000000100000000000000000000000000dac17f958d2ee523a2206206994597c13d831ec700000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000060000000000000000000000000000000000000000000000000000000000000002470a0823100000000000000000000000028c6c06298d514db089934071355e5743bf21d600000000000000000000000000000000000000000000000000000000000000000000000000000000028c6c06298d514db089934071355e5743bf21d6000000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000060000000000000000000000000000000000000000000000000000000000000000406fdde0300000000000000000000000000000000000000000000000000000000"
+	aggregateOutput = "0x00000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000004000000000000000000000000000000000000000000000000000000000000000c000000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000040000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000003e8000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000400000000000000000000000000000000000000000000000000000000000000000"
+	mixedInput      = "0x5c8a998cfffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffd00000000000000000000000000000000000000000000000000000000000000800000000000000000000000000000000000000000000000000000000000000160010203000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000040000000000000000000000000000000000000000000000000000000000000008000000000000000000000000000000000000000000000000000000000000000026162000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000163000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000060000000000000000000000000000000000000000000000000000000000000000100000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000002ffee000000000000000000000000000000000000000000000000000000000000"
+)
+
+func TestFunction_EncodeCall(t *testing.T) {
+	fn, err := ParseFunction("balanceOf(address) returns (uint256)")
+	assert.NoError(t, err)
+	input, err := fn.EncodeCall(testSender)
+	assert.NoError(t, err)
+	assert.Equal(t, balanceOfInput, "0x"+hex.EncodeToString(input))
+
+	fn, err = ParseFunction("mixed(int8 a, string[] b, (bytes x, uint16[2] y) c, bytes3 d)")
+	assert.NoError(t, err)
+	args := []any{
+		big.NewInt(-3),
+		[]any{"ab", "c"},
+		[]any{[]byte{0xff, 0xee}, []any{1, 2}},
+		[]byte{1, 2, 3},
+	}
+	input, err = fn.EncodeCall(args...)
+	assert.NoError(t, err)
+	assert.Equal(t, mixedInput, "0x"+hex.EncodeToString(input))
+
+	call, err := fn.DecodeCall(input)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(-3), call.Args["a"])
+	assert.Equal(t, []any{"ab", "c"}, call.Args["b"])
+
+	_, err = fn.EncodeCall(big.NewInt(128), []any{}, []any{[]byte{}, []any{1, 2}}, []byte{1, 2, 3})
+	assert.Error(t, err)
+	_, err = fn.EncodeCall(big.NewInt(1))
+	assert.Error(t, err)
+}
+
+func TestStateReader_Multicall(t *testing.T) {
+	var mu sync.Mutex
+	var params []json.RawMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Params []json.RawMessage `json:"params"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		mu.Lock()
+		params = req.Params
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": aggregateOutput})
+	}))
+	defer srv.Close()
+
+	reader := NewStateReader(NewHTTPRPC(srv.URL, 0), "")
+	block := BlockRef{Number: 10, Hash: "0x" + hex.EncodeToString(make([]byte, 32))}
+	results, err := reader.Multicall(context.Background(), block, []StateCall{
+		{Target: testToken, Signature: "balanceOf(address) view returns (uint256)", Args: []any{testSender}},
+		{Target: testSender, Signature: "name() returns (string)"},
+	})
+	assert.NoError(t, err)
+
+	var msg CallMsg
+	json.Unmarshal(params[0], &msg)
+	assert.Equal(t, Multicall3, msg.To)
+	assert.Equal(t, aggregateInput, msg.Data)
+	assert.JSONEq(t, `{"blockHash":"`+block.Hash+`"}`, string(params[1]))
+
+	assert.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, []any{big.NewInt(1000)}, results[0].Values)
+	var revert *RevertError
+	assert.ErrorAs(t, results[1].Err, &revert)
+}
+
+func TestProcessorOn_StateReadsAtLogBlock(t *testing.T) {
+	// No data, the handler gets called with any log
+	poke := "Poke()"
+	srv := newFactoryServer(12, []Log{
+		factoryTestLog(testPoolA, ConvertToTopics([]string{poke})[0], 4, 0, "0x"),
+		factoryTestLog(testPoolA, ConvertToTopics([]string{poke})[0], 9, 0, "0x"),
+	})
+	defer srv.Close()
+
+	processor := NewProcessor()
+	err := processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:      5,
+		LogsBufferSize: 10,
+		Topics:         []string{poke},
+	})
+	assert.NoError(t, err)
+
+	reads := make(chan uint64, 2)
+	err = processor.On("1", poke, func(ctx context.Context, ev *DecodedEvent, dctx *DecodeContext) error {
+		values, err := dctx.Call(ctx, testPoolA, "slot0() view returns (uint256)")
+		if err != nil {
+			return err
+		}
+		reads <- values[0].(*big.Int).Uint64()
+		return nil
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go processor.Run(ctx)
+
+	for _, expected := range []uint64{4, 9} {
+		select {
+		case got := <-reads:
+			assert.Equal(t, expected, got)
+		case <-ctx.Done():
+			t.Fatal("Test timeout")
+		}
+	}
+	assert.NoError(t, processor.Stop(ctx))
+}
@@ -0,0 +1,249 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+type SlowConsumerPolicy string
+
+const (
+	SlowConsumerBlock      SlowConsumerPolicy = "block"       // Wait for the subscriber, stalls the chain (default)
+	SlowConsumerDropOldest SlowConsumerPolicy = "drop-oldest" // Drop the oldest buffered log to make room
+	SlowConsumerDisconnect SlowConsumerPolicy = "disconnect"  // End the subscription with a SlowConsumerError
+)
+
+// DefaultSubscriptionBuffer is the buffer of a subscription when SubscriptionFilter.BufferSize is 0.
+const DefaultSubscriptionBuffer = 1024
+
+// SubscriptionFilter selects the logs of a subscription and how a full buffer is handled.
+type SubscriptionFilter struct {
+	// Addresses matches the log emitter, empty matches any
+	Addresses []string
+	// Topics matches topic0, as event signatures, declarations or hashes like Options.Topics. Empty matches any
+	Topics []string
+	// BufferSize is the number of logs buffered for the subscriber
+	// Default: 1024
+	BufferSize int
+	// Policy applies when the buffer is full
+	// Default: "block"
+	Policy SlowConsumerPolicy
+}
+
+// Subscription is an ordered copy of the logs a chain emits on Logs.
+type Subscription interface {
+	// Logs delivers the matching logs in commit order.
+	// It is closed by Unsubscribe, by a disconnection and when the chain is removed.
+	Logs() <-chan Log
+	// Err is why the subscription ended once Logs is closed, nil when it was unsubscribed or its chain removed
+	Err() error
+	// Dropped counts the logs discarded under SlowConsumerDropOldest
+	Dropped() uint64
+	// Unsubscribe ends the subscription, logs already buffered can still be read
+	Unsubscribe()
+}
+
+type subscription struct {
+	chainId   string
+	addresses map[AddressBytes]bool
+	topics    map[Hash]bool
+	policy    SlowConsumerPolicy
+	// remove unregisters the subscription from its chain
+	remove func(*subscription)
+
+	// mu serializes sends and close, done is closed first so a blocked send gives up the lock
+	mu      sync.Mutex
+	ch      chan Log
+	done    chan struct{}
+	once    sync.Once
+	closed  bool
+	err     error
+	dropped atomic.Uint64
+}
+
+// Subscribe returns a new subscription to the logs of a chain, running or not.
+// Each subscription gets its own copy, so a slow subscriber only holds the chain back under SlowConsumerBlock.
+//
+// Once Subscribe was called on a chain, Logs is only fed when it was called too, so subscribers don't have to drain it,
+// even after their subscriptions ended.
+func (p *Processor) Subscribe(chainId string, filter SubscriptionFilter) (Subscription, error) {
+	p.mu.RLock()
+	chain, exists := p.chains[chainId]
+	p.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("chain %s not found", chainId)
+	}
+
+	switch filter.Policy {
+	case "":
+		filter.Policy = SlowConsumerBlock
+	case SlowConsumerBlock, SlowConsumerDropOldest, SlowConsumerDisconnect:
+	default:
+		return nil, fmt.Errorf("unknown slow consumer policy %q", filter.Policy)
+	}
+	if filter.BufferSize <= 0 {
+		filter.BufferSize = DefaultSubscriptionBuffer
+	}
+
+	sub := &subscription{
+		chainId: chainId,
+		policy:  filter.Policy,
+		ch:      make(chan Log, filter.BufferSize),
+		done:    make(chan struct{}),
+		remove:  chain.removeSubscription,
+	}
+	if len(filter.Addresses) > 0 {
+		sub.addresses = make(map[AddressBytes]bool, len(filter.Addresses))
+		for _, addr := range filter.Addresses {
+			a, err := ParseAddressBytes(addr)
+			if err != nil {
+				return nil, err
+			}
+			sub.addresses[a] = true
+		}
+	}
+	if len(filter.Topics) > 0 {
+		sub.topics = make(map[Hash]bool, len(filter.Topics))
+		for i, topic := range ConvertToTopics(filter.Topics) {
+			h, err := ParseHash(topic)
+			if err != nil {
+				return nil, fmt.Errorf("invalid topic %q: %w", filter.Topics[i], err)
+			}
+			sub.topics[h] = true
+		}
+	}
+
+	chain.subsMu.Lock()
+	chain.subs = append(chain.subs, sub)
+	chain.subsMu.Unlock()
+	chain.subscribed.Store(true)
+	return sub, nil
+}
+
+func (s *subscription) Logs() <-chan Log {
+	return s.ch
+}
+
+func (s *subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *subscription) Unsubscribe() {
+	s.close(nil)
+}
+
+// close ends the subscription once with err.
+func (s *subscription) close(err error) {
+	s.once.Do(func() {
+		close(s.done)
+		s.mu.Lock()
+		s.closed = true
+		s.err = err
+		close(s.ch)
+		s.mu.Unlock()
+		s.remove(s)
+	})
+}
+
+func (s *subscription) matches(l TypedLog) bool {
+	if s.addresses != nil && !s.addresses[l.Address] {
+		return false
+	}
+	if s.topics != nil && (len(l.Topics) == 0 || !s.topics[l.Topics[0]]) {
+		return false
+	}
+	return true
+}
+
+// send delivers a log according to the policy. It returns false when the subscriber has to be disconnected.
+func (s *subscription) send(ctx context.Context, l Log) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true, nil
+	}
+
+	switch s.policy {
+	case SlowConsumerDropOldest:
+		for {
+			select {
+			case s.ch <- l:
+				return true, nil
+			default:
+			}
+			// The subscriber may read concurrently, an empty channel just means room was made
+			select {
+			case <-s.ch:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	case SlowConsumerDisconnect:
+		select {
+		case s.ch <- l:
+			return true, nil
+		default:
+			return false, nil
+		}
+	default:
+		select {
+		case s.ch <- l:
+			return true, nil
+		case <-s.done:
+			return true, nil
+		case <-ctx.Done():
+			return true, ctx.Err()
+		}
+	}
+}
+
+// publish delivers a log to the matching subscriptions of the chain, in subscription order.
+func (c *chainState) publish(ctx context.Context, l fetchedLog) error {
+	c.subsMu.RLock()
+	subs := c.subs
+	c.subsMu.RUnlock()
+
+	for _, sub := range subs {
+		if !sub.matches(l.typed) {
+			continue
+		}
+		ok, err := sub.send(ctx, l.Log)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			sub.close(&SlowConsumerError{ChainId: c.chainInfo.ChainId, BufferSize: cap(sub.ch)})
+		}
+	}
+	return nil
+}
+
+func (c *chainState) removeSubscription(sub *subscription) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	for i, s := range c.subs {
+		if s == sub {
+			// Copy on write, publish iterates over a snapshot
+			c.subs = append(append([]*subscription(nil), c.subs[:i]...), c.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// closeSubscriptions ends every subscription of a removed chain.
+func (c *chainState) closeSubscriptions() {
+	c.subsMu.RLock()
+	subs := c.subs
+	c.subsMu.RUnlock()
+	for _, sub := range subs {
+		sub.close(nil)
+	}
+}
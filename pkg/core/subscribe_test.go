@@ -0,0 +1,181 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testTransferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	testApprovalTopic = "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"
+)
+
+// newSubscribeProcessor indexes blocks 1..40 with one log per block, alternating pool A Transfers and pool B Approvals.
+func newSubscribeProcessor(t *testing.T) *Processor {
+	var logs []Log
+	for n := uint64(1); n <= 40; n++ {
+		if n%2 == 1 {
+			logs = append(logs, factoryTestLog(testPoolA, testTransferTopic, n, 0, "0x"))
+		} else {
+			logs = append(logs, factoryTestLog(testPoolB, testApprovalTopic, n, 0, "0x"))
+		}
+	}
+	srv := newFactoryServer(100, logs)
+	t.Cleanup(srv.Close)
+
+	processor := NewProcessor()
+	err := processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:          5,
+		FetcherConcurrency: 2,
+		LogsBufferSize:     1,
+		EndBlock:           40,
+	})
+	assert.NoError(t, err)
+	return processor
+}
+
+func blockNumbers(ch <-chan Log) []uint64 {
+	var out []uint64
+	for l := range ch {
+		n, _ := HexQtyToUint64(l.BlockNumber)
+		out = append(out, n)
+	}
+	return out
+}
+
+func TestSubscribe_FanOutAndFilter(t *testing.T) {
+	processor := newSubscribeProcessor(t)
+	all, err := processor.Subscribe("1", SubscriptionFilter{})
+	assert.NoError(t, err)
+	poolA, err := processor.Subscribe("1", SubscriptionFilter{Addresses: []string{"0x8AD599C3A0FF1DE082011EFDDC58F1908EB6E6D8"}})
+	assert.NoError(t, err)
+	approvals, err := processor.Subscribe("1", SubscriptionFilter{Topics: []string{"Approval(address,address,uint256)"}})
+	assert.NoError(t, err)
+
+	// Logs is never read: with only subscribers it isn't fed, so the run completes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, processor.Run(ctx))
+	assert.NoError(t, processor.RemoveChain(ctx, "1"))
+
+	want := make([]uint64, 40)
+	for i := range want {
+		want[i] = uint64(i + 1)
+	}
+	assert.Equal(t, want, blockNumbers(all.Logs()))
+
+	var odd, even []uint64
+	for _, n := range want {
+		if n%2 == 1 {
+			odd = append(odd, n)
+		} else {
+			even = append(even, n)
+		}
+	}
+	assert.Equal(t, odd, blockNumbers(poolA.Logs()))
+	assert.Equal(t, even, blockNumbers(approvals.Logs()))
+	assert.NoError(t, all.Err())
+}
+
+func TestSubscribe_SlowConsumers(t *testing.T) {
+	processor := newSubscribeProcessor(t)
+	fast, _ := processor.Subscribe("1", SubscriptionFilter{})
+	dropping, _ := processor.Subscribe("1", SubscriptionFilter{BufferSize: 5, Policy: SlowConsumerDropOldest})
+	disconnected, _ := processor.Subscribe("1", SubscriptionFilter{BufferSize: 5, Policy: SlowConsumerDisconnect})
+
+	// Nobody reads until the run is over, neither slow subscriber holds the chain back
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, processor.Run(ctx))
+
+	assert.Len(t, fast.Logs(), 40)
+
+	assert.Equal(t, uint64(35), dropping.Dropped())
+	dropping.Unsubscribe()
+	assert.Equal(t, []uint64{36, 37, 38, 39, 40}, blockNumbers(dropping.Logs()))
+	assert.NoError(t, dropping.Err())
+
+	// The buffered logs are still delivered before the channel closes
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5}, blockNumbers(disconnected.Logs()))
+	var slowErr *SlowConsumerError
+	if assert.True(t, errors.As(disconnected.Err(), &slowErr)) {
+		assert.Equal(t, "1", slowErr.ChainId)
+		assert.Equal(t, 5, slowErr.BufferSize)
+	}
+}
+
+func TestSubscribe_BlockPolicy(t *testing.T) {
+	processor := newSubscribeProcessor(t)
+	blocking, _ := processor.Subscribe("1", SubscriptionFilter{BufferSize: 1})
+	other, _ := processor.Subscribe("1", SubscriptionFilter{BufferSize: 1})
+	otherDone := make(chan []uint64, 1)
+	go func() { otherDone <- blockNumbers(other.Logs()) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	runDone := make(chan error, 1)
+	go func() { runDone <- processor.Run(ctx) }()
+
+	// The chain waits for the blocking subscriber
+	var got []uint64
+	for len(got) < 10 {
+		l := <-blocking.Logs()
+		n, _ := HexQtyToUint64(l.BlockNumber)
+		got = append(got, n)
+	}
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, got)
+	select {
+	case <-runDone:
+		t.Fatal("run completed while a blocking subscriber lagged")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Unsubscribing releases the chain, the other subscriber keeps its ordered copy
+	blocking.Unsubscribe()
+	assert.NoError(t, <-runDone)
+	other.Unsubscribe()
+	want := make([]uint64, 40)
+	for i := range want {
+		want[i] = uint64(i + 1)
+	}
+	assert.Equal(t, want, <-otherDone)
+}
+
+func TestSubscribe_Errors(t *testing.T) {
+	processor := NewProcessor()
+	_, err := processor.Subscribe("1", SubscriptionFilter{})
+	assert.Error(t, err)
+
+	processor = newSubscribeProcessor(t)
+	_, err = processor.Subscribe("1", SubscriptionFilter{Policy: "fast"})
+	assert.Error(t, err)
+	_, err = processor.Subscribe("1", SubscriptionFilter{Addresses: []string{"0x12"}})
+	assert.Error(t, err)
+}
+
+func TestSubscribe_LastSubscriberGone(t *testing.T) {
+	processor := newSubscribeProcessor(t)
+	// Disconnected after its first log, the chain is left without subscribers
+	sub, err := processor.Subscribe("1", SubscriptionFilter{BufferSize: 1, Policy: SlowConsumerDisconnect})
+	assert.NoError(t, err)
+
+	// Logs is never called, the chain keeps committing to EndBlock instead of filling it
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runDone := make(chan error, 1)
+	go func() { runDone <- processor.Run(ctx) }()
+	select {
+	case err := <-runDone:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("chain stalled once its last subscriber was gone")
+	}
+
+	assert.Equal(t, []uint64{1}, blockNumbers(sub.Logs()))
+	var slowErr *SlowConsumerError
+	assert.True(t, errors.As(sub.Err(), &slowErr))
+}
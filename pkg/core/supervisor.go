@@ -0,0 +1,183 @@
+package core
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type RestartMode string
+
+const (
+	RestartNever   RestartMode = "never"   // A failed chain stays down (default)
+	RestartAlways  RestartMode = "always"  // Restart after InitialBackoff every time
+	RestartBackoff RestartMode = "backoff" // Restart with exponentially growing delays
+)
+
+// errorsBufferSize bounds the Errors stream, failures are dropped when nobody reads it.
+const errorsBufferSize = 64
+
+type RestartPolicy struct {
+	// Mode selects when a failed chain is restarted
+	// Default: "never"
+	Mode RestartMode
+	// MaxRestarts is the number of restarts before the chain is given up
+	// 0 means unlimited
+	MaxRestarts int
+	// InitialBackoff is the delay before the first restart
+	// Default: 1s
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between restarts in backoff mode
+	// Default: 5m
+	MaxBackoff time.Duration
+	// Multiplier is the factor by which the delay grows after each restart in backoff mode
+	// Default: 2.0
+	Multiplier float64
+}
+
+func DefaultRestartPolicy() RestartPolicy {
+	return RestartPolicy{
+		Mode:           RestartNever,
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     5 * time.Minute,
+		Multiplier:     2.0,
+	}
+}
+
+// allows reports whether a chain that already restarted `restarts` times may restart again.
+func (r RestartPolicy) allows(restarts int) bool {
+	switch r.Mode {
+	case RestartAlways, RestartBackoff:
+		return r.MaxRestarts == 0 || restarts < r.MaxRestarts
+	default:
+		return false
+	}
+}
+
+// delay returns the wait before the restart following `restarts` previous ones.
+func (r RestartPolicy) delay(restarts int) time.Duration {
+	wait := r.InitialBackoff
+	if r.Mode != RestartBackoff {
+		return wait
+	}
+	for i := 0; i < restarts; i++ {
+		wait = time.Duration(float64(wait) * r.Multiplier)
+		if r.MaxBackoff > 0 && wait >= r.MaxBackoff {
+			return r.MaxBackoff
+		}
+	}
+	return wait
+}
+
+// ChainHealth is the supervision state of a chain.
+type ChainHealth struct {
+	Status ChainStatus
+	// LastError is the last failure of the chain, nil if it never failed
+	LastError error
+	// LastErrorAt is when LastError happened
+	LastErrorAt time.Time
+	// Restarts counts the restarts since the chain was added
+	Restarts int
+}
+
+// Health returns the supervision state of a chain.
+func (p *Processor) Health(chainId string) (ChainHealth, error) {
+	chain, err := p.chain(chainId)
+	if err != nil {
+		return ChainHealth{}, err
+	}
+
+	chain.controlMu.Lock()
+	defer chain.controlMu.Unlock()
+	return ChainHealth{
+		Status:      chain.control.status,
+		LastError:   chain.control.lastErr,
+		LastErrorAt: chain.control.lastErrAt,
+		Restarts:    chain.control.restarts,
+	}, nil
+}
+
+// Errors streams chain failures of every run, whether the chain gets restarted or not.
+// The channel is never closed. Failures are dropped when it's full.
+func (p *Processor) Errors() <-chan ChainError {
+	return p.errorsCh
+}
+
+// supervise runs a chain and restarts it on failure according to its RestartPolicy.
+// It returns the last error of a chain that was given up, nil when it was stopped.
+func (p *Processor) supervise(ctx context.Context, logsCh chan Log, chain *chainState) error {
+	policy := *chain.opts.RestartPolicy
+	id := chain.chainInfo.ChainId
+
+	for {
+		chain.setStatus(ChainRunning)
+		err := p.runChain(ctx, logsCh, chain)
+		// Errors caused by cancelling Run, Stop or RemoveChain are a clean stop, not a failure
+		if err == nil || p.halted(ctx, chain) {
+			chain.setStatus(ChainStopped)
+			return nil
+		}
+
+		restarts := chain.recordFailure(err)
+		restart := policy.allows(restarts)
+		p.reportError(ChainError{
+			ChainId:     id,
+			Err:         err,
+			Restarts:    restarts,
+			WillRestart: restart,
+		})
+
+		if !restart {
+			chain.setStatus(ChainErrored)
+			log.Printf("Chain %s stopped: %v", id, err)
+			return err
+		}
+
+		wait := policy.delay(restarts)
+		chain.setStatus(ChainRestarting)
+		log.Printf("Chain %s failed: %v, restarting in %s", id, err, wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		case <-p.stopSignal():
+		case <-chain.removeSignal():
+		}
+		timer.Stop()
+		if p.halted(ctx, chain) {
+			chain.setStatus(ChainStopped)
+			return nil
+		}
+		chain.recordRestart()
+	}
+}
+
+// halted reports whether the chain was asked to stop, by cancelling Run, Stop or RemoveChain.
+func (p *Processor) halted(ctx context.Context, chain *chainState) bool {
+	return ctx.Err() != nil || p.stopping() || chain.removing()
+}
+
+// reportError publishes a failure on the Errors stream without blocking the chain.
+func (p *Processor) reportError(chainErr ChainError) {
+	select {
+	case p.errorsCh <- chainErr:
+	default:
+		log.Printf("Errors stream is full, dropping: %v", &chainErr)
+	}
+}
+
+// recordFailure stores the last error and returns the number of restarts so far.
+func (c *chainState) recordFailure(err error) int {
+	c.controlMu.Lock()
+	defer c.controlMu.Unlock()
+	c.control.lastErr = err
+	c.control.lastErrAt = time.Now()
+	return c.control.restarts
+}
+
+func (c *chainState) recordRestart() {
+	c.controlMu.Lock()
+	defer c.controlMu.Unlock()
+	c.control.restarts++
+}
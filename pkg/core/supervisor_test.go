@@ -0,0 +1,263 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newFlakyServer rejects the first `failures` head requests with a non-retryable error, then serves newChainServer.
+func newFlakyServer(failures int32, head uint64) (*httptest.Server, func()) {
+	healthy := newChainServer(head)
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"jsonrpc": "2.0",
+				"id":      1,
+				"error":   map[string]any{"code": -32601, "message": "method not found"},
+			})
+			return
+		}
+		healthy.Config.Handler.ServeHTTP(w, r)
+	}))
+	return srv, func() {
+		srv.Close()
+		healthy.Close()
+	}
+}
+
+func noRetry() *RetryConfig {
+	cfg := DefaultRetryConfig()
+	cfg.MaxAttempts = 1
+	return &cfg
+}
+
+func TestSupervisor_RestartWithBackoff(t *testing.T) {
+	srv, closeSrv := newFlakyServer(2, 100)
+	defer closeSrv()
+
+	sink := &memorySink{}
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:   10,
+		Sinks:       []Sink{sink},
+		RetryConfig: noRetry(),
+		RestartPolicy: &RestartPolicy{
+			Mode:           RestartBackoff,
+			MaxRestarts:    5,
+			InitialBackoff: 10 * time.Millisecond,
+			MaxBackoff:     50 * time.Millisecond,
+			Multiplier:     2,
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go processor.Run(ctx)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case chainErr := <-processor.Errors():
+			assert.Equal(t, "1", chainErr.ChainId)
+			assert.Equal(t, i, chainErr.Restarts)
+			assert.True(t, chainErr.WillRestart)
+
+			var rpcErr *RPCError
+			assert.True(t, errors.As(&chainErr, &rpcErr))
+		case <-ctx.Done():
+			t.Fatal("Test timeout")
+		}
+	}
+
+	assert.Eventually(t, func() bool { return sink.written() > 0 }, 4*time.Second, 10*time.Millisecond)
+
+	health, err := processor.Health("1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, health.Restarts)
+	assert.Error(t, health.LastError)
+	assert.False(t, health.LastErrorAt.IsZero())
+	assert.NotEqual(t, ChainErrored, health.Status)
+
+	assert.NoError(t, processor.Stop(ctx))
+}
+
+func TestSupervisor_GivesUpAfterMaxRestarts(t *testing.T) {
+	srv, closeSrv := newFlakyServer(1000, 100)
+	defer closeSrv()
+
+	healthySink := &memorySink{}
+	healthy := newChainServer(100)
+	defer healthy.Close()
+
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:   10,
+		RetryConfig: noRetry(),
+		RestartPolicy: &RestartPolicy{
+			Mode:           RestartAlways,
+			MaxRestarts:    2,
+			InitialBackoff: time.Millisecond,
+		},
+	})
+	processor.AddChain(ChainInfo{ChainId: "137", RPC: NewHTTPRPC(healthy.URL, 0)}, &Options{
+		RangeSize: 10,
+		Sinks:     []Sink{healthySink},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go processor.Run(ctx)
+
+	var last ChainError
+	for i := 0; i < 3; i++ {
+		select {
+		case last = <-processor.Errors():
+		case <-ctx.Done():
+			t.Fatal("Test timeout")
+		}
+	}
+	assert.Equal(t, 2, last.Restarts)
+	assert.False(t, last.WillRestart)
+
+	assert.Eventually(t, func() bool {
+		health, _ := processor.Health("1")
+		return health.Status == ChainErrored
+	}, time.Second, 10*time.Millisecond)
+
+	// The other chain is not affected
+	assert.Eventually(t, func() bool { return healthySink.written() > 0 }, 4*time.Second, 10*time.Millisecond)
+	state, _ := processor.State("137")
+	assert.NotEqual(t, ChainErrored, state)
+
+	assert.NoError(t, processor.Stop(ctx))
+}
+
+func TestSupervisor_NeverRestartByDefault(t *testing.T) {
+	srv, closeSrv := newFlakyServer(1000, 100)
+	defer closeSrv()
+
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:   10,
+		RetryConfig: noRetry(),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := processor.Run(ctx)
+	assert.Error(t, err)
+
+	chainErr := <-processor.Errors()
+	assert.False(t, chainErr.WillRestart)
+	assert.Contains(t, chainErr.Error(), "chain 1 failed")
+
+	health, _ := processor.Health("1")
+	assert.Equal(t, ChainErrored, health.Status)
+	assert.Equal(t, 0, health.Restarts)
+}
+
+func TestSupervisor_CancelRunIsCleanStop(t *testing.T) {
+	healthy := newChainServer(20)
+	defer healthy.Close()
+	// The second head request hangs, so the deadline interrupts the chain in the middle of a call
+	var heads int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if bytes.Contains(body, []byte("eth_blockNumber")) && atomic.AddInt32(&heads, 1) > 1 {
+			<-r.Context().Done()
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		healthy.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:      10,
+		LogsBufferSize: 1024,
+		RetryConfig:    noRetry(),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, processor.Run(ctx))
+
+	health, _ := processor.Health("1")
+	assert.Equal(t, ChainStopped, health.Status)
+	assert.NoError(t, health.LastError)
+	select {
+	case chainErr := <-processor.Errors():
+		t.Fatalf("unexpected chain error: %v", &chainErr)
+	default:
+	}
+}
+
+func TestSupervisor_CancelDuringBackoff(t *testing.T) {
+	srv, closeSrv := newFlakyServer(1000, 100)
+	defer closeSrv()
+
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:   10,
+		RetryConfig: noRetry(),
+		RestartPolicy: &RestartPolicy{
+			Mode:           RestartBackoff,
+			InitialBackoff: time.Minute,
+			MaxBackoff:     time.Minute,
+			Multiplier:     2,
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() { runErr <- processor.Run(ctx) }()
+
+	chainErr := <-processor.Errors()
+	assert.True(t, chainErr.WillRestart)
+	assert.Eventually(t, func() bool {
+		health, _ := processor.Health("1")
+		return health.Status == ChainRestarting
+	}, time.Second, 5*time.Millisecond)
+
+	// Cancelling Run while the chain waits to restart stops it cleanly
+	cancel()
+	assert.NoError(t, <-runErr)
+	health, _ := processor.Health("1")
+	assert.Equal(t, ChainStopped, health.Status)
+}
+
+func TestRestartPolicy_Delay(t *testing.T) {
+	policy := RestartPolicy{
+		Mode:           RestartBackoff,
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+	}
+	assert.Equal(t, time.Second, policy.delay(0))
+	assert.Equal(t, 4*time.Second, policy.delay(2))
+	assert.Equal(t, 5*time.Second, policy.delay(10))
+
+	policy.Mode = RestartAlways
+	assert.Equal(t, time.Second, policy.delay(10))
+
+	policy.MaxRestarts = 3
+	assert.True(t, policy.allows(2))
+	assert.False(t, policy.allows(3))
+
+	policy.Mode = RestartNever
+	assert.False(t, policy.allows(0))
+}
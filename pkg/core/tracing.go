@@ -0,0 +1,55 @@
+package core
+
+import (
+	"context"
+	"net/url"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation scope of the spans of the processor and the RPC layer.
+const TracerName = "github.com/ryuux05/indexer-sdk-go/pkg/core"
+
+// Span attributes
+const (
+	AttrChainId   = attribute.Key("indexer.chain_id")
+	AttrFromBlock = attribute.Key("indexer.from_block")
+	AttrToBlock   = attribute.Key("indexer.to_block")
+	AttrLogs      = attribute.Key("indexer.logs")
+	AttrSink      = attribute.Key("indexer.sink")
+	AttrAttempt   = attribute.Key("indexer.retry.attempt")
+)
+
+// startSpan starts a span of the chain, a child of the span in ctx if any.
+func (c *chainState) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, AttrChainId.String(c.chainInfo.ChainId))
+	return c.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// childTracer is the tracer of the span in ctx, spans of untraced calls are not recorded.
+func childTracer(ctx context.Context) trace.Tracer {
+	return trace.SpanFromContext(ctx).TracerProvider().Tracer(TracerName)
+}
+
+// endSpan records err on the span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startRPCSpan starts the client span of a request, the endpoint is reduced to its host to keep API keys out of traces.
+func (r *HTTPRPC) startRPCSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attribute.String("rpc.system", "jsonrpc"),
+		attribute.String("rpc.method", method),
+	}
+	if u, err := url.Parse(r.endpoint); err == nil && u.Host != "" {
+		attrs = append(attrs, attribute.String("server.address", u.Hostname()))
+	}
+	return childTracer(ctx).Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,173 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestTracer() (*tracetest.InMemoryExporter, *sdktrace.TracerProvider) {
+	exporter := tracetest.NewInMemoryExporter()
+	return exporter, sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+}
+
+func spanAttr(s tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// childrenOf returns the names of the spans whose parent is span.
+func childrenOf(spans tracetest.SpanStubs, span trace.SpanContext) []string {
+	var names []string
+	for _, s := range spans {
+		if s.Parent.SpanID() == span.SpanID() {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+func TestTracing_Windows(t *testing.T) {
+	var logs []Log
+	for n := uint64(1); n <= 20; n++ {
+		logs = append(logs, factoryTestLog(testPoolA, testTransferTopic, n, 0, "0x"))
+	}
+	srv := newFactoryServer(100, logs)
+	defer srv.Close()
+
+	exporter, tp := newTestTracer()
+	processor := NewProcessor()
+	err := processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:      10,
+		LogsBufferSize: 64,
+		EndBlock:       20,
+		Sinks:          []Sink{&memorySink{}},
+		TracerProvider: tp,
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx, root := tp.Tracer("test").Start(ctx, "run")
+	assert.NoError(t, processor.Run(ctx))
+	root.End()
+
+	spans := exporter.GetSpans()
+	var windows tracetest.SpanStubs
+	for _, s := range spans {
+		if s.Name == "indexer.window" {
+			windows = append(windows, s)
+		}
+	}
+	if !assert.Len(t, windows, 2) {
+		return
+	}
+
+	var ranges [][2]int64
+	for _, w := range windows {
+		// The trace context of Run is propagated
+		assert.Equal(t, root.SpanContext().TraceID(), w.SpanContext.TraceID())
+		assert.Equal(t, root.SpanContext().SpanID(), w.Parent.SpanID())
+		assert.Equal(t, "1", spanAttr(w, AttrChainId).AsString())
+		ranges = append(ranges, [2]int64{spanAttr(w, AttrFromBlock).AsInt64(), spanAttr(w, AttrToBlock).AsInt64()})
+
+		// The arbiter reads the start and end blocks of the window
+		children := childrenOf(spans, w.SpanContext)
+		assert.ElementsMatch(t, []string{
+			"indexer.fetch", "indexer.retry.attempt", "indexer.retry.attempt",
+			"eth_getBlockByNumber", "eth_getBlockByNumber",
+			"indexer.handlers", "indexer.sink.write", "indexer.emit",
+		}, children)
+
+		for _, s := range spans {
+			if s.Parent.SpanID() != w.SpanContext.SpanID() {
+				continue
+			}
+			switch s.Name {
+			case "indexer.fetch":
+				assert.ElementsMatch(t, []string{"indexer.retry.attempt", "eth_getLogs"}, childrenOf(spans, s.SpanContext))
+			case "indexer.sink.write":
+				assert.Equal(t, "*core.memorySink", spanAttr(s, AttrSink).AsString())
+				assert.Equal(t, int64(10), spanAttr(s, AttrLogs).AsInt64())
+			case "indexer.emit":
+				assert.Equal(t, int64(10), spanAttr(s, AttrLogs).AsInt64())
+			}
+		}
+	}
+	assert.ElementsMatch(t, [][2]int64{{1, 10}, {11, 20}}, ranges)
+
+	// eth_blockNumber is outside any window, a child of the run
+	for _, s := range spans {
+		if s.Name == "eth_blockNumber" {
+			assert.Equal(t, trace.SpanKindClient, s.SpanKind)
+			assert.Equal(t, "127.0.0.1", spanAttr(s, "server.address").AsString())
+		}
+	}
+}
+
+func TestTracing_RetryAttempts(t *testing.T) {
+	healthy := newChainServer(100)
+	defer healthy.Close()
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		healthy.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	exporter, tp := newTestTracer()
+	rpc := NewHTTPRPC(srv.URL, 0)
+	cfg := DefaultRetryConfig()
+	cfg.InitialBackoff = time.Millisecond
+
+	ctx, root := tp.Tracer("test").Start(context.Background(), "head")
+	err := RetryWithBackoff(ctx, cfg, func() error {
+		_, err := rpc.Head(ctx)
+		return err
+	})
+	root.End()
+	assert.NoError(t, err)
+
+	var attempts, requests tracetest.SpanStubs
+	for _, s := range exporter.GetSpans() {
+		switch s.Name {
+		case "indexer.retry.attempt":
+			attempts = append(attempts, s)
+		case "eth_blockNumber":
+			requests = append(requests, s)
+		}
+	}
+	if assert.Len(t, attempts, 2) && assert.Len(t, requests, 2) {
+		assert.Equal(t, int64(1), spanAttr(attempts[0], AttrAttempt).AsInt64())
+		assert.Equal(t, codes.Error, attempts[0].Status.Code)
+		assert.Equal(t, int64(2), spanAttr(attempts[1], AttrAttempt).AsInt64())
+		assert.Equal(t, codes.Unset, attempts[1].Status.Code)
+
+		assert.Equal(t, codes.Error, requests[0].Status.Code)
+		assert.Len(t, requests[0].Events, 1, "the error is recorded")
+		assert.Equal(t, root.SpanContext().SpanID(), requests[1].Parent.SpanID())
+	}
+
+	// Calls without a span in their context are not traced
+	exporter.Reset()
+	_, err = rpc.Head(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, exporter.GetSpans())
+}
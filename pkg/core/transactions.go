@@ -0,0 +1,268 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// newCallFilter parses Options.Selectors, signatures are registered in the returned decoder.
+func newCallFilter(opts *Options, rpc RPC) ([][4]byte, *Decoder, error) {
+	if opts.FetchMode != FetchModeTransactions {
+		if len(opts.Selectors) > 0 || opts.Traces != "" {
+			return nil, nil, fmt.Errorf("selectors and traces require the transactions fetch mode")
+		}
+		return nil, nil, nil
+	}
+	if len(opts.Factories) > 0 {
+		return nil, nil, fmt.Errorf("factories are not supported in transactions mode")
+	}
+	if _, ok := rpc.(TransactionRPC); !ok {
+		return nil, nil, fmt.Errorf("transactions mode requires an RPC implementing TransactionRPC")
+	}
+	switch opts.Traces {
+	case "":
+	case TraceDebug, TraceParity:
+		if _, ok := rpc.(TraceRPC); !ok {
+			return nil, nil, fmt.Errorf("traces require an RPC implementing TraceRPC")
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported trace method %q", opts.Traces)
+	}
+
+	decoder := NewDecoder()
+	for _, e := range opts.Errors {
+		if _, err := decoder.RegisterError(e); err != nil {
+			return nil, nil, err
+		}
+	}
+	selectors := make([][4]byte, 0, len(opts.Selectors))
+	for _, s := range opts.Selectors {
+		var sel [4]byte
+		if raw, ok := strings.CutPrefix(s, "0x"); ok && len(raw) == 8 {
+			if _, err := hex.Decode(sel[:], []byte(raw)); err != nil {
+				return nil, nil, fmt.Errorf("invalid selector %q: %w", s, err)
+			}
+		} else {
+			fn, err := decoder.RegisterFunction(s)
+			if err != nil {
+				return nil, nil, err
+			}
+			sel = fn.Selector()
+		}
+		selectors = append(selectors, sel)
+	}
+	return selectors, decoder, nil
+}
+
+// Calls returns the channel transactions mode emits calls on, in block order.
+func (p *Processor) Calls(chainId string) (<-chan Call, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	chain, exists := p.chains[chainId]
+	if !exists {
+		return nil, fmt.Errorf("chain %s not found", chainId)
+	}
+	if chain.callsCh == nil {
+		return nil, fmt.Errorf("chain %s is not in transactions mode", chainId)
+	}
+	return chain.callsCh, nil
+}
+
+// matchesCall checks a callee and its calldata against Options.Addresses and Options.Selectors.
+func (c *chainState) matchesCall(to string, input []byte) bool {
+	if len(c.addresses) > 0 {
+		addr, err := ParseAddressBytes(to)
+		if err != nil {
+			// Contract creation
+			return false
+		}
+		found := false
+		for _, a := range c.addresses {
+			if a == addr {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(c.selectors) == 0 {
+		return true
+	}
+	if len(input) < 4 {
+		return false
+	}
+	for _, sel := range c.selectors {
+		if [4]byte(input[:4]) == sel {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchCalls collects the matching transactions, and internal calls when tracing, of blocks [from..to].
+func (p *Processor) fetchCalls(ctx context.Context, from uint64, to uint64, chain *chainState) ([]Call, error) {
+	rpc := chain.chainInfo.RPC.(TransactionRPC)
+
+	var calls []Call
+	for blockNum := from; blockNum <= to; blockNum++ {
+		number := Uint64ToHexQty(blockNum)
+		block, err := rpc.GetFullBlock(ctx, number)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get block %d with transactions: %w", blockNum, err)
+		}
+		blockHash := block.Hash
+
+		var blockCalls []Call
+		for _, tx := range block.Transactions {
+			var fp fieldParser
+			c := Call{
+				BlockNumber:      blockNum,
+				BlockHash:        blockHash,
+				TransactionHash:  tx.Hash,
+				TransactionIndex: fp.qty("transactionIndex", tx.TransactionIndex),
+				Type:             "CALL",
+				From:             tx.From,
+				To:               tx.To,
+				Input:            fp.bytes("input", tx.Input),
+				Value:            fp.big("value", tx.Value),
+				Gas:              fp.qty("gas", tx.Gas),
+			}
+			if fp.err != nil {
+				return nil, fmt.Errorf("invalid transaction %s: %w", tx.Hash, fp.err)
+			}
+			if c.To == "" {
+				c.Type = "CREATE"
+			}
+			if chain.matchesCall(c.To, c.Input) {
+				blockCalls = append(blockCalls, c)
+			}
+		}
+
+		// Failed transactions emit no log, their status is only in the receipt
+		if len(blockCalls) > 0 {
+			receipts, err := chain.chainInfo.RPC.GetBlockReceipts(ctx, number)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get receipts for block %d: %w", blockNum, err)
+			}
+			if chain.opts.VerifyIntegrity {
+				if err := VerifyReceipts(block.Block, receipts); err != nil {
+					return nil, err
+				}
+			}
+			if err := applyReceipts(blockCalls, receipts); err != nil {
+				return nil, err
+			}
+		}
+
+		if chain.opts.Traces != "" {
+			internal, outputs, err := p.fetchInternalCalls(ctx, blockNum, blockHash, chain)
+			if err != nil {
+				return nil, err
+			}
+			// Receipts don't carry the revert data of failed transactions, their trace does
+			for i := range blockCalls {
+				if !blockCalls[i].Success {
+					blockCalls[i].Output = outputs[strings.ToLower(blockCalls[i].TransactionHash)]
+				}
+			}
+			blockCalls = append(blockCalls, internal...)
+			// Transactions first, then their internal calls depth first
+			sort.SliceStable(blockCalls, func(i, j int) bool {
+				return blockCalls[i].TransactionIndex < blockCalls[j].TransactionIndex
+			})
+		}
+
+		calls = append(calls, blockCalls...)
+	}
+
+	for i := range calls {
+		if len(calls[i].Input) >= 4 {
+			calls[i].Decoded, _ = chain.callDecoder.DecodeCall(calls[i].Input)
+		}
+		if !calls[i].Success && len(calls[i].Output) > 0 {
+			calls[i].Revert, _ = chain.callDecoder.DecodeRevert(calls[i].Output)
+		}
+	}
+	return calls, nil
+}
+
+// fetchInternalCalls traces a block and keeps the matching internal calls.
+// It also returns the output of failed transactions by lower-case hash.
+func (p *Processor) fetchInternalCalls(ctx context.Context, blockNum uint64, blockHash string, chain *chainState) ([]Call, map[string][]byte, error) {
+	traces, err := chain.chainInfo.RPC.(TraceRPC).TraceBlock(ctx, Uint64ToHexQty(blockNum), chain.opts.Traces)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to trace block %d: %w", blockNum, err)
+	}
+
+	var calls []Call
+	outputs := make(map[string][]byte)
+	for _, t := range traces {
+		// The top level frame is the transaction itself
+		if len(t.TraceAddress) == 0 {
+			if t.Error != "" {
+				if output, err := hexToBytes(t.Output); err == nil && len(output) > 0 {
+					outputs[strings.ToLower(t.TransactionHash)] = output
+				}
+			}
+			continue
+		}
+
+		var fp fieldParser
+		c := Call{
+			BlockNumber:      blockNum,
+			BlockHash:        blockHash,
+			TransactionHash:  t.TransactionHash,
+			TransactionIndex: t.TransactionIndex,
+			TraceAddress:     t.TraceAddress,
+			Type:             t.Type,
+			From:             t.From,
+			To:               t.To,
+			Input:            fp.bytes("input", t.Input),
+			Output:           fp.bytes("output", t.Output),
+			Value:            fp.big("value", t.Value),
+			Gas:              fp.qty("gas", t.Gas),
+			GasUsed:          fp.qty("gasUsed", t.GasUsed),
+			Error:            t.Error,
+			Success:          t.Error == "",
+		}
+		if fp.err != nil {
+			return nil, nil, fmt.Errorf("invalid trace of transaction %s: %w", t.TransactionHash, fp.err)
+		}
+		if chain.matchesCall(c.To, c.Input) {
+			calls = append(calls, c)
+		}
+	}
+	return calls, outputs, nil
+}
+
+// applyReceipts fills the status and gas used of transaction calls from the block receipts.
+func applyReceipts(calls []Call, receipts []Receipt) error {
+	byHash := make(map[string]Receipt, len(receipts))
+	for _, r := range receipts {
+		byHash[strings.ToLower(r.TransactionHash)] = r
+	}
+
+	for i := range calls {
+		r, ok := byHash[strings.ToLower(calls[i].TransactionHash)]
+		if !ok {
+			return fmt.Errorf("missing receipt for transaction %s", calls[i].TransactionHash)
+		}
+		tr, err := r.Typed()
+		if err != nil {
+			return fmt.Errorf("invalid receipt %s: %w", r.TransactionHash, err)
+		}
+		calls[i].Success = tr.Root != nil || tr.Status == 1
+		calls[i].GasUsed = tr.GasUsed
+		if tr.ContractAddress != nil {
+			calls[i].To = tr.ContractAddress.Hex()
+		}
+	}
+	return nil
+}
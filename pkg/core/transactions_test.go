@@ -0,0 +1,306 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testToken  = "0xdac17f958d2ee523a2206206994597c13d831ec7"
+	testRouter = "0x7a250d5630b4cf539739df2c5dacb4c659f2488d"
+	testSender = "0x28c6c06298d514db089934071355e5743bf21d60"
+)
+
+// transferInput encodes transfer(address,uint256) calldata.
+func transferInput(to string, amount uint64) string {
+	return "0xa9059cbb" + strings.Repeat("0", 24) + strings.TrimPrefix(to, "0x") + fmt.Sprintf("%064x", amount)
+}
+
+type testTx struct {
+	block  uint64
+	to     string
+	input  string
+	failed bool
+	// Revert data of a failed transaction, in its trace
+	output string
+	// debug callTracer subcalls
+	calls []map[string]any
+}
+
+func (tx testTx) hash(index int) string {
+	return fmt.Sprintf("0x%062x%02x", tx.block, index)
+}
+
+func newTransactionServer(head uint64, txs []testTx) *httptest.Server {
+	type rpcReq struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+
+	blockTxs := func(blockNum uint64) []testTx {
+		var out []testTx
+		for _, tx := range txs {
+			if tx.block == blockNum {
+				out = append(out, tx)
+			}
+		}
+		return out
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body, _ := io.ReadAll(r.Body)
+		var req rpcReq
+		json.Unmarshal(body, &req)
+
+		var number string
+		if len(req.Params) > 0 {
+			json.Unmarshal(req.Params[0], &number)
+		}
+		blockNum, _ := HexQtyToUint64(number)
+
+		var result any
+		switch req.Method {
+		case "eth_blockNumber":
+			result = Uint64ToHexQty(head)
+		case "eth_getBlockByNumber":
+			block := map[string]any{
+				"number":     number,
+				"hash":       fmt.Sprintf("0x%064x", blockNum),
+				"parentHash": fmt.Sprintf("0x%064x", blockNum-1),
+				"timestamp":  Uint64ToHexQty(1700000000 + blockNum*12),
+			}
+			var full bool
+			json.Unmarshal(req.Params[1], &full)
+			if full {
+				list := []map[string]any{}
+				for i, tx := range blockTxs(blockNum) {
+					list = append(list, map[string]any{
+						"hash":             tx.hash(i),
+						"from":             testSender,
+						"to":               tx.to,
+						"input":            tx.input,
+						"value":            "0x0",
+						"gas":              "0x5208",
+						"nonce":            Uint64ToHexQty(uint64(i)),
+						"blockNumber":      number,
+						"blockHash":        block["hash"],
+						"transactionIndex": Uint64ToHexQty(uint64(i)),
+					})
+				}
+				block["transactions"] = list
+			}
+			result = block
+		case "eth_getBlockReceipts":
+			list := []map[string]any{}
+			for i, tx := range blockTxs(blockNum) {
+				status := "0x1"
+				if tx.failed {
+					status = "0x0"
+				}
+				list = append(list, map[string]any{
+					"transactionHash":  tx.hash(i),
+					"transactionIndex": Uint64ToHexQty(uint64(i)),
+					"blockNumber":      number,
+					"from":             testSender,
+					"to":               tx.to,
+					"status":           status,
+					"gasUsed":          "0x5000",
+					"logs":             []any{},
+				})
+			}
+			result = list
+		case "debug_traceBlockByNumber":
+			list := []map[string]any{}
+			for i, tx := range blockTxs(blockNum) {
+				frame := map[string]any{
+					"type":  "CALL",
+					"from":  testSender,
+					"to":    tx.to,
+					"input": tx.input,
+					"calls": tx.calls,
+				}
+				if tx.failed {
+					frame["error"] = "execution reverted"
+					frame["output"] = tx.output
+				}
+				list = append(list, map[string]any{"txHash": tx.hash(i), "result": frame})
+			}
+			result = list
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": result})
+	}))
+}
+
+func collectCalls(t *testing.T, ctx context.Context, ch <-chan Call, n int) []Call {
+	var got []Call
+	for len(got) < n {
+		select {
+		case c := <-ch:
+			got = append(got, c)
+		case <-ctx.Done():
+			t.Fatalf("Test timeout, got %d calls", len(got))
+		}
+	}
+	return got
+}
+
+func TestTransactions_MatchesFailedCalls(t *testing.T) {
+	srv := newTransactionServer(10, []testTx{
+		{block: 2, to: testToken, input: transferInput(testSender, 100)},
+		{block: 3, to: testOther, input: transferInput(testSender, 1)},
+		{block: 3, to: testToken, input: transferInput(testSender, 200), failed: true},
+		// approve(address,uint256)
+		{block: 5, to: testToken, input: "0x095ea7b3"},
+		{block: 7, to: testToken, input: transferInput(testOther, 300)},
+	})
+	defer srv.Close()
+
+	sink := &memorySink{}
+	processor := NewProcessor()
+	err := processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:          3,
+		FetcherConcurrency: 2,
+		LogsBufferSize:     10,
+		FetchMode:          FetchModeTransactions,
+		Addresses:          []string{testToken},
+		Selectors:          []string{"transfer(address to, uint256 amount)"},
+		Sinks:              []Sink{sink},
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go processor.Run(ctx)
+
+	callsCh, err := processor.Calls("1")
+	assert.NoError(t, err)
+	got := collectCalls(t, ctx, callsCh, 3)
+	assert.NoError(t, processor.Stop(ctx))
+
+	assert.Equal(t, []uint64{2, 3, 7}, []uint64{got[0].BlockNumber, got[1].BlockNumber, got[2].BlockNumber})
+	assert.Equal(t, []bool{true, false, true}, []bool{got[0].Success, got[1].Success, got[2].Success})
+	assert.Equal(t, uint64(1), got[1].TransactionIndex)
+	assert.Equal(t, uint64(0x5000), got[1].GasUsed)
+
+	decoded := got[2].Decoded
+	if assert.NotNil(t, decoded) {
+		assert.Equal(t, "transfer(address,uint256)", decoded.Signature)
+		to, _ := ParseAddressBytes(testOther)
+		assert.Equal(t, to, decoded.Args["to"])
+		assert.Equal(t, big.NewInt(300), decoded.Args["amount"])
+	}
+
+	sink.mu.Lock()
+	var committed int
+	for _, b := range sink.batches {
+		committed += len(b.Calls)
+	}
+	sink.mu.Unlock()
+	assert.Equal(t, 3, committed)
+}
+
+func TestTransactions_InternalCalls(t *testing.T) {
+	srv := newTransactionServer(4, []testTx{
+		{block: 2, to: testRouter, input: "0x38ed1739", calls: []map[string]any{
+			{"type": "STATICCALL", "from": testRouter, "to": testOther, "input": "0x0902f1ac"},
+			{"type": "CALL", "from": testRouter, "to": testPoolA, "input": "0x022c0d9f", "calls": []map[string]any{
+				{"type": "CALL", "from": testPoolA, "to": testToken, "input": transferInput(testSender, 42), "gasUsed": "0x3a98"},
+			}},
+		}},
+		{block: 3, to: testToken, input: transferInput(testRouter, 7), failed: true},
+	})
+	defer srv.Close()
+
+	processor := NewProcessor()
+	err := processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:      2,
+		LogsBufferSize: 10,
+		FetchMode:      FetchModeTransactions,
+		Addresses:      []string{testToken},
+		Selectors:      []string{"transfer(address,uint256)"},
+		Traces:         TraceDebug,
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go processor.Run(ctx)
+
+	callsCh, _ := processor.Calls("1")
+	got := collectCalls(t, ctx, callsCh, 2)
+	assert.NoError(t, processor.Stop(ctx))
+
+	internal := got[0]
+	assert.Equal(t, uint64(2), internal.BlockNumber)
+	assert.Equal(t, []int{1, 0}, internal.TraceAddress)
+	assert.Equal(t, testPoolA, internal.From)
+	assert.Equal(t, uint64(0x3a98), internal.GasUsed)
+	assert.True(t, internal.Success)
+	if assert.NotNil(t, internal.Decoded) {
+		assert.Equal(t, big.NewInt(42), internal.Decoded.Values[1])
+	}
+
+	assert.Empty(t, got[1].TraceAddress)
+	assert.False(t, got[1].Success)
+}
+
+func TestTransactions_InvalidConfig(t *testing.T) {
+	processor := NewProcessor()
+
+	// Selectors without transactions mode
+	err := processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC("http://localhost", 0)}, &Options{
+		RangeSize: 5,
+		Selectors: []string{"0xa9059cbb"},
+	})
+	assert.Error(t, err)
+
+	err = processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC("http://localhost", 0)}, &Options{
+		RangeSize: 5,
+		FetchMode: FetchModeTransactions,
+		Traces:    "replay",
+	})
+	assert.Error(t, err)
+
+	err = processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC("http://localhost", 0)}, &Options{
+		RangeSize: 5,
+		FetchMode: FetchModeTransactions,
+		Selectors: []string{"transfer(address,uint256"},
+	})
+	assert.Error(t, err)
+
+	_, err = processor.Calls("1")
+	assert.Error(t, err)
+}
+
+func TestHTTPRPC_TraceBlockParity(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":[
+			{"action":{"callType":"call","from":"0x01","to":"0x02","gas":"0x10","input":"0xabcdef01","value":"0x0"},"result":{"gasUsed":"0x8","output":"0x"},"traceAddress":[],"transactionHash":"0xaa","transactionPosition":0,"type":"call"},
+			{"action":{"callType":"delegatecall","from":"0x02","to":"0x03","gas":"0x8","input":"0x12345678","value":"0x0"},"error":"Reverted","traceAddress":[0],"transactionHash":"0xaa","transactionPosition":0,"type":"call"},
+			{"action":{"from":"0x02","gas":"0x8","init":"0x6080","value":"0x0"},"result":{"gasUsed":"0x4","address":"0x04","code":"0x"},"traceAddress":[1],"transactionHash":"0xaa","transactionPosition":0,"type":"create"},
+			{"action":{"author":"0x05","rewardType":"block","value":"0x1"},"traceAddress":[],"type":"reward"}
+		]}`))
+	}))
+	defer srv.Close()
+
+	traces, err := NewHTTPRPC(srv.URL, 0).TraceBlock(context.Background(), "0x1", TraceParity)
+	assert.NoError(t, err)
+	assert.Len(t, traces, 3)
+	assert.Equal(t, "DELEGATECALL", traces[1].Type)
+	assert.Equal(t, "Reverted", traces[1].Error)
+	assert.Equal(t, "CREATE", traces[2].Type)
+	assert.Equal(t, "0x04", traces[2].To)
+	assert.Equal(t, "0x6080", traces[2].Input)
+}
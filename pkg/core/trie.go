@@ -0,0 +1,131 @@
+package core
+
+import (
+	"bytes"
+	"sort"
+)
+
+// EmptyRootHash is the root of a Merkle-Patricia trie without any entries,
+// e.g. the receiptsRoot of a block with no transactions.
+const EmptyRootHash = "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421"
+
+type trieEntry struct {
+	key   []byte // nibbles
+	value []byte
+}
+
+// trieRoot computes the root hash of a Merkle-Patricia trie holding the given key/value pairs.
+// The trie is built in memory in one pass, nothing is persisted.
+func trieRoot(pairs map[string][]byte) []byte {
+	entries := make([]trieEntry, 0, len(pairs))
+	for k, v := range pairs {
+		entries = append(entries, trieEntry{key: keyToNibbles([]byte(k)), value: v})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	return Keccak256(trieNode(entries, 0))
+}
+
+// deriveListRoot computes the trie root of an ordered list, keyed by rlp(index).
+// This is how transactionsRoot and receiptsRoot are derived.
+func deriveListRoot(values [][]byte) []byte {
+	pairs := make(map[string][]byte, len(values))
+	for i, v := range values {
+		pairs[string(rlpEncodeUint(uint64(i)))] = v
+	}
+	return trieRoot(pairs)
+}
+
+// trieNode returns the RLP encoding of the node holding entries, which all share the first depth nibbles.
+func trieNode(entries []trieEntry, depth int) []byte {
+	switch len(entries) {
+	case 0:
+		return rlpEncodeBytes(nil)
+	case 1:
+		return rlpEncodeList(
+			rlpEncodeBytes(hexPrefix(entries[0].key[depth:], true)),
+			rlpEncodeBytes(entries[0].value),
+		)
+	}
+
+	// Extension node when every key shares more nibbles
+	prefix := commonPrefixLen(entries, depth)
+	if prefix > 0 {
+		return rlpEncodeList(
+			rlpEncodeBytes(hexPrefix(entries[0].key[depth:depth+prefix], false)),
+			trieRef(trieNode(entries, depth+prefix)),
+		)
+	}
+
+	// Branch node, entries are sorted so children are contiguous runs
+	items := make([][]byte, 17)
+	var value []byte
+	i := 0
+	if len(entries[0].key) == depth {
+		value = entries[0].value
+		i = 1
+	}
+	for nibble := byte(0); nibble < 16; nibble++ {
+		j := i
+		for j < len(entries) && entries[j].key[depth] == nibble {
+			j++
+		}
+		if j == i {
+			items[nibble] = rlpEncodeBytes(nil)
+			continue
+		}
+		items[nibble] = trieRef(trieNode(entries[i:j], depth+1))
+		i = j
+	}
+	items[16] = rlpEncodeBytes(value)
+
+	return rlpEncodeList(items...)
+}
+
+// trieRef embeds small nodes inline and references bigger ones by hash.
+func trieRef(node []byte) []byte {
+	if len(node) < 32 {
+		return node
+	}
+	return rlpEncodeBytes(Keccak256(node))
+}
+
+func commonPrefixLen(entries []trieEntry, depth int) int {
+	first := entries[0].key[depth:]
+	last := entries[len(entries)-1].key[depth:]
+	n := 0
+	for n < len(first) && n < len(last) && first[n] == last[n] {
+		n++
+	}
+	return n
+}
+
+func keyToNibbles(key []byte) []byte {
+	nibbles := make([]byte, len(key)*2)
+	for i, b := range key {
+		nibbles[i*2] = b >> 4
+		nibbles[i*2+1] = b & 0x0f
+	}
+	return nibbles
+}
+
+// hexPrefix is the compact encoding of a nibble path with the leaf flag.
+func hexPrefix(nibbles []byte, leaf bool) []byte {
+	var flag byte
+	if leaf {
+		flag = 2
+	}
+	out := make([]byte, 0, len(nibbles)/2+1)
+	if len(nibbles)%2 == 1 {
+		out = append(out, (flag+1)<<4|nibbles[0])
+		nibbles = nibbles[1:]
+	} else {
+		out = append(out, flag<<4)
+	}
+	for i := 0; i < len(nibbles); i += 2 {
+		out = append(out, nibbles[i]<<4|nibbles[i+1])
+	}
+	return out
+}
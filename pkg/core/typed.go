@@ -0,0 +1,368 @@
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Hash is a 32 bytes keccak256 hash, block hash, transaction hash or log topic.
+type Hash [32]byte
+
+// AddressBytes is a 20 bytes account address.
+type AddressBytes [20]byte
+
+// ParseHash decodes a 0x-prefixed 32 bytes hex string.
+func ParseHash(s string) (Hash, error) {
+	var h Hash
+	if err := decodeFixedHex(s, h[:]); err != nil {
+		return h, fmt.Errorf("invalid hash: %w", err)
+	}
+	return h, nil
+}
+
+// ParseAddressBytes decodes a 0x-prefixed 20 bytes hex string. Checksum casing is ignored.
+func ParseAddressBytes(s string) (AddressBytes, error) {
+	var a AddressBytes
+	if err := decodeFixedHex(s, a[:]); err != nil {
+		return a, fmt.Errorf("invalid address: %w", err)
+	}
+	return a, nil
+}
+
+func decodeFixedHex(s string, dst []byte) error {
+	raw := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if len(raw) != len(dst)*2 {
+		return fmt.Errorf("%q has %d hex chars, expected %d", s, len(raw), len(dst)*2)
+	}
+	_, err := hex.Decode(dst, []byte(raw))
+	return err
+}
+
+func (h Hash) Bytes() []byte  { return h[:] }
+func (h Hash) Hex() string    { return "0x" + hex.EncodeToString(h[:]) }
+func (h Hash) String() string { return h.Hex() }
+
+func (a AddressBytes) Bytes() []byte  { return a[:] }
+func (a AddressBytes) Hex() string    { return "0x" + hex.EncodeToString(a[:]) }
+func (a AddressBytes) String() string { return a.Hex() }
+
+func (h Hash) MarshalText() ([]byte, error) { return []byte(h.Hex()), nil }
+func (h *Hash) UnmarshalText(text []byte) error {
+	parsed, err := ParseHash(string(text))
+	if err != nil {
+		return err
+	}
+	*h = parsed
+	return nil
+}
+
+func (a AddressBytes) MarshalText() ([]byte, error) { return []byte(a.Hex()), nil }
+func (a *AddressBytes) UnmarshalText(text []byte) error {
+	parsed, err := ParseAddressBytes(string(text))
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// TypedBlock is the parsed form of Block.
+// Absent optional fields are zero values, BaseFeePerGas is nil before London.
+type TypedBlock struct {
+	Number           uint64
+	Hash             Hash
+	ParentHash       Hash
+	Timestamp        time.Time
+	LogsBloom        Bloom
+	TransactionsRoot Hash
+	StateRoot        Hash
+	ReceiptsRoot     Hash
+	Miner            AddressBytes
+	GasLimit         uint64
+	GasUsed          uint64
+	BaseFeePerGas    *big.Int
+	ExtraData        []byte
+}
+
+// TypedLog is the parsed form of Log.
+type TypedLog struct {
+	Address          AddressBytes
+	Topics           []Hash
+	Data             []byte
+	BlockNumber      uint64
+	TransactionHash  Hash
+	TransactionIndex uint64
+	BlockHash        Hash
+	LogIndex         uint64
+	Removed          bool
+	Context          *DecodeContext
+}
+
+// TypedReceipt is the parsed form of Receipt.
+// ContractAddress is nil unless the transaction created a contract, To is nil when it did.
+// Root is only set on pre-Byzantium receipts.
+type TypedReceipt struct {
+	BlockHash         Hash
+	BlockNumber       uint64
+	ContractAddress   *AddressBytes
+	CumulativeGasUsed uint64
+	EffectiveGasPrice *big.Int
+	From              AddressBytes
+	GasUsed           uint64
+	Logs              []TypedLog
+	LogsBloom         Bloom
+	Status            uint64
+	Root              *Hash
+	To                *AddressBytes
+	TransactionHash   Hash
+	TransactionIndex  uint64
+	Type              uint8
+}
+
+// fieldParser collects the first parse error so conversions read field by field.
+type fieldParser struct {
+	err error
+}
+
+func (p *fieldParser) qty(name, s string) uint64 {
+	if s == "" || p.err != nil {
+		return 0
+	}
+	n, err := HexQtyToUint64(s)
+	if err != nil {
+		p.err = fmt.Errorf("invalid %s %q: %w", name, s, err)
+	}
+	return n
+}
+
+func (p *fieldParser) big(name, s string) *big.Int {
+	if s == "" || p.err != nil {
+		return nil
+	}
+	n, err := hexToBig(s)
+	if err != nil {
+		p.err = fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return n
+}
+
+func (p *fieldParser) hash(name, s string) Hash {
+	if s == "" || p.err != nil {
+		return Hash{}
+	}
+	h, err := ParseHash(s)
+	if err != nil {
+		p.err = fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return h
+}
+
+func (p *fieldParser) address(name, s string) AddressBytes {
+	if s == "" || p.err != nil {
+		return AddressBytes{}
+	}
+	a, err := ParseAddressBytes(s)
+	if err != nil {
+		p.err = fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return a
+}
+
+func (p *fieldParser) bytes(name, s string) []byte {
+	if s == "" || p.err != nil {
+		return nil
+	}
+	b, err := hexToBytes(s)
+	if err != nil {
+		p.err = fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return b
+}
+
+func (p *fieldParser) bloom(s string) Bloom {
+	if s == "" || p.err != nil {
+		return Bloom{}
+	}
+	b, err := ParseBloom(s)
+	if err != nil {
+		p.err = err
+	}
+	return b
+}
+
+// Typed parses the block header.
+func (b Block) Typed() (TypedBlock, error) {
+	var p fieldParser
+	tb := TypedBlock{
+		Number:           p.qty("number", b.Number),
+		Hash:             p.hash("hash", b.Hash),
+		ParentHash:       p.hash("parentHash", b.ParentHash),
+		Timestamp:        time.Unix(int64(p.qty("timestamp", b.Timestamp)), 0).UTC(),
+		LogsBloom:        p.bloom(b.LogsBloom),
+		TransactionsRoot: p.hash("transactionsRoot", b.TransactionsRoot),
+		StateRoot:        p.hash("stateRoot", b.StateRoot),
+		ReceiptsRoot:     p.hash("receiptsRoot", b.ReceiptsRoot),
+		Miner:            p.address("miner", b.Miner),
+		GasLimit:         p.qty("gasLimit", b.GasLimit),
+		GasUsed:          p.qty("gasUsed", b.GasUsed),
+		BaseFeePerGas:    p.big("baseFeePerGas", b.BaseFeePerGas),
+		ExtraData:        p.bytes("extraData", b.ExtraData),
+	}
+	return tb, p.err
+}
+
+// Wire formats the block back to its JSON-RPC form.
+func (b TypedBlock) Wire() Block {
+	return Block{
+		Number:           Uint64ToHexQty(b.Number),
+		Hash:             b.Hash.Hex(),
+		ParentHash:       b.ParentHash.Hex(),
+		Timestamp:        Uint64ToHexQty(uint64(b.Timestamp.Unix())),
+		LogsBloom:        b.LogsBloom.String(),
+		TransactionsRoot: b.TransactionsRoot.Hex(),
+		StateRoot:        b.StateRoot.Hex(),
+		ReceiptsRoot:     b.ReceiptsRoot.Hex(),
+		Miner:            b.Miner.Hex(),
+		GasLimit:         Uint64ToHexQty(b.GasLimit),
+		GasUsed:          Uint64ToHexQty(b.GasUsed),
+		BaseFeePerGas:    bigToHexQty(b.BaseFeePerGas),
+		ExtraData:        "0x" + hex.EncodeToString(b.ExtraData),
+	}
+}
+
+// Typed parses the log.
+func (l Log) Typed() (TypedLog, error) {
+	var p fieldParser
+	tl := TypedLog{
+		Address:          p.address("address", l.Address),
+		Data:             p.bytes("data", l.Data),
+		BlockNumber:      p.qty("blockNumber", l.BlockNumber),
+		TransactionHash:  p.hash("transactionHash", l.TransactionHash),
+		TransactionIndex: p.qty("transactionIndex", l.TransactionIndex),
+		BlockHash:        p.hash("blockHash", l.BlockHash),
+		LogIndex:         p.qty("logIndex", l.LogIndex),
+		Removed:          l.Removed,
+		Context:          l.Context,
+	}
+	if p.err != nil {
+		return tl, p.err
+	}
+
+	tl.Topics = make([]Hash, len(l.Topics))
+	for i, t := range l.Topics {
+		s, ok := t.(string)
+		if !ok {
+			return tl, fmt.Errorf("invalid topic %v", t)
+		}
+		tl.Topics[i] = p.hash("topic", s)
+	}
+	return tl, p.err
+}
+
+// Wire formats the log back to its JSON-RPC form.
+func (l TypedLog) Wire() Log {
+	topics := make([]any, len(l.Topics))
+	for i, t := range l.Topics {
+		topics[i] = t.Hex()
+	}
+	return Log{
+		Address:          l.Address.Hex(),
+		Topics:           topics,
+		Data:             "0x" + hex.EncodeToString(l.Data),
+		BlockNumber:      Uint64ToHexQty(l.BlockNumber),
+		TransactionHash:  l.TransactionHash.Hex(),
+		TransactionIndex: Uint64ToHexQty(l.TransactionIndex),
+		BlockHash:        l.BlockHash.Hex(),
+		LogIndex:         Uint64ToHexQty(l.LogIndex),
+		Removed:          l.Removed,
+		Context:          l.Context,
+	}
+}
+
+// Typed parses the receipt and its logs.
+func (r Receipt) Typed() (TypedReceipt, error) {
+	var p fieldParser
+	tr := TypedReceipt{
+		BlockHash:         p.hash("blockHash", r.BlockHash),
+		BlockNumber:       p.qty("blockNumber", r.BlockNumber),
+		CumulativeGasUsed: p.qty("cumulativeGasUsed", r.CumulativeGasUsed),
+		EffectiveGasPrice: p.big("effectiveGasPrice", r.EffectiveGasPrice),
+		From:              p.address("from", r.From),
+		GasUsed:           p.qty("gasUsed", r.GasUsed),
+		LogsBloom:         p.bloom(r.LogsBloom),
+		Status:            p.qty("status", r.Status),
+		TransactionHash:   p.hash("transactionHash", r.TransactionHash),
+		TransactionIndex:  p.qty("transactionIndex", r.TransactionIndex),
+		Type:              uint8(p.qty("type", r.Type)),
+	}
+	if r.ContractAddress != nil && *r.ContractAddress != "" {
+		addr := p.address("contractAddress", *r.ContractAddress)
+		tr.ContractAddress = &addr
+	}
+	if r.To != "" {
+		to := p.address("to", r.To)
+		tr.To = &to
+	}
+	if r.Root != "" {
+		root := p.hash("root", r.Root)
+		tr.Root = &root
+	}
+	if p.err != nil {
+		return tr, p.err
+	}
+
+	tr.Logs = make([]TypedLog, len(r.Logs))
+	for i, l := range r.Logs {
+		tl, err := l.Typed()
+		if err != nil {
+			return tr, fmt.Errorf("invalid log %d: %w", i, err)
+		}
+		tr.Logs[i] = tl
+	}
+	return tr, nil
+}
+
+// Wire formats the receipt back to its JSON-RPC form.
+func (r TypedReceipt) Wire() Receipt {
+	logs := make([]Log, len(r.Logs))
+	for i, l := range r.Logs {
+		logs[i] = l.Wire()
+	}
+	wire := Receipt{
+		BlockHash:         r.BlockHash.Hex(),
+		BlockNumber:       Uint64ToHexQty(r.BlockNumber),
+		CumulativeGasUsed: Uint64ToHexQty(r.CumulativeGasUsed),
+		EffectiveGasPrice: bigToHexQty(r.EffectiveGasPrice),
+		From:              r.From.Hex(),
+		GasUsed:           Uint64ToHexQty(r.GasUsed),
+		Logs:              logs,
+		LogsBloom:         r.LogsBloom.String(),
+		TransactionHash:   r.TransactionHash.Hex(),
+		TransactionIndex:  Uint64ToHexQty(r.TransactionIndex),
+		Type:              Uint64ToHexQty(uint64(r.Type)),
+	}
+	if r.Root != nil {
+		wire.Root = r.Root.Hex()
+	} else {
+		wire.Status = Uint64ToHexQty(r.Status)
+	}
+	if r.ContractAddress != nil {
+		addr := r.ContractAddress.Hex()
+		wire.ContractAddress = &addr
+	}
+	if r.To != nil {
+		wire.To = r.To.Hex()
+	}
+	return wire
+}
+
+// bigToHexQty formats n as a hex quantity, nil stays absent.
+func bigToHexQty(n *big.Int) string {
+	if n == nil {
+		return ""
+	}
+	return "0x" + n.Text(16)
+}
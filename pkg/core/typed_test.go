@@ -0,0 +1,103 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedBlock_RoundTrip(t *testing.T) {
+	block := Block{
+		Number:           "0x12d687",
+		Hash:             "0x4c6f9f6b3e5c3b9d8f2a6a8e3c4e1d3d7d5f7b1a9c0e2f4a6b8c0d2e4f6a8b0c",
+		ParentHash:       "0x1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f809",
+		Timestamp:        "0x6553f100",
+		LogsBloom:        testReceiptBloom,
+		TransactionsRoot: "0x0000000000000000000000000000000000000000000000000000000000000001",
+		StateRoot:        "0x0000000000000000000000000000000000000000000000000000000000000002",
+		ReceiptsRoot:     testReceiptsRoot,
+		Miner:            "0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5",
+		GasLimit:         "0x1c9c380",
+		GasUsed:          "0xe4e1c0",
+		BaseFeePerGas:    "0x2540be400",
+		ExtraData:        "0x6265617665726275696c642e6f7267",
+	}
+
+	typed, err := block.Typed()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1234567), typed.Number)
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), typed.Timestamp)
+	assert.Equal(t, "10000000000", typed.BaseFeePerGas.String())
+	assert.Equal(t, "beaverbuild.org", string(typed.ExtraData))
+
+	assert.Equal(t, block, typed.Wire())
+}
+
+func TestTypedReceipt_RoundTrip(t *testing.T) {
+	contract := "0x5fbdb2315678afecb367f032d93f642f64180aa3"
+	receipt := Receipt{
+		BlockHash:         "0x4c6f9f6b3e5c3b9d8f2a6a8e3c4e1d3d7d5f7b1a9c0e2f4a6b8c0d2e4f6a8b0c",
+		BlockNumber:       "0x12d687",
+		ContractAddress:   &contract,
+		CumulativeGasUsed: "0xb44d",
+		EffectiveGasPrice: "0x3b9aca00",
+		From:              "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266",
+		GasUsed:           "0xb44d",
+		Logs: []Log{
+			{
+				Address:          "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+				Topics:           []any{"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"},
+				Data:             "0x00000000000000000000000000000000000000000000000000000000000f4240",
+				BlockNumber:      "0x12d687",
+				TransactionHash:  "0x1111111111111111111111111111111111111111111111111111111111111111",
+				TransactionIndex: "0x0",
+				BlockHash:        "0x4c6f9f6b3e5c3b9d8f2a6a8e3c4e1d3d7d5f7b1a9c0e2f4a6b8c0d2e4f6a8b0c",
+				LogIndex:         "0x0",
+			},
+		},
+		LogsBloom:        testReceiptBloom,
+		Status:           "0x1",
+		TransactionHash:  "0x1111111111111111111111111111111111111111111111111111111111111111",
+		TransactionIndex: "0x0",
+		Type:             "0x2",
+	}
+
+	typed, err := receipt.Typed()
+	assert.NoError(t, err)
+	assert.Nil(t, typed.To, "contract creation has no receiver")
+	assert.Equal(t, contract, typed.ContractAddress.Hex())
+	assert.Equal(t, uint8(2), typed.Type)
+	assert.Equal(t, "1000000000", typed.EffectiveGasPrice.String())
+	assert.Len(t, typed.Logs[0].Topics, 1)
+
+	assert.Equal(t, receipt, typed.Wire())
+}
+
+func TestTypedLog_InvalidFields(t *testing.T) {
+	_, err := Log{Address: "0xabc"}.Typed()
+	assert.Error(t, err)
+
+	_, err = Log{Topics: []any{"0xddf252ad"}}.Typed()
+	assert.Error(t, err)
+
+	_, err = Log{Topics: []any{42}}.Typed()
+	assert.Error(t, err)
+}
+
+func TestHash_JSON(t *testing.T) {
+	h, err := ParseHash("0xDDF252AD1BE2C89B69C2B068FC378DAA952BA7F163C4A11628F55A4DF523B3EF")
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(h)
+	assert.NoError(t, err)
+	assert.Equal(t, `"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"`, string(b))
+
+	var decoded Hash
+	assert.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, h, decoded)
+
+	var addr AddressBytes
+	assert.Error(t, json.Unmarshal([]byte(`"0x1234"`), &addr))
+}
@@ -1,16 +1,128 @@
 package core
 
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+)
+
 const ZeroAddress Address = "0x0000000000000000000000000000000000000000"
 
 type Block struct {
 	// Current block number
-	Number string
+	Number string `json:"number"`
 	// The hash of the block
-	Hash string
+	Hash string `json:"hash"`
 	// The previous block hash
-	ParentHash string
+	ParentHash string `json:"parentHash"`
 	// The time the block is created
-	Timestamp string
+	Timestamp string `json:"timestamp"`
+	// The bloom filter for the logs of the block
+	LogsBloom string `json:"logsBloom,omitempty"`
+	// The root of the transaction trie of the block
+	TransactionsRoot string `json:"transactionsRoot,omitempty"`
+	// The root of the final state trie of the block
+	StateRoot string `json:"stateRoot,omitempty"`
+	// The root of the receipts trie of the block
+	ReceiptsRoot string `json:"receiptsRoot,omitempty"`
+	// The address of the beneficiary to whom the mining rewards were given
+	Miner string `json:"miner,omitempty"`
+	// The maximum gas allowed in this block
+	GasLimit string `json:"gasLimit,omitempty"`
+	// The total used gas by all transactions in this block
+	GasUsed string `json:"gasUsed,omitempty"`
+	// The base fee per gas, only present after London
+	BaseFeePerGas string `json:"baseFeePerGas,omitempty"`
+	// The "extra data" field of this block
+	ExtraData string `json:"extraData,omitempty"`
+}
+
+// FullBlock is a block returned with its transactions.
+type FullBlock struct {
+	Block
+	// Transactions in block order
+	Transactions []Transaction `json:"transactions"`
+}
+
+type Transaction struct {
+	// The hash of the transaction
+	Hash string `json:"hash"`
+	// The address of the sender
+	From string `json:"from"`
+	// The address of the receiver. null when it's a contract creation transaction
+	To string `json:"to"`
+	// The calldata sent along with the transaction
+	Input string `json:"input"`
+	// The value transferred in wei
+	Value string `json:"value"`
+	// The gas provided by the sender
+	Gas string `json:"gas"`
+	// The gas price, the effective one for dynamic fee transactions
+	GasPrice string `json:"gasPrice,omitempty"`
+	// Fee caps of dynamic fee transactions
+	MaxFeePerGas string `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas,omitempty"`
+	// The number of transactions made by the sender prior to this one
+	Nonce string `json:"nonce"`
+	// The block number where this transaction was in
+	BlockNumber string `json:"blockNumber"`
+	// The hash of the block where this transaction was in
+	BlockHash string `json:"blockHash"`
+	// An index of the transaction in the block
+	TransactionIndex string `json:"transactionIndex"`
+	// The transaction type
+	Type string `json:"type,omitempty"`
+}
+
+// Trace is an internal call returned by TraceRPC, normalized from the debug or trace namespace.
+type Trace struct {
+	// The transaction the call belongs to
+	TransactionHash string
+	TransactionIndex uint64
+	// Position in the call tree, empty for the transaction itself, [0 1] for the second call of the first call
+	TraceAddress []int
+	// Upper-case call type, e.g. CALL, DELEGATECALL, STATICCALL, CREATE
+	Type string
+	From string
+	// The callee, or the created contract
+	To string
+	Input string
+	Output string
+	Value string
+	Gas string
+	GasUsed string
+	// Revert or failure reason, empty when the call succeeded
+	Error string
+}
+
+// Call is a transaction, or an internal call, indexed in transactions mode.
+type Call struct {
+	BlockNumber uint64
+	BlockHash string
+	TransactionHash string
+	TransactionIndex uint64
+	// Position in the call tree of internal calls, empty for the transaction itself
+	TraceAddress []int
+	// CALL or CREATE for transactions, the trace call type for internal calls
+	Type string
+	From string
+	// The callee, or the created contract
+	To string
+	Input []byte
+	Value *big.Int
+	Gas uint64
+	// Gas used by the transaction from its receipt, or by the internal call from its trace
+	GasUsed uint64
+	// Return data of internal calls
+	Output []byte
+	// Failure reason of internal calls
+	Error string
+	// Whether the transaction (receipt status 1), or the internal call, succeeded
+	Success bool
+	// Calldata decoded with the matching Options.Selectors signature, nil when unknown or malformed
+	Decoded *DecodedCall
+	// Revert data decoded with Options.Errors, set for failed calls whose output is known, i.e. when tracing
+	Revert *RevertError
 }
 
 type Address string
@@ -35,6 +147,8 @@ type Log struct {
 	LogIndex string `json:"logIndex,omitempty"`
 	// The integer of the log index position in the block. null when it's a pending log
 	Removed bool `json:"removed,omitempty"`
+	// Block and transaction metadata attached by the processor, nil unless Options.Enrichment is set
+	Context *DecodeContext `json:"context,omitempty"`
 }
 
 type Receipt struct {
@@ -59,6 +173,8 @@ type Receipt struct {
 	LogsBloom string `json:"logsBloom"`
 	// It is either 1 (success) or 0 (failure) encoded as a hexadecimal
 	Status string `json:"status"`
+	// The post-transaction state root, only present before Byzantium instead of status
+	Root string `json:"root,omitempty"`
 	// The address of the receiver. null when it's a contract creation transaction
 	To string `json:"to"`
 	// The hash of the transaction
@@ -84,12 +200,86 @@ type Filter struct {
 	Topics []string `json:"topics,omitempty"`   // positional; omit if unused
 	// Using the blockHash field is equivalent to setting the fromBlock and toBlock to the block number the blockHash references. If blockHash is present in the filter criteria, neither fromBlock nor toBlock is allowed
 	BlockHash string `json:"blockHash,omitempty"`
+	// TopicSets replaces Topics when set, it supports wildcards and alternatives, see Event.TopicFilter
+	TopicSets TopicFilter `json:"-"`
 }
 
-type Cursor struct {
+// MarshalJSON sends TopicSets as the topics when set.
+func (f Filter) MarshalJSON() ([]byte, error) {
+	type plain Filter
+	if len(f.TopicSets) == 0 {
+		return json.Marshal(plain(f))
+	}
+	return json.Marshal(struct {
+		plain
+		Topics TopicFilter `json:"topics"`
+	}{plain(f), f.TopicSets})
+}
 
+// CallMsg is the message of an eth_call.
+type CallMsg struct {
+	// The address the call is sent from, optional
+	From string `json:"from,omitempty"`
+	// The contract called
+	To string `json:"to"`
+	// The calldata, selector included
+	Data string `json:"data,omitempty"`
+	// Optional gas limit and value
+	Gas string `json:"gas,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// BlockRef pins an eth_call to a block. Hash, when set, takes precedence over Number (EIP-1898),
+// so a reorged block fails the call instead of reading another branch.
+type BlockRef struct {
+	Number uint64
+	Hash string
 }
 
+// WindowHash is a committed window end height and its block hash.
+type WindowHash struct {
+	BlockNumber uint64 `json:"blockNumber"`
+	Hash        string `json:"hash"`
+}
+
+// Cursor is the persisted position of a chain.
+type Cursor struct {
+	ChainId string `json:"chainId"`
+	// Last committed block
+	BlockNumber uint64 `json:"blockNumber"`
+	// Recent window hashes, oldest first, so reorgs are still detected after a restart
+	WindowHashes []WindowHash `json:"windowHashes"`
+	// Contracts discovered through Options.Factories up to BlockNumber
+	Children []ChildContract `json:"children,omitempty"`
+}
+
+// DecodeContext carries the block and transaction metadata of a log.
+// Which fields are filled depends on Options.Enrichment.
 type DecodeContext struct {
+	// Chain the log was indexed from
+	ChainId string
+	// Parsed position of the log, always filled when enrichment is enabled
+	BlockNumber uint64
+	BlockHash string
+	TransactionHash string
+	TransactionIndex uint64
+	LogIndex uint64
+
+	// Block level fields, filled from EnrichmentBlock
+	BlockTimestamp time.Time
+
+	// Transaction level fields, filled from EnrichmentReceipt
+	// The sender of the transaction
+	From string
+	// The receiver of the transaction, empty for contract creation
+	To string
+	// Whether the transaction succeeded (status 1)
+	Success bool
+	// The amount of gas used by the transaction
+	GasUsed uint64
+	// The actual value per gas deducted from the sender account
+	EffectiveGasPrice *big.Int
 
+	// state serves Call and Multicall, nil when the RPC can't eth_call
+	state *StateReader
 }
\ No newline at end of file
@@ -0,0 +1,253 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+)
+
+// EncodeReceipt returns the consensus encoding of a receipt as stored in the receipts trie.
+// Typed receipts (EIP-2718) are prefixed with their type byte.
+func EncodeReceipt(r Receipt) ([]byte, error) {
+	tr, err := r.Typed()
+	if err != nil {
+		return nil, err
+	}
+	return tr.Encode(), nil
+}
+
+// Encode returns the consensus encoding of the receipt.
+func (r TypedReceipt) Encode() []byte {
+	statusOrRoot := rlpEncodeUint(r.Status)
+	if r.Root != nil {
+		statusOrRoot = rlpEncodeBytes(r.Root[:])
+	}
+
+	logs := make([][]byte, len(r.Logs))
+	for i, l := range r.Logs {
+		topics := make([][]byte, len(l.Topics))
+		for j, t := range l.Topics {
+			topics[j] = rlpEncodeBytes(t[:])
+		}
+		logs[i] = rlpEncodeList(rlpEncodeBytes(l.Address[:]), rlpEncodeList(topics...), rlpEncodeBytes(l.Data))
+	}
+
+	payload := rlpEncodeList(
+		statusOrRoot,
+		rlpEncodeUint(r.CumulativeGasUsed),
+		rlpEncodeBytes(r.LogsBloom[:]),
+		rlpEncodeList(logs...),
+	)
+
+	if r.Type == 0 {
+		return payload
+	}
+	return append([]byte{r.Type}, payload...)
+}
+
+// ReceiptsRoot recomputes the receipts trie root of a block from its receipts.
+func ReceiptsRoot(receipts []Receipt) (string, error) {
+	values := make([][]byte, len(receipts))
+	for i, r := range receipts {
+		enc, err := EncodeReceipt(r)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode receipt %d: %w", i, err)
+		}
+		values[i] = enc
+	}
+	return "0x" + hex.EncodeToString(deriveListRoot(values)), nil
+}
+
+// VerifyReceipts checks receipts returned by the provider against the block header.
+// The recomputed receipts root must match receiptsRoot and the union of receipt blooms must match logsBloom.
+func VerifyReceipts(block Block, receipts []Receipt) error {
+	number, _ := HexQtyToUint64(block.Number)
+
+	root, err := ReceiptsRoot(receipts)
+	if err != nil {
+		return &IntegrityError{BlockNumber: number, Reason: err.Error()}
+	}
+	if !hexEqual(root, block.ReceiptsRoot) {
+		return &IntegrityError{
+			BlockNumber: number,
+			Reason:      fmt.Sprintf("receipts root mismatch: computed %s, header %s", root, block.ReceiptsRoot),
+		}
+	}
+
+	headerBloom, err := ParseBloom(block.LogsBloom)
+	if err != nil {
+		return &IntegrityError{BlockNumber: number, Reason: err.Error()}
+	}
+	var bloom Bloom
+	for _, r := range receipts {
+		b, err := LogsBloom(r.Logs)
+		if err != nil {
+			return &IntegrityError{BlockNumber: number, Reason: err.Error()}
+		}
+		bloom.Or(b)
+	}
+	if bloom != headerBloom {
+		return &IntegrityError{BlockNumber: number, Reason: "logs bloom mismatch between receipts and header"}
+	}
+
+	return nil
+}
+
+// VerifyLogsBloom checks that every log is covered by the block's logsBloom.
+// A log that fails the bloom test can't belong to the block.
+func VerifyLogsBloom(block Block, logs []Log) error {
+	parsed, err := parseLogs(logs)
+	if err != nil {
+		number, _ := HexQtyToUint64(block.Number)
+		return &IntegrityError{BlockNumber: number, Reason: err.Error()}
+	}
+	return verifyLogsBloom(block, parsed)
+}
+
+func verifyLogsBloom(block Block, logs []fetchedLog) error {
+	number, _ := HexQtyToUint64(block.Number)
+
+	bloom, err := ParseBloom(block.LogsBloom)
+	if err != nil {
+		return &IntegrityError{BlockNumber: number, Reason: err.Error()}
+	}
+	for _, l := range logs {
+		if !logInBloom(bloom, l.typed) {
+			return &IntegrityError{
+				BlockNumber: number,
+				Reason:      fmt.Sprintf("log %s/%s is not covered by the block bloom", l.TransactionHash, l.LogIndex),
+			}
+		}
+	}
+	return nil
+}
+
+// verifyLogsWindow cross-checks eth_getLogs results of a window against the block headers.
+// Blocks whose bloom may hold a filter address and topic pair that none of their logs has are re-read from receipts
+// to catch dropped logs. A dropped log sharing its address and topic with a returned one can't be told from the bloom.
+func (p *Processor) verifyLogsWindow(ctx context.Context, from uint64, to uint64, logs []fetchedLog, chain *chainState) error {
+	byBlock := make(map[uint64][]fetchedLog)
+	for _, l := range logs {
+		byBlock[l.typed.BlockNumber] = append(byBlock[l.typed.BlockNumber], l)
+	}
+
+	headers, err := p.getHeaders(ctx, from, to, chain)
+	if err != nil {
+		return err
+	}
+
+	for i, block := range headers {
+		blockNum := from + uint64(i)
+		blockLogs := byBlock[blockNum]
+		if err := verifyLogsBloom(block, blockLogs); err != nil {
+			return err
+		}
+
+		bloom, err := ParseBloom(block.LogsBloom)
+		if err != nil {
+			return &IntegrityError{BlockNumber: blockNum, Reason: err.Error()}
+		}
+		if !p.bloomMatchesFilter(bloom, chain) || !uncoveredPair(bloom, blockLogs, chain) {
+			continue
+		}
+
+		receipts, err := chain.chainInfo.RPC.GetBlockReceipts(ctx, Uint64ToHexQty(blockNum))
+		if err != nil {
+			return fmt.Errorf("failed to get receipts for block %d: %w", blockNum, err)
+		}
+		if err := VerifyReceipts(block, receipts); err != nil {
+			return err
+		}
+		matching := 0
+		for _, r := range receipts {
+			receiptLogs, err := parseLogs(r.Logs)
+			if err != nil {
+				return fmt.Errorf("invalid receipt %s: %w", r.TransactionHash, err)
+			}
+			for _, l := range receiptLogs {
+				if p.matchesTopicFilter(l.typed, chain) && p.matchesAddressFilter(l.typed, chain) {
+					matching++
+				}
+			}
+		}
+		if missing := matching - len(blockLogs); missing > 0 {
+			return &IntegrityError{
+				BlockNumber: blockNum,
+				Reason:      fmt.Sprintf("eth_getLogs dropped %d matching logs", missing),
+			}
+		}
+	}
+
+	return nil
+}
+
+// bloomMatchesFilter reports whether a block with this bloom may contain logs matching the chain filter.
+// Topics and addresses are each OR-ed, and both have to match.
+func (p *Processor) bloomMatchesFilter(bloom Bloom, chain *chainState) bool {
+	if bloom == (Bloom{}) {
+		return false
+	}
+	return bloomMatchesAny(bloom, chain.topics) && bloomMatchesAny(bloom, chain.addresses)
+}
+
+// uncoveredPair reports whether the bloom may hold a filter address and topic0 pair that none of the logs has.
+// An empty address or topic list matches any log.
+func uncoveredPair(bloom Bloom, logs []fetchedLog, chain *chainState) bool {
+	covered := func(match func(TypedLog) bool) bool {
+		for _, l := range logs {
+			if match(l.typed) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case len(chain.addresses) == 0 && len(chain.topics) == 0:
+		return len(logs) == 0
+	case len(chain.addresses) == 0:
+		for _, topic := range chain.topics {
+			if bloom.Test(topic[:]) && !covered(func(l TypedLog) bool { return hasTopic0(l, topic) }) {
+				return true
+			}
+		}
+	case len(chain.topics) == 0:
+		for _, addr := range chain.addresses {
+			if bloom.Test(addr[:]) && !covered(func(l TypedLog) bool { return l.Address == addr }) {
+				return true
+			}
+		}
+	default:
+		for _, addr := range chain.addresses {
+			if !bloom.Test(addr[:]) {
+				continue
+			}
+			for _, topic := range chain.topics {
+				if bloom.Test(topic[:]) && !covered(func(l TypedLog) bool { return l.Address == addr && hasTopic0(l, topic) }) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// bloomMatchesAny reports whether any of the values may be in the bloom, an empty list always matches.
+func bloomMatchesAny[T interface{ Bytes() []byte }](bloom Bloom, values []T) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if bloom.Test(v.Bytes()) {
+			return true
+		}
+	}
+	return false
+}
+
+func hexEqual(a, b string) bool {
+	ra, errA := hexToBytes(a)
+	rb, errB := hexToBytes(b)
+	return errA == nil && errB == nil && bytes.Equal(ra, rb)
+}
@@ -0,0 +1,288 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Vectors below were generated with go-ethereum's types.DeriveSha and types.CreateBloom.
+const (
+	testReceiptsRoot     = "0xda979b0a0943c9cc629935ccc1528ad328aeedb7e285bf7a249450f0ea03330a"
+	testReceiptBloom     = "0x00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000080000000000000000000020000000000000000000000008000008000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000010000200000000000000000000000000000000000000000000010000000000000000000000240000000000200000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000800000000000000000000000000"
+	testManyReceiptsRoot = "0x2d39b6a8325cd4e3309009b724a1ea812e13296922ed1e302eb67e073ed26cec"
+)
+
+var emptyBloom = "0x" + hex.EncodeToString(make([]byte, BloomByteLength))
+
+func testReceipts() []Receipt {
+	return []Receipt{
+		{
+			Type:              "0x0",
+			Status:            "0x1",
+			CumulativeGasUsed: "0xb44d",
+			LogsBloom:         testReceiptBloom,
+			Logs: []Log{
+				{
+					Address: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+					Topics: []any{
+						"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+						"0x0000000000000000000000001111111111111111111111111111111111111111",
+						"0x0000000000000000000000002222222222222222222222222222222222222222",
+					},
+					Data: "0x00000000000000000000000000000000000000000000000000000000000f4240",
+				},
+			},
+		},
+		{
+			Type:              "0x2",
+			Status:            "0x0",
+			CumulativeGasUsed: "0x10655",
+			LogsBloom:         emptyBloom,
+			Logs:              []Log{},
+		},
+	}
+}
+
+func TestTrieRoot_KnownVectors(t *testing.T) {
+	assert.Equal(t, EmptyRootHash, "0x"+hex.EncodeToString(trieRoot(map[string][]byte{})))
+
+	dogs := map[string][]byte{
+		"doe":          []byte("reindeer"),
+		"dog":          []byte("puppy"),
+		"dogglesworth": []byte("cat"),
+	}
+	assert.Equal(t, "8aad789dff2f538bca5d8ea56e8abe10f4c7ba3a5dea95fea4cd6e7c3a1168d3", hex.EncodeToString(trieRoot(dogs)))
+
+	puppy := map[string][]byte{
+		"do":    []byte("verb"),
+		"horse": []byte("stallion"),
+		"doge":  []byte("coin"),
+		"dog":   []byte("puppy"),
+	}
+	assert.Equal(t, "5991bb8c6514148a29db676a14ac506cd2cd5775ace63c30a4fe457715e9ac84", hex.EncodeToString(trieRoot(puppy)))
+}
+
+func TestReceiptsRoot_Success(t *testing.T) {
+	root, err := ReceiptsRoot(testReceipts())
+	assert.NoError(t, err)
+	assert.Equal(t, testReceiptsRoot, root)
+
+	// More than 127 receipts exercises multi-byte rlp keys
+	many := make([]Receipt, 130)
+	for i := range many {
+		many[i] = Receipt{
+			Type:              "0x2",
+			Status:            "0x1",
+			CumulativeGasUsed: Uint64ToHexQty(uint64(21000 * (i + 1))),
+			LogsBloom:         emptyBloom,
+		}
+	}
+	root, err = ReceiptsRoot(many)
+	assert.NoError(t, err)
+	assert.Equal(t, testManyReceiptsRoot, root)
+
+	root, err = ReceiptsRoot(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, EmptyRootHash, root)
+}
+
+func TestLogsBloom_Success(t *testing.T) {
+	receipts := testReceipts()
+	bloom, err := LogsBloom(receipts[0].Logs)
+	assert.NoError(t, err)
+	assert.Equal(t, testReceiptBloom, bloom.String())
+
+	transfer, _ := hexToBytes("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	approval, _ := hexToBytes("0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925")
+	assert.True(t, bloom.Test(transfer))
+	assert.False(t, bloom.Test(approval))
+}
+
+func TestVerifyReceipts_DetectsTampering(t *testing.T) {
+	block := Block{
+		Number:       "0x10",
+		ReceiptsRoot: testReceiptsRoot,
+		LogsBloom:    testReceiptBloom,
+	}
+	assert.NoError(t, VerifyReceipts(block, testReceipts()))
+
+	// A provider silently dropping the log
+	dropped := testReceipts()
+	dropped[0].Logs = nil
+	err := VerifyReceipts(block, dropped)
+	var integrityErr *IntegrityError
+	assert.True(t, errors.As(err, &integrityErr))
+	assert.Equal(t, uint64(16), integrityErr.BlockNumber)
+
+	// A provider omitting a whole receipt
+	err = VerifyReceipts(block, testReceipts()[:1])
+	assert.ErrorAs(t, err, &integrityErr)
+}
+
+func TestVerifyLogsBloom_DetectsForeignLog(t *testing.T) {
+	block := Block{Number: "0x10", LogsBloom: testReceiptBloom}
+	assert.NoError(t, VerifyLogsBloom(block, testReceipts()[0].Logs))
+
+	foreign := Log{
+		Address: "0x3333333333333333333333333333333333333333",
+		Topics:  []any{"0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"},
+	}
+	var integrityErr *IntegrityError
+	assert.ErrorAs(t, VerifyLogsBloom(block, []Log{foreign}), &integrityErr)
+}
+
+func TestRunWithVerifyIntegrity_DroppedReceiptLog(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		switch req.Method {
+		case "eth_blockNumber":
+			_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": "0x1"})
+
+		case "eth_getBlockByNumber":
+			blockNum, _ := HexQtyToUint64(fmt.Sprintf("%s", req.Params[0]))
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"jsonrpc": "2.0",
+				"id":      1,
+				"result": map[string]any{
+					"number":       req.Params[0],
+					"hash":         req.Params[0],
+					"parentHash":   Uint64ToHexQty(blockNum - 1),
+					"timestamp":    "0x0",
+					"receiptsRoot": testReceiptsRoot,
+					"logsBloom":    testReceiptBloom,
+				},
+			})
+
+		case "eth_getBlockReceipts":
+			// Return receipts with the transfer log missing
+			receipts := testReceipts()
+			receipts[0].Logs = []Log{}
+			_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": receipts})
+		}
+	}))
+	defer srv.Close()
+
+	processor := NewProcessor()
+	processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+		RangeSize:          1,
+		FetcherConcurrency: 1,
+		LogsBufferSize:     10,
+		FetchMode:          FetchModeReceipts,
+		VerifyIntegrity:    true,
+		RetryConfig: &RetryConfig{
+			MaxAttempts:    2,
+			InitialBackoff: 10 * time.Millisecond,
+			MaxBackoff:     20 * time.Millisecond,
+			Multiplier:     1.5,
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := processor.Run(ctx)
+	var integrityErr *IntegrityError
+	assert.ErrorAs(t, err, &integrityErr)
+	assert.Equal(t, uint64(1), integrityErr.BlockNumber)
+}
+
+func TestRunWithVerifyIntegrity_DroppedOneOfTwoLogs(t *testing.T) {
+	transfer := factoryTestLog(testPoolA, testTransferTopic, 1, 0, "0x")
+	approval := factoryTestLog(testPoolA, testApprovalTopic, 1, 1, "0x")
+	receipts := []Receipt{{Type: "0x2", Status: "0x1", CumulativeGasUsed: "0x5208", Logs: []Log{transfer, approval}}}
+	bloom, err := LogsBloom(receipts[0].Logs)
+	assert.NoError(t, err)
+	receipts[0].LogsBloom = bloom.String()
+	root, err := ReceiptsRoot(receipts)
+	assert.NoError(t, err)
+
+	run := func(returned []Log) error {
+		type rpcReq struct {
+			ID     uint   `json:"id"`
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+		result := func(req rpcReq) any {
+			switch req.Method {
+			case "eth_blockNumber":
+				return "0x1"
+			case "eth_getBlockByNumber":
+				blockNum, _ := HexQtyToUint64(fmt.Sprintf("%s", req.Params[0]))
+				return map[string]any{
+					"number":       req.Params[0],
+					"hash":         fmt.Sprintf("0x%064x", blockNum),
+					"parentHash":   fmt.Sprintf("0x%064x", blockNum-1),
+					"timestamp":    "0x0",
+					"receiptsRoot": root,
+					"logsBloom":    bloom.String(),
+				}
+			case "eth_getLogs":
+				return returned
+			case "eth_getBlockReceipts":
+				return receipts
+			}
+			return nil
+		}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			body, _ := io.ReadAll(r.Body)
+			// Headers are fetched in batches
+			var batch []rpcReq
+			if json.Unmarshal(body, &batch) == nil {
+				resps := make([]map[string]any, len(batch))
+				for i, req := range batch {
+					resps[i] = map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result(req)}
+				}
+				_ = json.NewEncoder(w).Encode(resps)
+				return
+			}
+			var req rpcReq
+			json.Unmarshal(body, &req)
+			_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result(req)})
+		}))
+		defer srv.Close()
+
+		processor := NewProcessor()
+		processor.AddChain(ChainInfo{ChainId: "1", RPC: NewHTTPRPC(srv.URL, 0)}, &Options{
+			RangeSize:       1,
+			LogsBufferSize:  10,
+			EndBlock:        1,
+			FetchMode:       FetchModeLogs,
+			Addresses:       []string{testPoolA},
+			Topics:          []string{testTransferTopic, testApprovalTopic},
+			VerifyIntegrity: true,
+			RetryConfig:     noRetry(),
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return processor.Run(ctx)
+	}
+
+	assert.NoError(t, run([]Log{transfer, approval}))
+
+	// The block has logs, but the bloom holds the Approval none of them is
+	err = run([]Log{transfer})
+	var integrityErr *IntegrityError
+	if assert.ErrorAs(t, err, &integrityErr) {
+		assert.Equal(t, uint64(1), integrityErr.BlockNumber)
+		assert.Contains(t, integrityErr.Reason, "dropped 1 matching logs")
+	}
+}
@@ -0,0 +1,200 @@
+// Package prometheus is a core.Metrics exposing the processor and RPC series in the Prometheus text format.
+package prometheus
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+)
+
+// DefaultNamespace prefixes the series when Config.Namespace is empty.
+const DefaultNamespace = "indexer"
+
+// Config describes where the series are registered.
+type Config struct {
+	// Namespace prefixes every series
+	// Default: "indexer"
+	Namespace string
+	// Registry the series are registered on and Handler serves, a new registry by default.
+	// Pass a registry shared with other collectors to serve them together.
+	Registry *prometheus.Registry
+	// LatencyBuckets are the buckets of the RPC latency histogram, in seconds
+	// Default: prometheus.DefBuckets
+	LatencyBuckets []float64
+}
+
+// Metrics implements core.Metrics.
+// Chains are labelled chain_id, requests method and endpoint, where the endpoint is reduced to its scheme and host
+// so API keys in the URL path or query never end up in a label.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	cursor     *prometheus.GaugeVec
+	head       *prometheus.GaugeVec
+	lag        *prometheus.GaugeVec
+	windows    *prometheus.CounterVec
+	logs       *prometheus.CounterVec
+	reorgs     *prometheus.CounterVec
+	reorgDepth *prometheus.HistogramVec
+	fallbacks  *prometheus.CounterVec
+	requests   *prometheus.CounterVec
+	latency    *prometheus.HistogramVec
+	retries    *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+
+	// heights keeps the cursor and head of each chain to compute the lag
+	mu      sync.Mutex
+	heights map[string]*heights
+}
+
+type heights struct {
+	cursor uint64
+	head   uint64
+}
+
+var _ core.Metrics = (*Metrics)(nil)
+
+// New registers the series on the registry.
+func New(cfg Config) (*Metrics, error) {
+	ns := cfg.Namespace
+	if ns == "" {
+		ns = DefaultNamespace
+	}
+	registry := cfg.Registry
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+	}
+	buckets := cfg.LatencyBuckets
+	if buckets == nil {
+		buckets = prometheus.DefBuckets
+	}
+
+	chain := []string{"chain_id"}
+	request := []string{"method", "endpoint"}
+	m := &Metrics{
+		registry: registry,
+		cursor: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: ns, Name: "chain_cursor_block", Help: "Last committed block of the chain.",
+		}, chain),
+		head: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: ns, Name: "chain_head_block", Help: "Head height last seen for the chain.",
+		}, chain),
+		lag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: ns, Name: "chain_lag_blocks", Help: "Blocks between the head and the cursor of the chain.",
+		}, chain),
+		windows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: "windows_committed_total", Help: "Windows committed to the sinks and the cursor.",
+		}, chain),
+		logs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: "logs_emitted_total", Help: "Logs emitted on the logs channel and subscriptions.",
+		}, chain),
+		reorgs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: "reorgs_total", Help: "Reorgs detected.",
+		}, chain),
+		reorgDepth: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: ns, Name: "reorg_depth_blocks", Help: "Blocks rolled back by a reorg.",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 11),
+		}, chain),
+		fallbacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: "reorg_fallbacks_total", Help: "Reorgs rolled back by the hard fallback, their ancestor wasn't found.",
+		}, chain),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: "rpc_requests_total", Help: "RPC requests sent, batches count once.",
+		}, request),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: ns, Name: "rpc_request_duration_seconds", Help: "RPC request latency.",
+			Buckets: buckets,
+		}, request),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: "rpc_retries_total", Help: "Failed RPC requests being retried.",
+		}, request),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: "rpc_errors_total", Help: "Failed RPC requests by class, http_<status>, rpc_<code>, context or transport.",
+		}, append(request, "class")),
+		heights: make(map[string]*heights),
+	}
+
+	for _, c := range []prometheus.Collector{
+		m.cursor, m.head, m.lag, m.windows, m.logs, m.reorgs, m.reorgDepth, m.fallbacks,
+		m.requests, m.latency, m.retries, m.errors,
+	} {
+		if err := registry.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) SetCursor(chainId string, block uint64) {
+	m.cursor.WithLabelValues(chainId).Set(float64(block))
+	m.setLag(chainId, func(h *heights) { h.cursor = block })
+}
+
+func (m *Metrics) SetHead(chainId string, block uint64) {
+	m.head.WithLabelValues(chainId).Set(float64(block))
+	m.setLag(chainId, func(h *heights) { h.head = block })
+}
+
+// setLag updates the heights of a chain and its lag, which stays 0 until the head is known.
+func (m *Metrics) setLag(chainId string, update func(*heights)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.heights[chainId]
+	if !ok {
+		h = &heights{}
+		m.heights[chainId] = h
+	}
+	update(h)
+
+	var lag uint64
+	if h.head > h.cursor {
+		lag = h.head - h.cursor
+	}
+	m.lag.WithLabelValues(chainId).Set(float64(lag))
+}
+
+func (m *Metrics) WindowCommitted(chainId string, logs int) {
+	m.windows.WithLabelValues(chainId).Inc()
+	m.logs.WithLabelValues(chainId).Add(float64(logs))
+}
+
+func (m *Metrics) Reorg(chainId string, depth uint64) {
+	m.reorgs.WithLabelValues(chainId).Inc()
+	m.reorgDepth.WithLabelValues(chainId).Observe(float64(depth))
+}
+
+func (m *Metrics) ReorgFallback(chainId string) {
+	m.fallbacks.WithLabelValues(chainId).Inc()
+}
+
+func (m *Metrics) RPCRequest(method string, endpoint string, duration time.Duration, err error) {
+	endpoint = endpointLabel(endpoint)
+	m.requests.WithLabelValues(method, endpoint).Inc()
+	m.latency.WithLabelValues(method, endpoint).Observe(duration.Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(method, endpoint, core.ErrorClass(err)).Inc()
+	}
+}
+
+func (m *Metrics) RPCRetry(method string, endpoint string) {
+	m.retries.WithLabelValues(method, endpointLabel(endpoint)).Inc()
+}
+
+// endpointLabel keeps the scheme and host of an endpoint.
+func endpointLabel(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return "unknown"
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,85 @@
+package prometheus
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	srv := httptest.NewServer(m.Handler())
+	defer srv.Close()
+	res, err := srv.Client().Get(srv.URL)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Contains(t, res.Header.Get("Content-Type"), "text/plain")
+	body, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+func TestMetrics_Chain(t *testing.T) {
+	m, err := New(Config{})
+	assert.NoError(t, err)
+
+	m.SetCursor("1", 90)
+	m.SetHead("1", 120)
+	m.WindowCommitted("1", 7)
+	m.WindowCommitted("1", 3)
+	m.Reorg("1", 3)
+	m.ReorgFallback("1")
+	// A cursor past the head, e.g. with a lagging provider, reports no lag
+	m.SetHead("10", 50)
+	m.SetCursor("10", 60)
+
+	body := scrape(t, m)
+	for _, line := range []string{
+		`indexer_chain_cursor_block{chain_id="1"} 90`,
+		`indexer_chain_head_block{chain_id="1"} 120`,
+		`indexer_chain_lag_blocks{chain_id="1"} 30`,
+		`indexer_chain_lag_blocks{chain_id="10"} 0`,
+		`indexer_windows_committed_total{chain_id="1"} 2`,
+		`indexer_logs_emitted_total{chain_id="1"} 10`,
+		`indexer_reorgs_total{chain_id="1"} 1`,
+		`indexer_reorg_depth_blocks_bucket{chain_id="1",le="2"} 0`,
+		`indexer_reorg_depth_blocks_bucket{chain_id="1",le="4"} 1`,
+		`indexer_reorg_depth_blocks_sum{chain_id="1"} 3`,
+		`indexer_reorg_fallbacks_total{chain_id="1"} 1`,
+	} {
+		assert.Contains(t, body, line)
+	}
+}
+
+func TestMetrics_RPC(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := New(Config{Namespace: "idx", Registry: registry, LatencyBuckets: []float64{0.1, 1}})
+	assert.NoError(t, err)
+
+	endpoint := "https://eth-mainnet.example.com/v2/secret-key?token=abc"
+	m.RPCRequest("eth_getLogs", endpoint, 50*time.Millisecond, nil)
+	m.RPCRequest("eth_getLogs", endpoint, 500*time.Millisecond, &core.HTTPError{StatusCode: 429})
+	m.RPCRetry("eth_getLogs", endpoint)
+	m.RPCRequest("eth_getLogs", endpoint, 50*time.Millisecond, &core.RPCError{Code: -32005})
+
+	body := scrape(t, m)
+	assert.NotContains(t, body, "secret-key")
+	for _, line := range []string{
+		`idx_rpc_requests_total{endpoint="https://eth-mainnet.example.com",method="eth_getLogs"} 3`,
+		`idx_rpc_request_duration_seconds_bucket{endpoint="https://eth-mainnet.example.com",method="eth_getLogs",le="0.1"} 2`,
+		`idx_rpc_request_duration_seconds_bucket{endpoint="https://eth-mainnet.example.com",method="eth_getLogs",le="1"} 3`,
+		`idx_rpc_retries_total{endpoint="https://eth-mainnet.example.com",method="eth_getLogs"} 1`,
+		`idx_rpc_errors_total{class="http_429",endpoint="https://eth-mainnet.example.com",method="eth_getLogs"} 1`,
+		`idx_rpc_errors_total{class="rpc_-32005",endpoint="https://eth-mainnet.example.com",method="eth_getLogs"} 1`,
+	} {
+		assert.Contains(t, body, line)
+	}
+
+	// The series are already registered on the shared registry
+	_, err = New(Config{Namespace: "idx", Registry: registry})
+	assert.Error(t, err)
+}
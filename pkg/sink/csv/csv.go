@@ -0,0 +1,410 @@
+// Package csv is a core.Sink writing decoded events as CSV files, one file per event and chain.
+package csv
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+)
+
+// Config describes the exported events and where files are written.
+type Config struct {
+	// Dir holds one sub-directory per chain, with a <Name>.csv file per event
+	Dir string
+	// Events are the event declarations to export, logs of other events are skipped
+	Events []string
+}
+
+// Metadata columns, before the parameters of the event.
+var metadataColumns = []string{"block_number", "block_hash", "block_timestamp", "tx_hash", "tx_index", "log_index", "address"}
+
+// Sink appends the rows of every batch to the files of its events, ordered by block and log index,
+// and fsyncs them before returning. Files start with a header row taken from the ABI parameter names:
+// tuples are flattened into dotted columns ("order.maker"), arrays are JSON cells.
+type Sink struct {
+	dir    string
+	tables map[core.EventKey]*table
+
+	mu sync.Mutex
+	// last block written to each file, by path, read from disk on first use
+	last map[string]uint64
+}
+
+// table is the file layout of one event.
+type table struct {
+	event  core.Event
+	header []string
+}
+
+// New creates the sink, directories and files are created on first write.
+func New(cfg Config) (*Sink, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("missing directory")
+	}
+	s := &Sink{
+		dir:    cfg.Dir,
+		tables: make(map[core.EventKey]*table, len(cfg.Events)),
+		last:   make(map[string]uint64),
+	}
+	names := make(map[string]string, len(cfg.Events))
+	for _, declaration := range cfg.Events {
+		event, err := core.ParseEvent(declaration)
+		if err != nil {
+			return nil, err
+		}
+		if event.Anonymous {
+			return nil, fmt.Errorf("anonymous event %s can't be matched by topic", event.Signature())
+		}
+		if other, ok := names[event.Name]; ok {
+			return nil, fmt.Errorf("events %s and %s share the name %s, use distinct sinks", other, declaration, event.Name)
+		}
+		names[event.Name] = declaration
+
+		t, err := newTable(event)
+		if err != nil {
+			return nil, err
+		}
+		s.tables[event.Key()] = t
+	}
+	return s, nil
+}
+
+// newTable derives the header of an event: metadata columns, then the flattened parameters,
+// named as declared, unnamed ones arg0, arg1...
+func newTable(event core.Event) (*table, error) {
+	t := &table{event: event, header: append([]string(nil), metadataColumns...)}
+	seen := make(map[string]bool, len(metadataColumns))
+	for _, c := range metadataColumns {
+		seen[c] = true
+	}
+	for i, in := range event.Inputs {
+		name := in.Name
+		if name == "" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		// Parameters named like metadata, e.g. "address"
+		if seen[name] {
+			name = "evt_" + name
+		}
+		for _, c := range flatten(name, in) {
+			if seen[c] {
+				return nil, fmt.Errorf("event %s has duplicate column %s", event.Signature(), c)
+			}
+			seen[c] = true
+			t.header = append(t.header, c)
+		}
+	}
+	return t, nil
+}
+
+// flatten names the columns of a parameter, one per tuple field.
+func flatten(name string, arg core.Argument) []string {
+	if arg.Type.Kind != core.TupleKind || arg.Indexed {
+		return []string{name}
+	}
+	var columns []string
+	for i, c := range arg.Type.Components {
+		field := c.Name
+		if field == "" {
+			field = strconv.Itoa(i)
+		}
+		columns = append(columns, flatten(name+"."+field, c)...)
+	}
+	return columns
+}
+
+// cells formats a decoded value as the cells of its columns.
+func cells(arg core.Argument, v any) ([]string, error) {
+	if arg.Type.Kind == core.TupleKind && !arg.Indexed {
+		fields, ok := v.([]any)
+		if !ok || len(fields) != len(arg.Type.Components) {
+			return nil, fmt.Errorf("unexpected %T for %s", v, arg.Type)
+		}
+		var out []string
+		for i, c := range arg.Type.Components {
+			cs, err := cells(c, fields[i])
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, cs...)
+		}
+		return out, nil
+	}
+	switch x := core.JSONValue(v).(type) {
+	case string:
+		return []string{x}, nil
+	case bool:
+		return []string{strconv.FormatBool(x)}, nil
+	case []any:
+		b, err := json.Marshal(x)
+		if err != nil {
+			return nil, err
+		}
+		return []string{string(b)}, nil
+	}
+	return nil, fmt.Errorf("unexpected %T for %s", v, arg.Type)
+}
+
+// record is a row of a file with its position.
+type record struct {
+	table    *table
+	block    uint64
+	logIndex uint64
+	cells    []string
+}
+
+// Write appends the rows of the batch, sorted by block and log index.
+// Blocks already in a file, e.g. replayed after a restart, are rolled back first.
+func (s *Sink) Write(ctx context.Context, batch core.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.rollback(batch.ChainId, batch.FromBlock); err != nil {
+		return err
+	}
+
+	var records []record
+	for _, l := range batch.Logs {
+		r, ok, err := s.record(l)
+		if err != nil {
+			return err
+		}
+		if ok {
+			records = append(records, r)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].block != records[j].block {
+			return records[i].block < records[j].block
+		}
+		return records[i].logIndex < records[j].logIndex
+	})
+
+	rows := make(map[*table][][]string)
+	for _, r := range records {
+		rows[r.table] = append(rows[r.table], r.cells)
+	}
+	for t, rs := range rows {
+		if err := s.append(batch.ChainId, t, rs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record converts a log of an exported event, ok is false for other logs.
+func (s *Sink) record(l core.Log) (r record, ok bool, err error) {
+	key, ok := core.LogEventKey(l)
+	if !ok {
+		return r, false, nil
+	}
+	t, ok := s.tables[key]
+	if !ok {
+		return r, false, nil
+	}
+
+	ev, err := t.event.DecodeLog(l)
+	if err != nil {
+		return r, false, fmt.Errorf("failed to decode log %s of transaction %s: %w", l.LogIndex, l.TransactionHash, err)
+	}
+	r = record{table: t}
+	if r.block, err = core.HexQtyToUint64(l.BlockNumber); err != nil {
+		return r, false, fmt.Errorf("invalid block number %q: %w", l.BlockNumber, err)
+	}
+	if r.logIndex, err = core.HexQtyToUint64(l.LogIndex); err != nil {
+		return r, false, fmt.Errorf("invalid log index %q: %w", l.LogIndex, err)
+	}
+	txIndex, err := core.HexQtyToUint64(l.TransactionIndex)
+	if err != nil {
+		return r, false, fmt.Errorf("invalid transaction index %q: %w", l.TransactionIndex, err)
+	}
+	// Filled when Options.Enrichment includes the block
+	var timestamp string
+	if l.Context != nil && !l.Context.BlockTimestamp.IsZero() {
+		timestamp = l.Context.BlockTimestamp.UTC().Format(time.RFC3339)
+	}
+
+	r.cells = []string{
+		strconv.FormatUint(r.block, 10),
+		l.BlockHash,
+		timestamp,
+		l.TransactionHash,
+		strconv.FormatUint(txIndex, 10),
+		strconv.FormatUint(r.logIndex, 10),
+		l.Address,
+	}
+	for i, in := range t.event.Inputs {
+		cs, err := cells(in, ev.Values[i])
+		if err != nil {
+			return r, false, fmt.Errorf("invalid %s of %s: %w", in.Name, t.event.Signature(), err)
+		}
+		r.cells = append(r.cells, cs...)
+	}
+	return r, true, nil
+}
+
+// append writes rows to the file of an event, with the header when the file is new.
+func (s *Sink) append(chainId string, t *table, rows [][]string) error {
+	dir := s.chainDir(chainId)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	path := s.path(chainId, t)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w := csv.NewWriter(f)
+	if info.Size() == 0 {
+		w.Write(t.header)
+	}
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	last, _ := strconv.ParseUint(rows[len(rows)-1][0], 10, 64)
+	s.last[path] = last
+	return nil
+}
+
+// Rollback removes the rows above ancestor from the files of the chain.
+func (s *Sink) Rollback(ctx context.Context, chainId string, ancestor uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rollback(chainId, ancestor+1)
+}
+
+// rollback removes the rows of blocks from on.
+func (s *Sink) rollback(chainId string, from uint64) error {
+	for _, t := range s.tables {
+		path := s.path(chainId, t)
+		last, ok := s.last[path]
+		if !ok {
+			rows, err := ReadFile(path)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if len(rows) > 1 {
+				last, _ = strconv.ParseUint(rows[len(rows)-1][0], 10, 64)
+			}
+			s.last[path] = last
+		}
+		if last < from {
+			continue
+		}
+		if err := s.truncate(path, from); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncate rewrites a file without the rows of blocks from on.
+func (s *Sink) truncate(path string, from uint64) error {
+	rows, err := ReadFile(path)
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+	kept := rows[:1]
+	var last uint64
+	for _, row := range rows[1:] {
+		n, err := strconv.ParseUint(row[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid block number %q in %s: %w", row[0], path, err)
+		}
+		if n < from {
+			kept = append(kept, row)
+			last = n
+		}
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	w := csv.NewWriter(f)
+	w.WriteAll(kept)
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	s.last[path] = last
+	return syncDir(filepath.Dir(path))
+}
+
+// Flush is a no-op, Write syncs every file before returning.
+func (s *Sink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *Sink) chainDir(chainId string) string {
+	return filepath.Join(s.dir, chainId)
+}
+
+func (s *Sink) path(chainId string, t *table) string {
+	return filepath.Join(s.chainDir(chainId), t.event.Name+".csv")
+}
+
+// ReadFile reads back every row of a file written by the sink, the header first.
+func ReadFile(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err == io.EOF {
+		return nil, nil
+	}
+	return rows, err
+}
+
+// syncDir persists renames in dir.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
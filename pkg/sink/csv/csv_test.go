@@ -0,0 +1,149 @@
+package csv
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ryuux05/indexer-sdk-go/internal/sinktest"
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+const orderDecl = "OrderFilled(bytes32 indexed id, (address maker, (uint256 amount, bool partial) fill) order, uint256[] ids, string memo)"
+
+// column reads a column of a file, without the header.
+func column(t *testing.T, path string, i int) []string {
+	rows, err := ReadFile(path)
+	assert.NoError(t, err)
+	var out []string
+	for _, row := range rows[1:] {
+		out = append(out, row[i])
+	}
+	return out
+}
+
+func TestSink_FlattenAndOrder(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sink, err := New(Config{Dir: dir, Events: []string{sinktest.TransferDecl, orderDecl}})
+	assert.NoError(t, err)
+
+	event, _ := core.ParseEvent(orderDecl)
+	idBytes := make([]byte, 32)
+	idBytes[31] = 7
+	id, err := core.EncodeTopic(event.Inputs[0].Type, idBytes)
+	assert.NoError(t, err)
+	data, err := core.EncodeArguments(event.Inputs[1:], []any{
+		[]any{sinktest.Token, []any{big.NewInt(1500), true}},
+		[]any{big.NewInt(1), big.NewInt(2)},
+		"a, \"quoted\" memo",
+	})
+	assert.NoError(t, err)
+	order := sinktest.TransferLog(3, 1)
+	order.Topics = []any{event.Topic().Hex(), id.Hex()}
+	order.Data = "0x" + hex.EncodeToString(data)
+	order.Context = &core.DecodeContext{BlockTimestamp: time.Unix(1700000000, 0)}
+
+	// Logs out of order are written by block and log index
+	logs := []core.Log{sinktest.TransferLog(5, 2), order, sinktest.TransferLog(5, 0), sinktest.TransferLog(2, 4)}
+	assert.NoError(t, sink.Write(ctx, core.Batch{ChainId: "1", FromBlock: 1, ToBlock: 10, Logs: logs}))
+
+	rows, err := ReadFile(filepath.Join(dir, "1", "OrderFilled.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"block_number", "block_hash", "block_timestamp", "tx_hash", "tx_index", "log_index", "address",
+		"id", "order.maker", "order.fill.amount", "order.fill.partial", "ids", "memo",
+	}, rows[0])
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, []string{
+			"3", fmt.Sprintf("0x%064x", 3), "2023-11-14T22:13:20Z", order.TransactionHash, "1", "1", sinktest.Token,
+			fmt.Sprintf("0x%064x", 7), sinktest.Token, "1500", "true", `["1","2"]`, "a, \"quoted\" memo",
+		}, rows[1])
+	}
+
+	path := filepath.Join(dir, "1", "Transfer.csv")
+	rows, _ = ReadFile(path)
+	assert.Equal(t, []string{"from", "to", "value"}, rows[0][7:])
+	assert.Equal(t, []string{"2", "5", "5"}, column(t, path, 0))
+	assert.Equal(t, []string{"4", "0", "2"}, column(t, path, 5))
+	assert.Equal(t, []string{"204", "500", "502"}, column(t, path, 9))
+	// Enrichment off
+	assert.Equal(t, []string{"", "", ""}, column(t, path, 2))
+}
+
+func TestSink_RollbackAndReplay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "1", "Transfer.csv")
+	sink, err := New(Config{Dir: dir, Events: []string{sinktest.TransferDecl}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(1, 10, 2, 8)))
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(11, 20, 11, 15)))
+	assert.NoError(t, sink.Rollback(ctx, "1", 12))
+	assert.Equal(t, []string{"2", "8", "11"}, column(t, path, 0))
+
+	// A new sink replaying written blocks replaces them, the header is kept once
+	sink, _ = New(Config{Dir: dir, Events: []string{sinktest.TransferDecl}})
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(6, 12, 8, 12)))
+	assert.Equal(t, []string{"2", "8", "12"}, column(t, path, 0))
+
+	// Other chains are untouched
+	assert.NoError(t, sink.Rollback(ctx, "2", 0))
+	assert.Equal(t, []string{"2", "8", "12"}, column(t, path, 0))
+}
+
+func TestSink_ReplayFromGenesis(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "1", "Transfer.csv")
+	sink, err := New(Config{Dir: dir, Events: []string{sinktest.TransferDecl}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(0, 5, 0, 3)))
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(0, 5, 0, 3)))
+	assert.Equal(t, []string{"0", "3"}, column(t, path, 0))
+}
+
+func TestNew_Errors(t *testing.T) {
+	_, err := New(Config{})
+	assert.Error(t, err)
+	_, err = New(Config{Dir: t.TempDir(), Events: []string{sinktest.TransferDecl, "Transfer(address indexed from, address indexed to, uint256 indexed tokenId)"}})
+	assert.Error(t, err)
+}
+
+func TestSink_Backfill(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := New(Config{Dir: dir, Events: []string{sinktest.TransferDecl}})
+	assert.NoError(t, err)
+
+	processor := core.NewProcessor()
+	err = processor.AddChain(core.ChainInfo{ChainId: "1", RPC: sinktest.NewRPC(100)}, &core.Options{
+		RangeSize:          5,
+		FetcherConcurrency: 3,
+		LogsBufferSize:     10,
+		EndBlock:           42,
+		Sinks:              []core.Sink{sink},
+	})
+	assert.NoError(t, err)
+	logsCh, _ := processor.Logs("1")
+	go func() {
+		for range logsCh {
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, processor.Run(ctx))
+
+	var want []string
+	for n := 1; n <= 42; n++ {
+		want = append(want, fmt.Sprint(n))
+	}
+	assert.Equal(t, want, column(t, filepath.Join(dir, "1", "Transfer.csv"), 0))
+}
@@ -0,0 +1,563 @@
+// Package jsonl is a core.Sink writing logs as newline-delimited JSON files, one directory per chain.
+package jsonl
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+)
+
+// Compression of the files.
+type Compression string
+
+const (
+	CompressionNone Compression = ""
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// Config describes where and how files are written.
+type Config struct {
+	// Dir holds one sub-directory per chain
+	Dir string
+	// RotateBlocks starts a new file every RotateBlocks blocks, files cover aligned ranges, e.g. 0-99999. 0 disables it.
+	RotateBlocks uint64
+	// RotateBytes starts a new file once the current one reaches RotateBytes on disk. 0 disables it.
+	RotateBytes int64
+	Compression Compression
+	// Events are event declarations, matching logs get an "event" object with their decoded args
+	Events []string
+}
+
+// Line is one log in a file.
+type Line struct {
+	ChainId          string `json:"chainId"`
+	BlockNumber      uint64 `json:"blockNumber"`
+	BlockHash        string `json:"blockHash"`
+	TransactionHash  string `json:"transactionHash"`
+	TransactionIndex uint64 `json:"transactionIndex"`
+	LogIndex         uint64 `json:"logIndex"`
+	Address          string `json:"address"`
+	Topics           []any  `json:"topics"`
+	Data             string `json:"data"`
+	Event            *Event `json:"event,omitempty"`
+}
+
+// Event is the decoded form of a log, args use core.JSONValue.
+type Event struct {
+	Name      string         `json:"name"`
+	Signature string         `json:"signature"`
+	Args      map[string]any `json:"args"`
+}
+
+// Sink appends every batch to the current file of its chain and fsyncs it before returning,
+// so the cursor never runs ahead of the data on disk.
+//
+// Files are named after the blocks they cover, "<from>-<to>.jsonl" zero padded, plus ".gz" or ".zst".
+// Each batch is one gzip member or zstd frame, standard readers decode the concatenation.
+// An append is recorded in an "append.pending" file of the chain until it is durable and renamed,
+// the next write after a crash truncates the file back to its size before the append.
+type Sink struct {
+	cfg     Config
+	decoder *core.Decoder
+
+	mu sync.Mutex
+	// current file of each chain, nil until the first write
+	current map[string]*segment
+}
+
+// segment is a file covering blocks [from..to].
+type segment struct {
+	from uint64
+	to   uint64
+	size int64
+}
+
+// pendingAppend is the append in progress on the file of blocks [From..To], renamed to [From..NewTo] once durable.
+type pendingAppend struct {
+	From  uint64 `json:"from"`
+	To    uint64 `json:"to"`
+	NewTo uint64 `json:"newTo"`
+	// Size of the file before the append
+	Size int64 `json:"size"`
+}
+
+// New creates the sink, directories are created on first write.
+func New(cfg Config) (*Sink, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("missing directory")
+	}
+	switch cfg.Compression {
+	case CompressionNone, CompressionGzip, CompressionZstd:
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", cfg.Compression)
+	}
+	decoder := core.NewDecoder()
+	for _, declaration := range cfg.Events {
+		if _, err := decoder.Register(declaration); err != nil {
+			return nil, err
+		}
+	}
+	return &Sink{cfg: cfg, decoder: decoder, current: make(map[string]*segment)}, nil
+}
+
+// Write appends the logs of the batch, split at RotateBlocks boundaries.
+// A batch overlapping blocks already on disk, e.g. replayed after a crash, first rolls them back.
+func (s *Sink) Write(ctx context.Context, batch core.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.recover(batch.ChainId); err != nil {
+		return err
+	}
+	seg, err := s.segment(batch.ChainId)
+	if err != nil {
+		return err
+	}
+	if seg != nil && batch.FromBlock <= seg.to {
+		if err := s.rollback(batch.ChainId, batch.FromBlock); err != nil {
+			return err
+		}
+	}
+
+	lines := make([]Line, 0, len(batch.Logs))
+	for _, l := range batch.Logs {
+		line, err := s.line(batch.ChainId, l)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, line)
+	}
+
+	for from := batch.FromBlock; from <= batch.ToBlock; {
+		to := batch.ToBlock
+		if s.cfg.RotateBlocks > 0 {
+			if end := from - from%s.cfg.RotateBlocks + s.cfg.RotateBlocks - 1; end < to {
+				to = end
+			}
+		}
+		var part []Line
+		for len(lines) > 0 && lines[0].BlockNumber <= to {
+			part = append(part, lines[0])
+			lines = lines[1:]
+		}
+		if err := s.append(batch.ChainId, from, to, part); err != nil {
+			return err
+		}
+		from = to + 1
+	}
+	return nil
+}
+
+func (s *Sink) line(chainId string, l core.Log) (Line, error) {
+	var pos [3]uint64
+	for i, v := range []string{l.BlockNumber, l.TransactionIndex, l.LogIndex} {
+		n, err := core.HexQtyToUint64(v)
+		if err != nil {
+			return Line{}, fmt.Errorf("invalid log position %q: %w", v, err)
+		}
+		pos[i] = n
+	}
+	line := Line{
+		ChainId:          chainId,
+		BlockNumber:      pos[0],
+		BlockHash:        l.BlockHash,
+		TransactionHash:  l.TransactionHash,
+		TransactionIndex: pos[1],
+		LogIndex:         pos[2],
+		Address:          l.Address,
+		Topics:           l.Topics,
+		Data:             l.Data,
+	}
+	if ev, err := s.decoder.Decode(l); err == nil {
+		args := make(map[string]any, len(ev.Args))
+		for name, v := range ev.Args {
+			args[name] = core.JSONValue(v)
+		}
+		line.Event = &Event{Name: ev.Name, Signature: ev.Signature, Args: args}
+	}
+	return line, nil
+}
+
+// append writes lines of blocks [from..to] to the current file, or a new one when rotating.
+func (s *Sink) append(chainId string, from, to uint64, lines []Line) error {
+	seg := s.current[chainId]
+	if seg == nil || s.rotate(seg, from) {
+		seg = &segment{from: from, to: from}
+	}
+
+	var buf bytes.Buffer
+	if err := s.encode(&buf, lines); err != nil {
+		return err
+	}
+
+	dir := s.chainDir(chainId)
+	pending := pendingAppend{From: seg.from, To: seg.to, NewTo: to, Size: seg.size}
+	if err := s.markPending(chainId, pending); err != nil {
+		return err
+	}
+	oldPath := s.path(chainId, seg.from, seg.to)
+	f, err := os.OpenFile(oldPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// The name follows the covered range
+	if err := os.Rename(oldPath, s.path(chainId, seg.from, to)); err != nil {
+		return err
+	}
+	if err := syncDir(dir); err != nil {
+		return err
+	}
+	if err := os.Remove(s.pendingPath(chainId)); err != nil {
+		return err
+	}
+	if err := syncDir(dir); err != nil {
+		return err
+	}
+	seg.to = to
+	seg.size += int64(buf.Len())
+	s.current[chainId] = seg
+	return nil
+}
+
+// markPending durably records an append before it starts.
+func (s *Sink) markPending(chainId string, pending pendingAppend) error {
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	if err := writeFileSync(s.pendingPath(chainId), data); err != nil {
+		return err
+	}
+	return syncDir(s.chainDir(chainId))
+}
+
+// recover undoes an append that didn't complete, interrupted by a crash or failed:
+// the file gets its size and name from before the append back.
+func (s *Sink) recover(chainId string) error {
+	marker := s.pendingPath(chainId)
+	data, err := os.ReadFile(marker)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var pending pendingAppend
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return fmt.Errorf("invalid %s: %w", marker, err)
+	}
+	delete(s.current, chainId)
+
+	path := s.path(chainId, pending.From, pending.To)
+	if err := os.Rename(s.path(chainId, pending.From, pending.NewTo), path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if pending.Size == 0 {
+		// The append started the file
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if err := truncateSync(path, pending.Size); err != nil {
+		return err
+	}
+	if err := syncDir(s.chainDir(chainId)); err != nil {
+		return err
+	}
+	if err := os.Remove(marker); err != nil {
+		return err
+	}
+	return syncDir(s.chainDir(chainId))
+}
+
+// rotate tells whether blocks from on go to a new file.
+func (s *Sink) rotate(seg *segment, from uint64) bool {
+	if s.cfg.RotateBytes > 0 && seg.size >= s.cfg.RotateBytes {
+		return true
+	}
+	return s.cfg.RotateBlocks > 0 && from/s.cfg.RotateBlocks != seg.from/s.cfg.RotateBlocks
+}
+
+// encode writes lines as one compressed member.
+func (s *Sink) encode(w io.Writer, lines []Line) error {
+	var zw io.WriteCloser
+	switch s.cfg.Compression {
+	case CompressionGzip:
+		zw = gzip.NewWriter(w)
+	case CompressionZstd:
+		enc, err := zstd.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		zw = enc
+	default:
+		zw = nopCloser{w}
+	}
+
+	enc := json.NewEncoder(zw)
+	for _, line := range lines {
+		if err := enc.Encode(line); err != nil {
+			zw.Close()
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// Rollback removes the lines above ancestor: later files are deleted, the file containing ancestor is rewritten.
+func (s *Sink) Rollback(ctx context.Context, chainId string, ancestor uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.recover(chainId); err != nil {
+		return err
+	}
+	return s.rollback(chainId, ancestor+1)
+}
+
+// rollback removes the lines of blocks from on.
+func (s *Sink) rollback(chainId string, from uint64) error {
+	segments, err := s.segments(chainId)
+	if err != nil {
+		return err
+	}
+	delete(s.current, chainId)
+
+	for _, seg := range segments {
+		path := s.path(chainId, seg.from, seg.to)
+		switch {
+		case seg.to < from:
+			s.current[chainId] = seg
+		case seg.from >= from:
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		default:
+			kept, err := s.rewrite(chainId, seg, from-1)
+			if err != nil {
+				return err
+			}
+			s.current[chainId] = kept
+		}
+	}
+	return syncDir(s.chainDir(chainId))
+}
+
+// rewrite truncates the file of seg to the lines of blocks up to ancestor.
+func (s *Sink) rewrite(chainId string, seg *segment, ancestor uint64) (*segment, error) {
+	path := s.path(chainId, seg.from, seg.to)
+	lines, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	n := 0
+	for n < len(lines) && lines[n].BlockNumber <= ancestor {
+		n++
+	}
+
+	var buf bytes.Buffer
+	if err := s.encode(&buf, lines[:n]); err != nil {
+		return nil, err
+	}
+	kept := &segment{from: seg.from, to: ancestor, size: int64(buf.Len())}
+	tmp := path + ".tmp"
+	if err := writeFileSync(tmp, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, s.path(chainId, kept.from, kept.to)); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, err
+	}
+	return kept, nil
+}
+
+// Flush is a no-op, every Write is synced.
+func (s *Sink) Flush(ctx context.Context) error {
+	return nil
+}
+
+// segment returns the current file of a chain, found on disk after a restart.
+func (s *Sink) segment(chainId string) (*segment, error) {
+	if seg, ok := s.current[chainId]; ok {
+		return seg, nil
+	}
+	if err := os.MkdirAll(s.chainDir(chainId), 0o755); err != nil {
+		return nil, err
+	}
+	segments, err := s.segments(chainId)
+	if err != nil || len(segments) == 0 {
+		return nil, err
+	}
+	last := segments[len(segments)-1]
+	s.current[chainId] = last
+	return last, nil
+}
+
+// segments lists the files of a chain by block range.
+func (s *Sink) segments(chainId string) ([]*segment, error) {
+	entries, err := os.ReadDir(s.chainDir(chainId))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := s.suffix()
+	var segments []*segment
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), suffix)
+		if !ok {
+			continue
+		}
+		var seg segment
+		if _, err := fmt.Sscanf(name, "%d-%d", &seg.from, &seg.to); err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		seg.size = info.Size()
+		segments = append(segments, &seg)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].from < segments[j].from })
+	return segments, nil
+}
+
+func (s *Sink) chainDir(chainId string) string {
+	return filepath.Join(s.cfg.Dir, chainId)
+}
+
+func (s *Sink) path(chainId string, from, to uint64) string {
+	return filepath.Join(s.chainDir(chainId), fmt.Sprintf("%012d-%012d%s", from, to, s.suffix()))
+}
+
+func (s *Sink) pendingPath(chainId string) string {
+	return filepath.Join(s.chainDir(chainId), "append.pending")
+}
+
+func (s *Sink) suffix() string {
+	switch s.cfg.Compression {
+	case CompressionGzip:
+		return ".jsonl.gz"
+	case CompressionZstd:
+		return ".jsonl.zst"
+	}
+	return ".jsonl"
+}
+
+// ReadFile reads the lines of a file written by the sink, decompressing by extension.
+func ReadFile(path string) ([]Line, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			if err == io.EOF {
+				return nil, nil
+			}
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case strings.HasSuffix(path, ".zst"):
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	var lines []Line
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var line Line
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("invalid line in %s: %w", path, err)
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func truncateSync(path string, size int64) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir persists renames and removals in dir.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,169 @@
+package jsonl
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ryuux05/indexer-sdk-go/internal/sinktest"
+	"github.com/stretchr/testify/assert"
+)
+
+func files(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(filepath.Join(dir, "1"))
+	assert.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+// blocks reads the block numbers of every file of chain 1, in file order.
+func blocks(t *testing.T, dir string) []uint64 {
+	var out []uint64
+	for _, name := range files(t, dir) {
+		lines, err := ReadFile(filepath.Join(dir, "1", name))
+		assert.NoError(t, err)
+		for _, l := range lines {
+			out = append(out, l.BlockNumber)
+		}
+	}
+	return out
+}
+
+func TestSink_RotateBlocks(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sink, err := New(Config{Dir: dir, RotateBlocks: 10, Events: []string{sinktest.TransferDecl}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(1, 5, 2, 5)))
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(6, 14, 9, 12)))
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(15, 18)))
+
+	assert.Equal(t, []string{"000000000001-000000000009.jsonl", "000000000010-000000000018.jsonl"}, files(t, dir))
+	assert.Equal(t, []uint64{2, 5, 9, 12}, blocks(t, dir))
+
+	lines, err := ReadFile(filepath.Join(dir, "1", "000000000001-000000000009.jsonl"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, lines[0].Event) {
+		assert.Equal(t, "Transfer", lines[0].Event.Name)
+		assert.Equal(t, "200", lines[0].Event.Args["value"])
+	}
+}
+
+func TestSink_RotateBytes(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sink, err := New(Config{Dir: dir, RotateBytes: 1})
+	assert.NoError(t, err)
+
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(1, 5, 3)))
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(6, 10, 7)))
+	assert.Equal(t, []string{"000000000001-000000000005.jsonl", "000000000006-000000000010.jsonl"}, files(t, dir))
+}
+
+func TestSink_Compression(t *testing.T) {
+	for _, c := range []Compression{CompressionGzip, CompressionZstd} {
+		t.Run(string(c), func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			sink, err := New(Config{Dir: dir, Compression: c})
+			assert.NoError(t, err)
+
+			// One member per batch
+			assert.NoError(t, sink.Write(ctx, sinktest.Batch(1, 5, 1, 4)))
+			assert.NoError(t, sink.Write(ctx, sinktest.Batch(6, 10)))
+			assert.NoError(t, sink.Write(ctx, sinktest.Batch(11, 15, 12)))
+			assert.Len(t, files(t, dir), 1)
+			assert.Equal(t, []uint64{1, 4, 12}, blocks(t, dir))
+
+			assert.NoError(t, sink.Rollback(ctx, "1", 4))
+			assert.Equal(t, []uint64{1, 4}, blocks(t, dir))
+		})
+	}
+
+	_, err := New(Config{Dir: t.TempDir(), Compression: "lz4"})
+	assert.Error(t, err)
+}
+
+func TestSink_Rollback(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sink, err := New(Config{Dir: dir, RotateBlocks: 10})
+	assert.NoError(t, err)
+
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(1, 10, 2, 8)))
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(11, 20, 11, 15)))
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(21, 25, 22)))
+
+	// The tail file is dropped, the one containing the ancestor truncated
+	assert.NoError(t, sink.Rollback(ctx, "1", 12))
+	assert.Equal(t, []string{"000000000001-000000000009.jsonl", "000000000010-000000000012.jsonl"}, files(t, dir))
+	assert.Equal(t, []uint64{2, 8, 11}, blocks(t, dir))
+
+	// Writing resumes in the truncated file
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(13, 19, 13)))
+	assert.Equal(t, []string{"000000000001-000000000009.jsonl", "000000000010-000000000019.jsonl"}, files(t, dir))
+	assert.Equal(t, []uint64{2, 8, 11, 13}, blocks(t, dir))
+}
+
+func TestSink_ReplayAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sink, _ := New(Config{Dir: dir})
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(1, 5, 2)))
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(6, 10, 7)))
+
+	// A new sink finds the current file, a replayed window replaces its blocks
+	sink, _ = New(Config{Dir: dir})
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(6, 10, 7)))
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(11, 15, 14)))
+	assert.Equal(t, []string{"000000000001-000000000015.jsonl"}, files(t, dir))
+	assert.Equal(t, []uint64{2, 7, 14}, blocks(t, dir))
+}
+
+func TestSink_CrashDuringAppend(t *testing.T) {
+	for _, renamed := range []bool{false, true} {
+		ctx := context.Background()
+		dir := t.TempDir()
+		sink, _ := New(Config{Dir: dir, Compression: CompressionGzip})
+		assert.NoError(t, sink.Write(ctx, sinktest.Batch(1, 5, 2)))
+
+		// Blocks 6-10 are synced but the crash hits before the append is complete
+		seg := sink.current["1"]
+		assert.NoError(t, sink.markPending("1", pendingAppend{From: 1, To: 5, NewTo: 10, Size: seg.size}))
+		line, err := sink.line("1", sinktest.TransferLog(7, 0))
+		assert.NoError(t, err)
+		var buf bytes.Buffer
+		assert.NoError(t, sink.encode(&buf, []Line{line}))
+		path := sink.path("1", 1, 5)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		assert.NoError(t, err)
+		_, err = f.Write(buf.Bytes())
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+		if renamed {
+			assert.NoError(t, os.Rename(path, sink.path("1", 1, 10)))
+		}
+
+		// The replayed window isn't appended twice
+		sink, _ = New(Config{Dir: dir, Compression: CompressionGzip})
+		assert.NoError(t, sink.Write(ctx, sinktest.Batch(6, 10, 7)))
+		assert.Equal(t, []string{"000000000001-000000000010.jsonl.gz"}, files(t, dir))
+		assert.Equal(t, []uint64{2, 7}, blocks(t, dir))
+	}
+}
+
+func TestSink_ReplayFromGenesis(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sink, _ := New(Config{Dir: dir})
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(0, 5, 0, 3)))
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(0, 5, 0, 3)))
+	assert.Equal(t, []string{"000000000000-000000000005.jsonl"}, files(t, dir))
+	assert.Equal(t, []uint64{0, 3}, blocks(t, dir))
+}
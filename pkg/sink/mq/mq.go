@@ -0,0 +1,272 @@
+// Package mq is a core.Sink publishing every log as a keyed message through a broker Publisher.
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+)
+
+// DefaultRetainBlocks is how many recent blocks of messages are kept for rollbacks when Config.RetainBlocks is 0.
+const DefaultRetainBlocks = 256
+
+// Message is one log to publish.
+type Message struct {
+	// Key is "<chainId>:<address>", messages of a key are published in block and log order
+	Key string
+	// ID is unique per log and kind, "<chainId>:<blockHash>:<logIndex>" with a ":removed" suffix for rollbacks,
+	// consumers dedupe redeliveries with it. Rollback markers are "<chainId>:rollback:<ancestor>:<sequence>".
+	ID string
+	// Value is the JSON Envelope
+	Value []byte
+}
+
+// Publisher is a broker adapter.
+type Publisher interface {
+	// Publish sends msgs in order and returns once the broker acknowledged them.
+	// Brokers supporting it publish them atomically, others may leave a prefix published on error.
+	Publish(ctx context.Context, msgs []Message) error
+}
+
+// Config describes the published events.
+type Config struct {
+	// Events are event declarations, only matching logs are published, decoded.
+	// Empty publishes every log raw.
+	Events []string
+	// RetainBlocks bounds the blocks of published messages kept in memory to emit removed messages on rollback,
+	// DefaultRetainBlocks by default. Use at least Options.ReorgLookbackBlocks.
+	RetainBlocks uint64
+}
+
+// Envelope is the message value, a log like eth_subscribe delivers it with "removed" set on rollback.
+type Envelope struct {
+	ChainId          string `json:"chainId"`
+	BlockNumber      uint64 `json:"blockNumber"`
+	BlockHash        string `json:"blockHash"`
+	TransactionHash  string `json:"transactionHash"`
+	TransactionIndex uint64 `json:"transactionIndex"`
+	LogIndex         uint64 `json:"logIndex"`
+	Address          string `json:"address"`
+	Topics           []any  `json:"topics,omitempty"`
+	Data             string `json:"data,omitempty"`
+	// Name, Signature and Args of a decoded event, args use core.JSONValue
+	Name      string         `json:"name,omitempty"`
+	Signature string         `json:"signature,omitempty"`
+	Args      map[string]any `json:"args,omitempty"`
+	Removed   bool           `json:"removed"`
+	// Ancestor is set on rollback markers, the consumer drops every message of the chain above it
+	Ancestor *uint64 `json:"ancestor,omitempty"`
+}
+
+// Sink publishes each batch in one Publish call before Write returns, so the cursor only moves once the broker
+// acknowledged it. Delivery is at-least-once: a batch replayed after a restart is published again with the same IDs.
+//
+// On rollback the messages above the ancestor are published again, newest first, with Removed set.
+// Only the last RetainBlocks blocks published by this process are known, a restart forgets them: when the
+// retained blocks don't reach down to the ancestor, a rollback marker keyed "<chainId>" with Ancestor set follows
+// the removed messages. On partitioned brokers it lands on the partition of that key only.
+type Sink struct {
+	publisher Publisher
+	decoder   *core.Decoder
+	retain    uint64
+
+	mu sync.Mutex
+	// published messages of the retained blocks, by chain, in publish order
+	published map[string][]published
+	// since is the first block of a chain from which every published message is retained
+	since        map[string]uint64
+	lastRollback int64
+}
+
+type published struct {
+	block    uint64
+	key      string
+	id       string
+	envelope Envelope
+}
+
+// New creates the sink.
+func New(publisher Publisher, cfg Config) (*Sink, error) {
+	if publisher == nil {
+		return nil, fmt.Errorf("missing publisher")
+	}
+	s := &Sink{publisher: publisher, retain: cfg.RetainBlocks, published: make(map[string][]published), since: make(map[string]uint64)}
+	if s.retain == 0 {
+		s.retain = DefaultRetainBlocks
+	}
+	if len(cfg.Events) > 0 {
+		s.decoder = core.NewDecoder()
+		for _, e := range cfg.Events {
+			if _, err := s.decoder.Register(e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// Write publishes the logs of the batch.
+func (s *Sink) Write(ctx context.Context, batch core.Batch) error {
+	var entries []published
+	for _, l := range batch.Logs {
+		env, ok, err := s.envelope(batch.ChainId, l)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		entries = append(entries, published{
+			block:    env.BlockNumber,
+			key:      batch.ChainId + ":" + strings.ToLower(env.Address),
+			id:       fmt.Sprintf("%s:%s:%d", batch.ChainId, env.BlockHash, env.LogIndex),
+			envelope: env,
+		})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(entries) > 0 {
+		msgs, err := messages(entries, "")
+		if err != nil {
+			return err
+		}
+		if err := s.publisher.Publish(ctx, msgs); err != nil {
+			return fmt.Errorf("failed to publish blocks %d-%d: %w", batch.FromBlock, batch.ToBlock, err)
+		}
+	}
+
+	// Drop replayed entries, then the ones past the retention
+	kept := s.published[batch.ChainId]
+	n := len(kept)
+	for n > 0 && kept[n-1].block >= batch.FromBlock {
+		n--
+	}
+	kept = append(kept[:n], entries...)
+	i := 0
+	for i < len(kept) && kept[i].block+s.retain <= batch.ToBlock {
+		i++
+	}
+	s.published[batch.ChainId] = append([]published(nil), kept[i:]...)
+
+	if since, ok := s.since[batch.ChainId]; !ok || batch.FromBlock < since {
+		s.since[batch.ChainId] = batch.FromBlock
+	}
+	if batch.ToBlock >= s.retain && batch.ToBlock-s.retain+1 > s.since[batch.ChainId] {
+		s.since[batch.ChainId] = batch.ToBlock - s.retain + 1
+	}
+	return nil
+}
+
+func (s *Sink) envelope(chainId string, l core.Log) (Envelope, bool, error) {
+	env := Envelope{ChainId: chainId, BlockHash: l.BlockHash, TransactionHash: l.TransactionHash, Address: l.Address}
+	var err error
+	if env.BlockNumber, err = core.HexQtyToUint64(l.BlockNumber); err != nil {
+		return env, false, fmt.Errorf("invalid block number %q: %w", l.BlockNumber, err)
+	}
+	if env.TransactionIndex, err = core.HexQtyToUint64(l.TransactionIndex); err != nil {
+		return env, false, fmt.Errorf("invalid transaction index %q: %w", l.TransactionIndex, err)
+	}
+	if env.LogIndex, err = core.HexQtyToUint64(l.LogIndex); err != nil {
+		return env, false, fmt.Errorf("invalid log index %q: %w", l.LogIndex, err)
+	}
+
+	if s.decoder == nil {
+		env.Topics = l.Topics
+		env.Data = l.Data
+		return env, true, nil
+	}
+	decoded, err := s.decoder.Decode(l)
+	if err != nil {
+		// Logs of other events are not published
+		return env, false, nil
+	}
+	env.Name = decoded.Name
+	env.Signature = decoded.Signature
+	env.Args = make(map[string]any, len(decoded.Args))
+	for name, v := range decoded.Args {
+		env.Args[name] = core.JSONValue(v)
+	}
+	return env, true, nil
+}
+
+// messages encodes entries, suffix is appended to their IDs.
+func messages(entries []published, suffix string) ([]Message, error) {
+	msgs := make([]Message, len(entries))
+	for i, e := range entries {
+		value, err := json.Marshal(e.envelope)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling message %s: %w", e.id, err)
+		}
+		msgs[i] = Message{Key: e.key, ID: e.id + suffix, Value: value}
+	}
+	return msgs, nil
+}
+
+// Rollback publishes a removed message for every retained message above ancestor,
+// followed by a rollback marker when messages above ancestor may have been published before the retained ones.
+func (s *Sink) Rollback(ctx context.Context, chainId string, ancestor uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.published[chainId]
+	n := len(kept)
+	for n > 0 && kept[n-1].block > ancestor {
+		n--
+	}
+	since, ok := s.since[chainId]
+	covered := ok && ancestor+1 >= since
+	if n == len(kept) && covered {
+		return nil
+	}
+
+	removed := make([]published, 0, len(kept)-n)
+	for i := len(kept) - 1; i >= n; i-- {
+		e := kept[i]
+		e.envelope.Removed = true
+		removed = append(removed, e)
+	}
+	msgs, err := messages(removed, ":removed")
+	if err != nil {
+		return err
+	}
+	if !covered {
+		marker, err := s.rollbackMarker(chainId, ancestor)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, marker)
+	}
+	if err := s.publisher.Publish(ctx, msgs); err != nil {
+		return fmt.Errorf("failed to publish removed messages above block %d: %w", ancestor, err)
+	}
+	s.published[chainId] = kept[:n]
+	return nil
+}
+
+// rollbackMarker is the message telling consumers to drop every message of the chain above ancestor.
+// Its ID carries a strictly increasing sequence, so a later rollback to the same ancestor isn't deduped away.
+func (s *Sink) rollbackMarker(chainId string, ancestor uint64) (Message, error) {
+	seq := time.Now().UnixNano()
+	if seq <= s.lastRollback {
+		seq = s.lastRollback + 1
+	}
+	s.lastRollback = seq
+
+	id := fmt.Sprintf("%s:rollback:%d:%d", chainId, ancestor, seq)
+	value, err := json.Marshal(Envelope{ChainId: chainId, Removed: true, Ancestor: &ancestor})
+	if err != nil {
+		return Message{}, fmt.Errorf("error marshaling message %s: %w", id, err)
+	}
+	return Message{Key: chainId, ID: id, Value: value}, nil
+}
+
+// Flush is a no-op, Write returns once the broker acknowledged the batch.
+func (s *Sink) Flush(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,177 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ryuux05/indexer-sdk-go/internal/sinktest"
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	tokenA = sinktest.Token
+	tokenB = "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+)
+
+// memoryPublisher records published calls, failing the next one when err is set.
+type memoryPublisher struct {
+	mu    sync.Mutex
+	calls [][]Message
+	err   error
+}
+
+func (p *memoryPublisher) Publish(ctx context.Context, msgs []Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.err; err != nil {
+		p.err = nil
+		return err
+	}
+	p.calls = append(p.calls, msgs)
+	return nil
+}
+
+func (p *memoryPublisher) last() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls[len(p.calls)-1]
+}
+
+func decode(t *testing.T, m Message) Envelope {
+	var env Envelope
+	assert.NoError(t, json.Unmarshal(m.Value, &env))
+	return env
+}
+
+func TestSink_Publish(t *testing.T) {
+	ctx := context.Background()
+	pub := &memoryPublisher{}
+	sink, err := New(pub, Config{Events: []string{sinktest.TransferDecl}})
+	assert.NoError(t, err)
+
+	other := sinktest.Transfer{Address: tokenA, Block: 3, LogIndex: 2}.Log()
+	other.Topics = []any{fmt.Sprintf("0x%064x", 1)}
+	batch := core.Batch{ChainId: "1", FromBlock: 1, ToBlock: 10, Logs: []core.Log{
+		sinktest.Transfer{Address: tokenA, Block: 3, LogIndex: 0}.Log(), sinktest.Transfer{Address: tokenB, Block: 3, LogIndex: 1}.Log(), other, sinktest.Transfer{Address: tokenA, Block: 5, LogIndex: 0}.Log(),
+	}}
+	assert.NoError(t, sink.Write(ctx, batch))
+	// Batches without logs to publish make no call
+	assert.NoError(t, sink.Write(ctx, core.Batch{ChainId: "1", FromBlock: 11, ToBlock: 20}))
+
+	assert.Len(t, pub.calls, 1)
+	msgs := pub.last()
+	if assert.Len(t, msgs, 3) {
+		assert.Equal(t, "1:"+tokenA, msgs[0].Key)
+		assert.Equal(t, "1:"+strings.ToLower(tokenB), msgs[1].Key)
+		assert.Equal(t, "1:"+tokenA, msgs[2].Key)
+		assert.Equal(t, fmt.Sprintf("1:0x%064x:1", 3), msgs[1].ID)
+
+		env := decode(t, msgs[2])
+		assert.Equal(t, "Transfer", env.Name)
+		assert.Equal(t, uint64(5), env.BlockNumber)
+		assert.Equal(t, "500", env.Args["value"])
+		assert.False(t, env.Removed)
+	}
+
+	// A failed publish fails the batch, so the window is retried
+	pub.err = errors.New("broker down")
+	assert.ErrorContains(t, sink.Write(ctx, core.Batch{ChainId: "1", FromBlock: 21, ToBlock: 30, Logs: []core.Log{sinktest.Transfer{Address: tokenA, Block: 22, LogIndex: 0}.Log()}}), "broker down")
+}
+
+func TestSink_Rollback(t *testing.T) {
+	ctx := context.Background()
+	pub := &memoryPublisher{}
+	sink, err := New(pub, Config{RetainBlocks: 20})
+	assert.NoError(t, err)
+
+	assert.NoError(t, sink.Write(ctx, core.Batch{ChainId: "1", FromBlock: 1, ToBlock: 10, Logs: []core.Log{sinktest.Transfer{Address: tokenA, Block: 2, LogIndex: 0}.Log()}}))
+	assert.NoError(t, sink.Write(ctx, core.Batch{ChainId: "1", FromBlock: 11, ToBlock: 20, Logs: []core.Log{
+		sinktest.Transfer{Address: tokenA, Block: 12, LogIndex: 0}.Log(), sinktest.Transfer{Address: tokenB, Block: 12, LogIndex: 1}.Log(), sinktest.Transfer{Address: tokenA, Block: 18, LogIndex: 0}.Log(),
+	}}))
+	assert.NoError(t, sink.Write(ctx, core.Batch{ChainId: "1", FromBlock: 21, ToBlock: 30, Logs: []core.Log{sinktest.Transfer{Address: tokenB, Block: 25, LogIndex: 0}.Log()}}))
+
+	// Newest first, same keys, raw logs without Events
+	assert.NoError(t, sink.Rollback(ctx, "1", 12))
+	msgs := pub.last()
+	var ids []string
+	for _, m := range msgs {
+		assert.True(t, decode(t, m).Removed)
+		ids = append(ids, m.ID)
+	}
+	assert.Equal(t, sinktest.Transfer{Address: tokenA, Block: 18, LogIndex: 0}.Log().Data, decode(t, msgs[1]).Data)
+	assert.Equal(t, []string{
+		fmt.Sprintf("1:0x%064x:0:removed", 25),
+		fmt.Sprintf("1:0x%064x:0:removed", 18),
+	}, ids)
+	assert.Equal(t, "1:"+strings.ToLower(tokenB), msgs[0].Key)
+
+	// Nothing left above the ancestor
+	calls := len(pub.calls)
+	assert.NoError(t, sink.Rollback(ctx, "1", 12))
+	assert.Len(t, pub.calls, calls)
+
+	// Block 2 is past the retention, a marker follows the retained messages
+	assert.NoError(t, sink.Rollback(ctx, "1", 0))
+	msgs = pub.last()
+	if assert.Len(t, msgs, 3) {
+		assert.Equal(t, fmt.Sprintf("1:0x%064x:1:removed", 12), msgs[0].ID)
+		assert.Equal(t, "1", msgs[2].Key)
+		assert.True(t, strings.HasPrefix(msgs[2].ID, "1:rollback:0:"))
+		env := decode(t, msgs[2])
+		assert.True(t, env.Removed)
+		if assert.NotNil(t, env.Ancestor) {
+			assert.Equal(t, uint64(0), *env.Ancestor)
+		}
+	}
+}
+
+func TestSink_RollbackPastRetention(t *testing.T) {
+	ctx := context.Background()
+	pub := &memoryPublisher{}
+	sink, _ := New(pub, Config{})
+
+	// Nothing published by this process, the messages above the ancestor may predate a restart
+	assert.NoError(t, sink.Rollback(ctx, "1", 40))
+	first := pub.last()
+	if assert.Len(t, first, 1) {
+		assert.True(t, strings.HasPrefix(first[0].ID, "1:rollback:40:"))
+	}
+
+	// Resumed at block 41, the retained messages cover a rollback to 40 but not to 39
+	assert.NoError(t, sink.Write(ctx, core.Batch{ChainId: "1", FromBlock: 41, ToBlock: 50, Logs: []core.Log{sinktest.Transfer{Address: tokenA, Block: 45}.Log()}}))
+	assert.NoError(t, sink.Rollback(ctx, "1", 40))
+	assert.Len(t, pub.last(), 1)
+	assert.Equal(t, fmt.Sprintf("1:0x%064x:0:removed", 45), pub.last()[0].ID)
+
+	assert.NoError(t, sink.Rollback(ctx, "1", 39))
+	if assert.Len(t, pub.last(), 1) {
+		assert.True(t, strings.HasPrefix(pub.last()[0].ID, "1:rollback:39:"))
+	}
+
+	// Another rollback to the same ancestor is a distinct message
+	assert.NoError(t, sink.Rollback(ctx, "2", 40))
+	assert.NoError(t, sink.Rollback(ctx, "2", 40))
+	calls := pub.calls[len(pub.calls)-2:]
+	assert.NotEqual(t, calls[0][0].ID, calls[1][0].ID)
+}
+
+func TestSink_ReplayedWindow(t *testing.T) {
+	ctx := context.Background()
+	pub := &memoryPublisher{}
+	sink, _ := New(pub, Config{})
+
+	batch := core.Batch{ChainId: "1", FromBlock: 1, ToBlock: 10, Logs: []core.Log{sinktest.Transfer{Address: tokenA, Block: 4, LogIndex: 0}.Log()}}
+	assert.NoError(t, sink.Write(ctx, batch))
+	assert.NoError(t, sink.Write(ctx, batch))
+	assert.Equal(t, pub.calls[0], pub.calls[1], "a replay republishes the same IDs")
+
+	// The replay replaced the retained messages instead of doubling them
+	assert.NoError(t, sink.Rollback(ctx, "1", 0))
+	assert.Len(t, pub.last(), 1)
+}
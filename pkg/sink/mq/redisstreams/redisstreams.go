@@ -0,0 +1,63 @@
+// Package redisstreams is an mq.Publisher appending messages to a Redis stream.
+package redisstreams
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/ryuux05/indexer-sdk-go/pkg/sink/mq"
+)
+
+// DefaultStream is the stream name when Config.Stream is empty.
+const DefaultStream = "indexer:events"
+
+// Entry fields of every message.
+const (
+	FieldKey   = "key"
+	FieldID    = "id"
+	FieldValue = "value"
+)
+
+// Config describes the target stream.
+type Config struct {
+	Stream string
+	// MaxLen approximately trims the stream to its last MaxLen entries, 0 keeps everything
+	MaxLen int64
+}
+
+// Publisher appends each Publish call to one stream in a MULTI/EXEC transaction, so a batch is either
+// fully visible to consumers or not at all. A single stream is totally ordered, which keeps the order
+// of every key; consumer groups can shard by the "key" field.
+type Publisher struct {
+	client redis.UniversalClient
+	stream string
+	maxLen int64
+}
+
+// New creates the publisher, the client is owned by the caller.
+func New(client redis.UniversalClient, cfg Config) *Publisher {
+	if cfg.Stream == "" {
+		cfg.Stream = DefaultStream
+	}
+	return &Publisher{client: client, stream: cfg.Stream, maxLen: cfg.MaxLen}
+}
+
+// Publish appends msgs in order in one transaction.
+func (p *Publisher) Publish(ctx context.Context, msgs []mq.Message) error {
+	_, err := p.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, m := range msgs {
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: p.stream,
+				MaxLen: p.maxLen,
+				Approx: p.maxLen > 0,
+				Values: []any{FieldKey, m.Key, FieldID, m.ID, FieldValue, m.Value},
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to append to stream %s: %w", p.stream, err)
+	}
+	return nil
+}
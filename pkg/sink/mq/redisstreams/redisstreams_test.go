@@ -0,0 +1,67 @@
+package redisstreams
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/ryuux05/indexer-sdk-go/internal/sinktest"
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+	"github.com/ryuux05/indexer-sdk-go/pkg/sink/mq"
+	"github.com/stretchr/testify/assert"
+)
+
+func newClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func TestPublisher_Sink(t *testing.T) {
+	ctx := context.Background()
+	_, client := newClient(t)
+	sink, err := mq.New(New(client, Config{Stream: "events"}), mq.Config{Events: []string{sinktest.TransferDecl}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, sink.Write(ctx, core.Batch{ChainId: "1", FromBlock: 1, ToBlock: 10, Logs: []core.Log{sinktest.TransferLog(3, 0), sinktest.TransferLog(7, 0)}}))
+	assert.NoError(t, sink.Rollback(ctx, "1", 5))
+
+	entries, err := client.XRange(ctx, "events", "-", "+").Result()
+	assert.NoError(t, err)
+	var ids []string
+	var removed []bool
+	for _, e := range entries {
+		assert.Equal(t, "1:"+sinktest.Token, e.Values[FieldKey])
+		ids = append(ids, e.Values[FieldID].(string))
+		var env mq.Envelope
+		assert.NoError(t, json.Unmarshal([]byte(e.Values[FieldValue].(string)), &env))
+		removed = append(removed, env.Removed)
+	}
+	assert.Equal(t, []string{
+		fmt.Sprintf("1:0x%064x:0", 3),
+		fmt.Sprintf("1:0x%064x:0", 7),
+		fmt.Sprintf("1:0x%064x:0:removed", 7),
+	}, ids)
+	assert.Equal(t, []bool{false, false, true}, removed)
+}
+
+func TestPublisher_Transactional(t *testing.T) {
+	ctx := context.Background()
+	server, client := newClient(t)
+	pub := New(client, Config{})
+
+	// A key of another type makes XADD fail inside the transaction
+	server.Set(DefaultStream, "not a stream")
+	err := pub.Publish(ctx, []mq.Message{{Key: "k", ID: "1", Value: []byte("{}")}, {Key: "k", ID: "2", Value: []byte("{}")}})
+	assert.Error(t, err)
+
+	server.Del(DefaultStream)
+	assert.NoError(t, pub.Publish(ctx, []mq.Message{{Key: "k", ID: "1", Value: []byte("{}")}, {Key: "k", ID: "2", Value: []byte("{}")}}))
+	n, err := client.XLen(ctx, DefaultStream).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
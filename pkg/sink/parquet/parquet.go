@@ -0,0 +1,361 @@
+// Package parquet is a core.Sink exporting decoded events to Parquet files, for backfills loaded into DuckDB or Spark.
+package parquet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress/zstd"
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+)
+
+// DefaultPartitionBlocks is the block range of a file when Config.PartitionBlocks is 0.
+const DefaultPartitionBlocks = 100_000
+
+// Config describes the exported events and the file layout.
+type Config struct {
+	// Dir is the root of the dataset: Dir/chain_id=<id>/event=<name>/<from>-<to>.parquet
+	Dir string
+	// Events are the event declarations to export, logs of other events are skipped
+	Events []string
+	// PartitionBlocks is the aligned block range of a file, DefaultPartitionBlocks by default
+	PartitionBlocks uint64
+	// Numeric encodes integers wider than 64 bits, NumericBinary by default
+	Numeric Numeric
+}
+
+// Sink buffers the rows of the current partition of each chain, and writes one file per event
+// once the partition is complete, or on Flush. Files are immutable once written.
+//
+// Rows of an incomplete partition are lost on a crash while the cursor may have moved on,
+// pair the sink with an Options.EndBlock bounded backfill and rerun the range after a failure.
+// A graceful Stop flushes the partial partition.
+type Sink struct {
+	dir       string
+	partition uint64
+	tables    map[core.EventKey]*table
+
+	mu sync.Mutex
+	// open partition of each chain
+	open map[string]*partition
+}
+
+// partition buffers blocks [from..to] of a chain.
+type partition struct {
+	from uint64
+	to   uint64
+	rows map[*table][]parquet.Row
+}
+
+// New creates the sink, directories are created on first write.
+func New(cfg Config) (*Sink, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("missing directory")
+	}
+	if cfg.PartitionBlocks == 0 {
+		cfg.PartitionBlocks = DefaultPartitionBlocks
+	}
+	switch cfg.Numeric {
+	case "":
+		cfg.Numeric = NumericBinary
+	case NumericBinary, NumericDecimal:
+	default:
+		return nil, fmt.Errorf("unsupported numeric encoding %q", cfg.Numeric)
+	}
+
+	s := &Sink{
+		dir:       cfg.Dir,
+		partition: cfg.PartitionBlocks,
+		tables:    make(map[core.EventKey]*table, len(cfg.Events)),
+		open:      make(map[string]*partition),
+	}
+	names := make(map[string]string, len(cfg.Events))
+	for _, declaration := range cfg.Events {
+		event, err := core.ParseEvent(declaration)
+		if err != nil {
+			return nil, err
+		}
+		if event.Anonymous {
+			return nil, fmt.Errorf("anonymous event %s can't be matched by topic", event.Signature())
+		}
+		if other, ok := names[event.Name]; ok {
+			return nil, fmt.Errorf("events %s and %s share the name %s, use distinct sinks", other, declaration, event.Name)
+		}
+		names[event.Name] = declaration
+
+		t, err := newTable(event, cfg.Numeric)
+		if err != nil {
+			return nil, err
+		}
+		s.tables[event.Key()] = t
+	}
+	return s, nil
+}
+
+// Write buffers the rows of the batch, writing every partition it completes.
+// A batch overlapping blocks already buffered or written, e.g. replayed after a failed commit or a restart,
+// first rolls them back.
+func (s *Sink) Write(ctx context.Context, batch core.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Written files are only looked up when no partition is open, the open one follows them
+	if p := s.open[batch.ChainId]; p == nil || batch.FromBlock <= p.to {
+		if err := s.rollback(batch.ChainId, batch.FromBlock); err != nil {
+			return err
+		}
+	}
+
+	logs := batch.Logs
+	for from := batch.FromBlock; from <= batch.ToBlock; {
+		end := from - from%s.partition + s.partition - 1
+		to := min(end, batch.ToBlock)
+
+		p := s.open[batch.ChainId]
+		if p == nil {
+			p = &partition{from: from, rows: make(map[*table][]parquet.Row)}
+			s.open[batch.ChainId] = p
+		}
+		for len(logs) > 0 {
+			n, err := core.HexQtyToUint64(logs[0].BlockNumber)
+			if err != nil {
+				return fmt.Errorf("invalid block number %q: %w", logs[0].BlockNumber, err)
+			}
+			if n > to {
+				break
+			}
+			if err := s.add(batch.ChainId, p, logs[0]); err != nil {
+				return err
+			}
+			logs = logs[1:]
+		}
+		p.to = to
+
+		if to == end {
+			if err := s.writePartition(batch.ChainId, p); err != nil {
+				return err
+			}
+			delete(s.open, batch.ChainId)
+		}
+		from = to + 1
+	}
+	return nil
+}
+
+// add buffers the row of a log of an exported event.
+func (s *Sink) add(chainId string, p *partition, l core.Log) error {
+	key, ok := core.LogEventKey(l)
+	if !ok {
+		return nil
+	}
+	t, ok := s.tables[key]
+	if !ok {
+		return nil
+	}
+
+	ev, err := t.event.DecodeLog(l)
+	if err != nil {
+		return fmt.Errorf("failed to decode log %s of transaction %s: %w", l.LogIndex, l.TransactionHash, err)
+	}
+	row, err := t.row(chainId, ev)
+	if err != nil {
+		return err
+	}
+	p.rows[t] = append(p.rows[t], row)
+	return nil
+}
+
+// writePartition writes one file per event with rows.
+func (s *Sink) writePartition(chainId string, p *partition) error {
+	for t, rows := range p.rows {
+		if len(rows) == 0 {
+			continue
+		}
+		dir := filepath.Join(s.chainDir(chainId), t.dirName())
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(dir, fileName(p.from, p.to)), t.schema, rows); err != nil {
+			return fmt.Errorf("failed to write %s blocks %d-%d: %w", t.event.Name, p.from, p.to, err)
+		}
+	}
+	return nil
+}
+
+// Rollback drops the buffered rows above ancestor, deletes the files above it and rewrites the ones containing it.
+func (s *Sink) Rollback(ctx context.Context, chainId string, ancestor uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rollback(chainId, ancestor+1)
+}
+
+// rollback removes the rows of blocks from on.
+func (s *Sink) rollback(chainId string, from uint64) error {
+	if p := s.open[chainId]; p != nil {
+		if p.from >= from {
+			delete(s.open, chainId)
+		} else {
+			p.to = min(p.to, from-1)
+			for t, rows := range p.rows {
+				n := len(rows)
+				for n > 0 && t.blockNumber(rows[n-1]) >= from {
+					n--
+				}
+				p.rows[t] = rows[:n]
+			}
+		}
+	}
+
+	for _, t := range s.tables {
+		dir := filepath.Join(s.chainDir(chainId), t.dirName())
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			var fileFrom, fileTo uint64
+			if _, err := fmt.Sscanf(strings.TrimSuffix(e.Name(), ".parquet"), "%d-%d", &fileFrom, &fileTo); err != nil || fileTo < from {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if fileFrom >= from {
+				if err := os.Remove(path); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := s.truncate(t, path, filepath.Join(dir, fileName(fileFrom, from-1)), from-1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// truncate rewrites the rows of blocks up to ancestor of a file to newPath.
+func (s *Sink) truncate(t *table, path, newPath string, ancestor uint64) error {
+	rows, err := ReadRows(path)
+	if err != nil {
+		return err
+	}
+	kept := rows[:0]
+	for _, row := range rows {
+		if t.blockNumber(row) <= ancestor {
+			kept = append(kept, row)
+		}
+	}
+	if len(kept) > 0 {
+		if err := writeFile(newPath, t.schema, kept); err != nil {
+			return err
+		}
+	}
+	return os.Remove(path)
+}
+
+// Flush writes the partial partitions of every chain, e.g. on a graceful stop.
+func (s *Sink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chains := make([]string, 0, len(s.open))
+	for chainId := range s.open {
+		chains = append(chains, chainId)
+	}
+	sort.Strings(chains)
+	for _, chainId := range chains {
+		if err := s.flushChain(chainId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FlushChain writes the open partition of one chain, the processor calls it when the chain stops alone.
+func (s *Sink) FlushChain(ctx context.Context, chainId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flushChain(chainId)
+}
+
+func (s *Sink) flushChain(chainId string) error {
+	p, ok := s.open[chainId]
+	if !ok {
+		return nil
+	}
+	if err := s.writePartition(chainId, p); err != nil {
+		return err
+	}
+	delete(s.open, chainId)
+	return nil
+}
+
+func (s *Sink) chainDir(chainId string) string {
+	return filepath.Join(s.dir, "chain_id="+chainId)
+}
+
+func fileName(from, to uint64) string {
+	return fmt.Sprintf("%012d-%012d.parquet", from, to)
+}
+
+// writeFile writes rows to path through a synced temporary file, so readers never see a partial file.
+func writeFile(path string, schema *parquet.Schema, rows []parquet.Row) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	w := parquet.NewWriter(f, schema, parquet.Compression(&zstd.Codec{}))
+	if _, err := w.WriteRows(rows); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReadRows reads back every row of a file written by the sink.
+func ReadRows(path string) ([]parquet.Row, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := parquet.NewReader(f)
+	defer r.Close()
+	rows := make([]parquet.Row, r.NumRows())
+	n := 0
+	for n < len(rows) {
+		read, err := r.ReadRows(rows[n:])
+		n += read
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rows[:n], nil
+}
@@ -0,0 +1,210 @@
+package parquet
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/ryuux05/indexer-sdk-go/internal/sinktest"
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func transferDir(dir string) string {
+	return filepath.Join(dir, "chain_id=1", "event=Transfer")
+}
+
+func files(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(transferDir(dir))
+	assert.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+// blocks reads the block numbers of every Transfer file of chain 1, in file order.
+func blocks(t *testing.T, dir string) []uint64 {
+	event, _ := core.ParseEvent(sinktest.TransferDecl)
+	table, _ := newTable(event, NumericBinary)
+	var out []uint64
+	for _, name := range files(t, dir) {
+		rows, err := ReadRows(filepath.Join(transferDir(dir), name))
+		assert.NoError(t, err)
+		for _, row := range rows {
+			out = append(out, table.blockNumber(row))
+		}
+	}
+	return out
+}
+
+func TestNewTable_Schema(t *testing.T) {
+	event, err := core.ParseEvent("Swap(address indexed sender, uint8 kind, int24 tick, uint64 seq, int256 amount, bytes4 selector, bytes data, string indexed memo, uint256[] ids, bool address)")
+	assert.NoError(t, err)
+	table, err := newTable(event, NumericDecimal)
+	assert.NoError(t, err)
+
+	types := map[string]string{}
+	for _, f := range table.schema.Fields() {
+		types[f.Name()] = f.Type().String()
+	}
+	assert.Equal(t, "FIXED_LEN_BYTE_ARRAY(20)", types["address"])
+	assert.Equal(t, "FIXED_LEN_BYTE_ARRAY(20)", types["sender"])
+	assert.Equal(t, "INT(8,false)", types["kind"])
+	assert.Equal(t, "INT(32,true)", types["tick"])
+	assert.Equal(t, "INT(64,false)", types["seq"])
+	assert.Equal(t, "DECIMAL(76,0)", types["amount"])
+	assert.Equal(t, "FIXED_LEN_BYTE_ARRAY(4)", types["selector"])
+	assert.Equal(t, "BYTE_ARRAY", types["data"])
+	assert.Equal(t, "FIXED_LEN_BYTE_ARRAY(32)", types["memo"])
+	assert.Equal(t, "JSON", types["ids"])
+	assert.Equal(t, "BOOLEAN", types["evt_address"])
+	assert.Equal(t, "TIMESTAMP(isAdjustedToUTC=true,unit=MILLIS)", types["block_timestamp"])
+}
+
+func TestSink_Partitions(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sink, err := New(Config{Dir: dir, PartitionBlocks: 10, Events: []string{sinktest.TransferDecl}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(1, 5, 2, 5)))
+	// Completes the first partition
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(6, 14, 9, 12)))
+	assert.Equal(t, []string{"000000000001-000000000009.parquet"}, files(t, dir))
+
+	// An empty partition writes no file
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(15, 25, 15)))
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(26, 27)))
+	assert.NoError(t, sink.Flush(ctx))
+	assert.Equal(t, []string{"000000000001-000000000009.parquet", "000000000010-000000000019.parquet"}, files(t, dir))
+	assert.Equal(t, []uint64{2, 5, 9, 12, 15}, blocks(t, dir))
+}
+
+func TestSink_FlushChain(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sink, err := New(Config{Dir: dir, PartitionBlocks: 10, Events: []string{sinktest.TransferDecl}})
+	assert.NoError(t, err)
+
+	other := sinktest.Batch(1, 5, 3)
+	other.ChainId = "2"
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(1, 5, 2)))
+	assert.NoError(t, sink.Write(ctx, other))
+
+	// The partial partition of chain 2 stays open
+	assert.NoError(t, sink.FlushChain(ctx, "1"))
+	assert.Equal(t, []string{"000000000001-000000000005.parquet"}, files(t, dir))
+	_, err = os.Stat(filepath.Join(dir, "chain_id=2"))
+	assert.True(t, os.IsNotExist(err))
+	assert.NoError(t, sink.FlushChain(ctx, "1"))
+
+	assert.NoError(t, sink.Flush(ctx))
+	_, err = os.Stat(filepath.Join(dir, "chain_id=2", "event=Transfer", "000000000001-000000000005.parquet"))
+	assert.NoError(t, err)
+}
+
+func TestSink_Values(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sink, err := New(Config{Dir: dir, Events: []string{sinktest.TransferDecl}})
+	assert.NoError(t, err)
+
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	l := sinktest.Transfer{Block: 7, Value: max}.Log()
+	l.Context = &core.DecodeContext{BlockTimestamp: time.Unix(1700000000, 0)}
+	assert.NoError(t, sink.Write(ctx, core.Batch{ChainId: "1", FromBlock: 1, ToBlock: 10, Logs: []core.Log{l, sinktest.Transfer{Block: 8, Value: big.NewInt(5)}.Log()}}))
+	assert.NoError(t, sink.Flush(ctx))
+
+	f, err := os.Open(filepath.Join(transferDir(dir), "000000000001-000000000010.parquet"))
+	assert.NoError(t, err)
+	defer f.Close()
+	type transfer struct {
+		ChainId        string   `parquet:"chain_id"`
+		BlockNumber    uint64   `parquet:"block_number"`
+		BlockTimestamp *int64   `parquet:"block_timestamp,optional"`
+		Address        [20]byte `parquet:"address"`
+		From           [20]byte `parquet:"from"`
+		Value          [32]byte `parquet:"value"`
+	}
+	info, err := f.Stat()
+	assert.NoError(t, err)
+	rows, err := parquet.Read[transfer](f, info.Size())
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, "1", rows[0].ChainId)
+		assert.Equal(t, uint64(7), rows[0].BlockNumber)
+		if assert.NotNil(t, rows[0].BlockTimestamp) {
+			assert.Equal(t, int64(1700000000000), *rows[0].BlockTimestamp)
+		}
+		assert.Nil(t, rows[1].BlockTimestamp)
+		assert.Equal(t, sinktest.Token, "0x"+hex.EncodeToString(rows[0].Address[:]))
+		assert.Equal(t, fmt.Sprintf("%040x", 7), hex.EncodeToString(rows[0].From[:]))
+		assert.Equal(t, max, new(big.Int).SetBytes(rows[0].Value[:]))
+		assert.Equal(t, big.NewInt(5), new(big.Int).SetBytes(rows[1].Value[:]))
+	}
+}
+
+func TestSink_Rollback(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sink, err := New(Config{Dir: dir, PartitionBlocks: 10, Events: []string{sinktest.TransferDecl}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(1, 9, 2, 8)))
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(10, 19, 11, 15)))
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(20, 25, 22)))
+
+	// The buffered partition is dropped, the file containing the ancestor truncated
+	assert.NoError(t, sink.Rollback(ctx, "1", 12))
+	assert.Equal(t, []string{"000000000001-000000000009.parquet", "000000000010-000000000012.parquet"}, files(t, dir))
+	assert.Equal(t, []uint64{2, 8, 11}, blocks(t, dir))
+
+	// Buffered rows above the ancestor are dropped
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(13, 24, 13, 21, 23)))
+	assert.NoError(t, sink.Rollback(ctx, "1", 22))
+	assert.NoError(t, sink.Flush(ctx))
+	assert.Equal(t, []string{"000000000001-000000000009.parquet", "000000000010-000000000012.parquet", "000000000013-000000000019.parquet", "000000000020-000000000022.parquet"}, files(t, dir))
+	assert.Equal(t, []uint64{2, 8, 11, 13, 21}, blocks(t, dir))
+}
+
+func TestSink_Replay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sink, err := New(Config{Dir: dir, PartitionBlocks: 10, Events: []string{sinktest.TransferDecl}})
+	assert.NoError(t, err)
+
+	// A window written again replaces its buffered rows
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(1, 5, 2, 5)))
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(1, 5, 2, 5)))
+	// And truncates the file it completed like a rollback
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(6, 12, 8, 11)))
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(6, 12, 8, 11)))
+	assert.NoError(t, sink.Flush(ctx))
+	assert.Equal(t, []string{"000000000001-000000000005.parquet", "000000000006-000000000009.parquet", "000000000010-000000000012.parquet"}, files(t, dir))
+	assert.Equal(t, []uint64{2, 5, 8, 11}, blocks(t, dir))
+
+	// A new sink replays from the first block after a restart
+	sink, _ = New(Config{Dir: dir, PartitionBlocks: 10, Events: []string{sinktest.TransferDecl}})
+	assert.NoError(t, sink.Write(ctx, sinktest.Batch(0, 3, 0, 2)))
+	assert.NoError(t, sink.Flush(ctx))
+	assert.Equal(t, []string{"000000000000-000000000003.parquet"}, files(t, dir))
+	assert.Equal(t, []uint64{0, 2}, blocks(t, dir))
+}
+
+func TestNew_Errors(t *testing.T) {
+	_, err := New(Config{})
+	assert.Error(t, err)
+	_, err = New(Config{Dir: t.TempDir(), Numeric: "float"})
+	assert.Error(t, err)
+	// ERC-20 and ERC-721 Transfer would share a directory
+	_, err = New(Config{Dir: t.TempDir(), Events: []string{sinktest.TransferDecl, "Transfer(address indexed from, address indexed to, uint256 indexed tokenId)"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,235 @@
+package parquet
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+)
+
+// Numeric is the encoding of integers wider than 64 bits.
+type Numeric string
+
+const (
+	// NumericBinary stores the 32 bytes big-endian two's complement word, lossless.
+	NumericBinary Numeric = "binary"
+	// NumericDecimal annotates the same bytes as DECIMAL(76, 0). Values of 10^76 and more,
+	// like type(uint256).max approvals, exceed the declared precision.
+	NumericDecimal Numeric = "decimal"
+)
+
+// Metadata columns of every event file.
+const (
+	colChainId          = "chain_id"
+	colBlockNumber      = "block_number"
+	colBlockHash        = "block_hash"
+	colBlockTimestamp   = "block_timestamp"
+	colTransactionHash  = "tx_hash"
+	colTransactionIndex = "tx_index"
+	colLogIndex         = "log_index"
+	colAddress          = "address"
+)
+
+// table is the file schema of one event.
+type table struct {
+	event  core.Event
+	schema *parquet.Schema
+	// columns are the parameter column names, in declaration order
+	columns []string
+	// index of each column in a row, parquet orders group fields by name
+	index map[string]int
+}
+
+// newTable derives the schema of an event: metadata columns, then one column per parameter,
+// named as declared, unnamed ones arg0, arg1...
+func newTable(event core.Event, numeric Numeric) (*table, error) {
+	group := parquet.Group{
+		colChainId:          parquet.String(),
+		colBlockNumber:      parquet.Uint(64),
+		colBlockHash:        parquet.Leaf(parquet.FixedLenByteArrayType(32)),
+		colBlockTimestamp:   parquet.Optional(parquet.Timestamp(parquet.Millisecond)),
+		colTransactionHash:  parquet.Leaf(parquet.FixedLenByteArrayType(32)),
+		colTransactionIndex: parquet.Uint(64),
+		colLogIndex:         parquet.Uint(64),
+		colAddress:          parquet.Leaf(parquet.FixedLenByteArrayType(20)),
+	}
+
+	t := &table{event: event, index: make(map[string]int)}
+	for i, in := range event.Inputs {
+		name := in.Name
+		if name == "" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		// Parameters named like metadata, e.g. "address"
+		if _, ok := group[name]; ok {
+			name = "evt_" + name
+		}
+		if _, ok := group[name]; ok {
+			return nil, fmt.Errorf("event %s has duplicate column %s", event.Signature(), name)
+		}
+		group[name] = columnNode(in, numeric)
+		t.columns = append(t.columns, name)
+	}
+
+	t.schema = parquet.NewSchema(event.Name, group)
+	for name := range group {
+		leaf, _ := t.schema.Lookup(name)
+		t.index[name] = leaf.ColumnIndex
+	}
+	return t, nil
+}
+
+// columnNode maps an ABI type to its parquet column.
+func columnNode(arg core.Argument, numeric Numeric) parquet.Node {
+	t := arg.Type
+	if arg.Indexed && isHashedIndexed(t) {
+		// Only the keccak256 is in the topic
+		return parquet.Leaf(parquet.FixedLenByteArrayType(32))
+	}
+	switch t.Kind {
+	case core.UintKind, core.IntKind:
+		if t.Size <= 64 {
+			bits := 8
+			for bits < t.Size {
+				bits *= 2
+			}
+			if t.Kind == core.UintKind {
+				return parquet.Uint(bits)
+			}
+			return parquet.Int(bits)
+		}
+		if numeric == NumericDecimal {
+			return parquet.Decimal(0, 76, parquet.FixedLenByteArrayType(32))
+		}
+		return parquet.Leaf(parquet.FixedLenByteArrayType(32))
+	case core.BoolKind:
+		return parquet.Leaf(parquet.BooleanType)
+	case core.AddressKind:
+		return parquet.Leaf(parquet.FixedLenByteArrayType(20))
+	case core.FixedBytesKind:
+		return parquet.Leaf(parquet.FixedLenByteArrayType(t.Size))
+	case core.BytesKind:
+		return parquet.Leaf(parquet.ByteArrayType)
+	case core.StringKind:
+		return parquet.String()
+	}
+	// Arrays and tuples
+	return parquet.JSON()
+}
+
+func isHashedIndexed(t core.Type) bool {
+	switch t.Kind {
+	case core.BytesKind, core.StringKind, core.SliceKind, core.ArrayKind, core.TupleKind:
+		return true
+	}
+	return false
+}
+
+// row converts a decoded log to a parquet row.
+func (t *table) row(chainId string, ev *core.DecodedEvent) (parquet.Row, error) {
+	l := ev.Log
+	var pos [3]uint64
+	for i, v := range []string{l.BlockNumber, l.TransactionIndex, l.LogIndex} {
+		n, err := core.HexQtyToUint64(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log position %q: %w", v, err)
+		}
+		pos[i] = n
+	}
+	blockHash, err := core.ParseHash(l.BlockHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block hash: %w", err)
+	}
+	txHash, err := core.ParseHash(l.TransactionHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction hash: %w", err)
+	}
+	address, err := core.ParseAddressBytes(l.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log address: %w", err)
+	}
+
+	row := make(parquet.Row, len(t.index))
+	set := func(name string, v parquet.Value) {
+		i := t.index[name]
+		row[i] = v.Level(0, 0, i)
+	}
+	set(colChainId, parquet.ByteArrayValue([]byte(chainId)))
+	set(colBlockNumber, parquet.Int64Value(int64(pos[0])))
+	set(colBlockHash, parquet.FixedLenByteArrayValue(blockHash[:]))
+	set(colTransactionHash, parquet.FixedLenByteArrayValue(txHash[:]))
+	set(colTransactionIndex, parquet.Int64Value(int64(pos[1])))
+	set(colLogIndex, parquet.Int64Value(int64(pos[2])))
+	set(colAddress, parquet.FixedLenByteArrayValue(address[:]))
+
+	// Filled when Options.Enrichment includes the block
+	i := t.index[colBlockTimestamp]
+	if l.Context != nil && !l.Context.BlockTimestamp.IsZero() {
+		row[i] = parquet.Int64Value(l.Context.BlockTimestamp.UnixMilli()).Level(0, 1, i)
+	} else {
+		row[i] = parquet.NullValue().Level(0, 0, i)
+	}
+
+	for j, v := range ev.Values {
+		in := t.event.Inputs[j]
+		value, err := columnValue(in, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s of %s: %w", t.columns[j], t.event.Signature(), err)
+		}
+		set(t.columns[j], value)
+	}
+	return row, nil
+}
+
+// columnValue converts a decoded value to the physical value of its column.
+func columnValue(arg core.Argument, v any) (parquet.Value, error) {
+	switch x := v.(type) {
+	case *big.Int:
+		if arg.Type.Size <= 64 {
+			if arg.Type.Size <= 32 {
+				return parquet.Int32Value(int32(x.Int64())), nil
+			}
+			if arg.Type.Kind == core.UintKind {
+				return parquet.Int64Value(int64(x.Uint64())), nil
+			}
+			return parquet.Int64Value(x.Int64()), nil
+		}
+		word, err := core.EncodeArguments([]core.Argument{arg}, []any{x})
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.FixedLenByteArrayValue(word), nil
+	case bool:
+		return parquet.BooleanValue(x), nil
+	case core.AddressBytes:
+		return parquet.FixedLenByteArrayValue(x[:]), nil
+	case core.Hash:
+		return parquet.FixedLenByteArrayValue(x[:]), nil
+	case []byte:
+		if arg.Type.Kind == core.FixedBytesKind {
+			return parquet.FixedLenByteArrayValue(x), nil
+		}
+		return parquet.ByteArrayValue(x), nil
+	case string:
+		return parquet.ByteArrayValue([]byte(x)), nil
+	case []any:
+		b, err := json.Marshal(core.JSONValue(x))
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.ByteArrayValue(b), nil
+	}
+	return parquet.Value{}, fmt.Errorf("unexpected %T", v)
+}
+
+// blockNumber reads the block of a row.
+func (t *table) blockNumber(row parquet.Row) uint64 {
+	return uint64(row[t.index[colBlockNumber]].Int64())
+}
+
+// dirName is the partition directory of the event, e.g. "event=Transfer".
+func (t *table) dirName() string {
+	return "event=" + t.event.Name
+}
@@ -0,0 +1,263 @@
+// Package postgres is a core.Sink storing decoded events in PostgreSQL, one table per event.
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+)
+
+// DB is the subset of *pgx.Conn and *pgxpool.Pool the sink uses.
+type DB interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Config describes the stored events and where they go.
+type Config struct {
+	// Events are the event declarations to store, e.g. "Transfer(address indexed from, address indexed to, uint256 value)".
+	// Logs of other events are ignored.
+	Events []string
+	// Schema of the tables, "public" by default
+	Schema string
+	// TablePrefix is prepended to event table names, events sharing a name need distinct sinks and prefixes
+	TablePrefix string
+	// CursorTable holds the chain cursors, DefaultCursorTable by default
+	CursorTable string
+}
+
+// Sink writes every batch in one transaction: the logs of each event table with COPY, then the cursor.
+// It is also a core.CursorStore, set it as Options.CursorStore so the cursor only advances with the data.
+// Tables are created on first use, see Migrate.
+type Sink struct {
+	db          DB
+	schema      string
+	cursorTable string
+	tables      map[core.EventKey]table
+	// order is the creation and deletion order of the tables
+	order []table
+
+	mu       sync.Mutex
+	migrated bool
+}
+
+// New creates a sink writing to db, no query is sent before the first write.
+func New(db DB, cfg Config) (*Sink, error) {
+	if cfg.Schema == "" {
+		cfg.Schema = "public"
+	}
+	if cfg.CursorTable == "" {
+		cfg.CursorTable = DefaultCursorTable
+	}
+
+	s := &Sink{
+		db:          db,
+		schema:      cfg.Schema,
+		cursorTable: cfg.CursorTable,
+		tables:      make(map[core.EventKey]table, len(cfg.Events)),
+	}
+	names := make(map[string]string, len(cfg.Events))
+	for _, declaration := range cfg.Events {
+		event, err := core.ParseEvent(declaration)
+		if err != nil {
+			return nil, err
+		}
+		if event.Anonymous {
+			return nil, fmt.Errorf("anonymous event %s can't be matched by topic", event.Signature())
+		}
+		t, err := newTable(cfg.TablePrefix, event)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := names[t.name]; ok {
+			return nil, fmt.Errorf("events %s and %s both map to table %s", other, declaration, t.name)
+		}
+		names[t.name] = declaration
+
+		s.tables[event.Key()] = t
+		s.order = append(s.order, t)
+	}
+	return s, nil
+}
+
+// Schema returns the DDL statements creating the event and cursor tables, all idempotent.
+func (s *Sink) Schema() []string {
+	var stmts []string
+	// public always exists, and creating it needs rights the indexer may lack
+	if s.schema != "public" {
+		stmts = append(stmts, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", pgx.Identifier{s.schema}.Sanitize()))
+	}
+	for _, t := range s.order {
+		stmts = append(stmts, t.createSQL(s.schema))
+	}
+	return append(stmts, cursorTableSQL(s.schema, s.cursorTable))
+}
+
+// Migrate creates the missing tables in one transaction.
+func (s *Sink) Migrate(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.migrate(ctx)
+}
+
+func (s *Sink) migrate(ctx context.Context) error {
+	if s.migrated {
+		return nil
+	}
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		for _, stmt := range s.Schema() {
+			if _, err := tx.Exec(ctx, stmt); err != nil {
+				return fmt.Errorf("failed to migrate: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.migrated = true
+	return nil
+}
+
+// Write stores the logs of the batch and its cursor atomically.
+// Rows already stored in the batch range are replaced, so replaying a window is safe.
+func (s *Sink) Write(ctx context.Context, batch core.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.migrate(ctx); err != nil {
+		return err
+	}
+
+	rows := make(map[string][][]any, len(s.order))
+	for _, l := range batch.Logs {
+		t, ok := s.tableOf(l)
+		if !ok {
+			continue
+		}
+		ev, err := t.event.DecodeLog(l)
+		if err != nil {
+			return fmt.Errorf("failed to decode log %s of transaction %s: %w", l.LogIndex, l.TransactionHash, err)
+		}
+		row, err := t.row(ev)
+		if err != nil {
+			return err
+		}
+		row[0] = batch.ChainId
+		rows[t.name] = append(rows[t.name], row)
+	}
+
+	return pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		for _, t := range s.order {
+			_, err := tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE chain_id = $1 AND block_number BETWEEN $2 AND $3",
+				pgx.Identifier{s.schema, t.name}.Sanitize()), batch.ChainId, int64(batch.FromBlock), int64(batch.ToBlock))
+			if err != nil {
+				return fmt.Errorf("failed to clear %s: %w", t.name, err)
+			}
+			if len(rows[t.name]) == 0 {
+				continue
+			}
+			if _, err := tx.CopyFrom(ctx, pgx.Identifier{s.schema, t.name}, t.columnNames(), pgx.CopyFromRows(rows[t.name])); err != nil {
+				return fmt.Errorf("failed to copy into %s: %w", t.name, err)
+			}
+		}
+		if batch.Cursor.ChainId == "" {
+			return nil
+		}
+		return s.saveCursor(ctx, tx, batch.Cursor)
+	})
+}
+
+// tableOf finds the table of a log by its topics, logs of other events have none.
+func (s *Sink) tableOf(l core.Log) (table, bool) {
+	key, ok := core.LogEventKey(l)
+	if !ok {
+		return table{}, false
+	}
+	t, ok := s.tables[key]
+	return t, ok
+}
+
+// Rollback deletes the rows of the chain above ancestor from every event table.
+func (s *Sink) Rollback(ctx context.Context, chainId string, ancestor uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.migrate(ctx); err != nil {
+		return err
+	}
+
+	return pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		for _, t := range s.order {
+			_, err := tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE chain_id = $1 AND block_number > $2",
+				pgx.Identifier{s.schema, t.name}.Sanitize()), chainId, int64(ancestor))
+			if err != nil {
+				return fmt.Errorf("failed to roll back %s: %w", t.name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Flush is a no-op, every Write is committed.
+func (s *Sink) Flush(ctx context.Context) error {
+	return nil
+}
+
+// LoadCursor implements core.CursorStore.
+func (s *Sink) LoadCursor(ctx context.Context, chainId string) (*core.Cursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.migrate(ctx); err != nil {
+		return nil, err
+	}
+
+	var raw []byte
+	err := s.db.QueryRow(ctx, fmt.Sprintf("SELECT cursor FROM %s WHERE chain_id = $1",
+		pgx.Identifier{s.schema, s.cursorTable}.Sanitize()), chainId).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cursor: %w", err)
+	}
+
+	var cursor core.Cursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, fmt.Errorf("invalid stored cursor: %w", err)
+	}
+	return &cursor, nil
+}
+
+// SaveCursor implements core.CursorStore, the processor calls it after reorgs and on stop.
+func (s *Sink) SaveCursor(ctx context.Context, cursor core.Cursor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.migrate(ctx); err != nil {
+		return err
+	}
+	return s.saveCursor(ctx, s.db, cursor)
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func (s *Sink) saveCursor(ctx context.Context, db execer, cursor core.Cursor) error {
+	raw, err := json.Marshal(cursor)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(ctx, fmt.Sprintf(`INSERT INTO %s (chain_id, block_number, cursor) VALUES ($1, $2, $3)
+ON CONFLICT (chain_id) DO UPDATE SET block_number = EXCLUDED.block_number, cursor = EXCLUDED.cursor, updated_at = now()`,
+		pgx.Identifier{s.schema, s.cursorTable}.Sanitize()), cursor.ChainId, int64(cursor.BlockNumber), raw)
+	if err != nil {
+		return fmt.Errorf("failed to save cursor: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+)
+
+// DefaultCursorTable stores one cursor per chain.
+const DefaultCursorTable = "indexer_cursors"
+
+// metadataColumns lead every event table, the primary key is (chain_id, block_number, log_index).
+var metadataColumns = []column{
+	{name: "chain_id", sqlType: "TEXT"},
+	{name: "block_number", sqlType: "BIGINT"},
+	{name: "block_hash", sqlType: "TEXT"},
+	{name: "tx_hash", sqlType: "TEXT"},
+	{name: "tx_index", sqlType: "BIGINT"},
+	{name: "log_index", sqlType: "BIGINT"},
+	{name: "address", sqlType: "TEXT"},
+}
+
+type column struct {
+	name    string
+	sqlType string
+	// arg is the event parameter stored in the column, nil for metadata
+	arg *core.Argument
+}
+
+// table is the table of one event declaration.
+type table struct {
+	name    string
+	event   core.Event
+	columns []column
+}
+
+// newTable maps an event to a table named after it in snake case, e.g. "Transfer" to prefix+"transfer".
+// Parameters become typed columns after the metadata, unnamed ones are arg0, arg1...
+func newTable(prefix string, event core.Event) (table, error) {
+	t := table{
+		name:    prefix + snakeCase(event.Name),
+		event:   event,
+		columns: append([]column{}, metadataColumns...),
+	}
+	seen := make(map[string]bool, len(t.columns)+len(event.Inputs))
+	for _, c := range t.columns {
+		seen[c.name] = true
+	}
+
+	for i := range event.Inputs {
+		in := &event.Inputs[i]
+		name := snakeCase(in.Name)
+		if name == "" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		// Parameters named like metadata, e.g. "address"
+		if seen[name] {
+			name = "evt_" + name
+		}
+		if seen[name] {
+			return table{}, fmt.Errorf("event %s has duplicate column %s", event.Signature(), name)
+		}
+		seen[name] = true
+		t.columns = append(t.columns, column{name: name, sqlType: columnType(*in), arg: in})
+	}
+	return t, nil
+}
+
+// columnType maps an ABI type to its Postgres column type.
+// Integers that fit BIGINT use it, wider ones NUMERIC. Arrays and tuples are JSONB.
+func columnType(arg core.Argument) string {
+	t := arg.Type
+	if arg.Indexed && isHashedIndexed(t) {
+		// Only the keccak256 is in the topic
+		return "BYTEA"
+	}
+	switch t.Kind {
+	case core.UintKind:
+		if t.Size < 64 {
+			return "BIGINT"
+		}
+		return "NUMERIC(78, 0)"
+	case core.IntKind:
+		if t.Size <= 64 {
+			return "BIGINT"
+		}
+		return "NUMERIC(78, 0)"
+	case core.BoolKind:
+		return "BOOLEAN"
+	case core.AddressKind, core.StringKind:
+		return "TEXT"
+	case core.FixedBytesKind, core.BytesKind:
+		return "BYTEA"
+	}
+	return "JSONB"
+}
+
+func isHashedIndexed(t core.Type) bool {
+	switch t.Kind {
+	case core.BytesKind, core.StringKind, core.SliceKind, core.ArrayKind, core.TupleKind:
+		return true
+	}
+	return false
+}
+
+// columnNames returns the columns in COPY order.
+func (t table) columnNames() []string {
+	names := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// createSQL is the idempotent DDL of the table.
+func (t table) createSQL(schema string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE IF NOT EXISTS %s (\n", pgx.Identifier{schema, t.name}.Sanitize())
+	for _, c := range t.columns {
+		null := ""
+		if c.arg == nil {
+			null = " NOT NULL"
+		}
+		fmt.Fprintf(&b, "\t%s %s%s,\n", pgx.Identifier{c.name}.Sanitize(), c.sqlType, null)
+	}
+	b.WriteString("\tPRIMARY KEY (chain_id, block_number, log_index)\n)")
+	return b.String()
+}
+
+// row converts a decoded log to the values of the table columns.
+func (t table) row(ev *core.DecodedEvent) ([]any, error) {
+	l := ev.Log
+	blockNumber, err := core.HexQtyToUint64(l.BlockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block number %q: %w", l.BlockNumber, err)
+	}
+	txIndex, err := core.HexQtyToUint64(l.TransactionIndex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction index %q: %w", l.TransactionIndex, err)
+	}
+	logIndex, err := core.HexQtyToUint64(l.LogIndex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log index %q: %w", l.LogIndex, err)
+	}
+
+	row := make([]any, 0, len(t.columns))
+	row = append(row, "", int64(blockNumber), strings.ToLower(l.BlockHash), strings.ToLower(l.TransactionHash),
+		int64(txIndex), int64(logIndex), strings.ToLower(l.Address))
+	for i, v := range ev.Values {
+		c := t.columns[len(metadataColumns)+i]
+		value, err := columnValue(c, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s of %s: %w", c.name, t.event.Signature(), err)
+		}
+		row = append(row, value)
+	}
+	return row, nil
+}
+
+// columnValue converts a decoded value to what pgx encodes into the column type.
+func columnValue(c column, v any) (any, error) {
+	switch c.sqlType {
+	case "BIGINT":
+		n, ok := v.(*big.Int)
+		if !ok || !n.IsInt64() {
+			return nil, fmt.Errorf("%v doesn't fit BIGINT", v)
+		}
+		return n.Int64(), nil
+	case "NUMERIC(78, 0)":
+		n, ok := v.(*big.Int)
+		if !ok {
+			return nil, fmt.Errorf("expected *big.Int, got %T", v)
+		}
+		return pgtype.Numeric{Int: n, Valid: true}, nil
+	case "TEXT":
+		switch x := v.(type) {
+		case core.AddressBytes:
+			return x.Hex(), nil
+		case string:
+			return x, nil
+		}
+	case "BYTEA":
+		switch x := v.(type) {
+		case []byte:
+			return x, nil
+		case core.Hash:
+			return x[:], nil
+		}
+	case "BOOLEAN":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case "JSONB":
+		return json.Marshal(core.JSONValue(v))
+	}
+	return nil, fmt.Errorf("unexpected %T for %s", v, c.sqlType)
+}
+
+// snakeCase converts identifiers like "TokenExchange" or "amount0In" to "token_exchange" and "amount0_in".
+func snakeCase(s string) string {
+	s = strings.TrimLeft(s, "_")
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Split before an upper case letter following a lower case one or a digit,
+			// and before the last letter of an acronym, e.g. "NFTMinted" to "nft_minted"
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// cursorTableSQL is the DDL of the cursor table.
+func cursorTableSQL(schema, name string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	chain_id TEXT PRIMARY KEY,
+	block_number BIGINT NOT NULL,
+	cursor JSONB NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`, pgx.Identifier{schema, name}.Sanitize())
+}
@@ -0,0 +1,299 @@
+// Package sqlite is a core.Sink and core.CursorStore keeping logs, decoded events and cursors in one SQLite file.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+	_ "modernc.org/sqlite"
+)
+
+// schema is idempotent, it runs on every New.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS logs (
+	chain_id TEXT NOT NULL,
+	block_number INTEGER NOT NULL,
+	block_hash TEXT NOT NULL,
+	tx_hash TEXT NOT NULL,
+	tx_index INTEGER NOT NULL,
+	log_index INTEGER NOT NULL,
+	address TEXT NOT NULL,
+	topic0 TEXT,
+	topic1 TEXT,
+	topic2 TEXT,
+	topic3 TEXT,
+	data TEXT NOT NULL,
+	PRIMARY KEY (chain_id, block_number, log_index)
+)`,
+	`CREATE TABLE IF NOT EXISTS events (
+	chain_id TEXT NOT NULL,
+	block_number INTEGER NOT NULL,
+	log_index INTEGER NOT NULL,
+	tx_hash TEXT NOT NULL,
+	address TEXT NOT NULL,
+	name TEXT NOT NULL,
+	signature TEXT NOT NULL,
+	args TEXT NOT NULL,
+	PRIMARY KEY (chain_id, block_number, log_index)
+)`,
+	`CREATE INDEX IF NOT EXISTS events_by_name ON events (chain_id, name, block_number)`,
+	`CREATE TABLE IF NOT EXISTS cursors (
+	chain_id TEXT PRIMARY KEY,
+	block_number INTEGER NOT NULL,
+	children TEXT NOT NULL,
+	updated_at INTEGER NOT NULL
+)`,
+	`CREATE TABLE IF NOT EXISTS window_hashes (
+	chain_id TEXT NOT NULL,
+	block_number INTEGER NOT NULL,
+	hash TEXT NOT NULL,
+	PRIMARY KEY (chain_id, block_number)
+)`,
+}
+
+// Config lists the events decoded into the events table.
+type Config struct {
+	// Events are event declarations, e.g. "Transfer(address indexed from, address indexed to, uint256 value)".
+	// Every log is stored raw, the ones matching an event are also stored decoded, their args as a JSON object.
+	Events []string
+}
+
+// Sink stores each batch, and its cursor, in one SQLite transaction.
+// Set it as both a sink and Options.CursorStore: after a crash the data and the cursor are either both
+// committed or both absent, so a restart resumes exactly after the last stored window.
+// It also works as a plain CursorStore, without being one of the sinks.
+type Sink struct {
+	db      *sql.DB
+	decoder *core.Decoder
+}
+
+// Open opens, or creates, the database file at path in WAL mode.
+func Open(path string, cfg Config) (*Sink, error) {
+	dsn := "file:" + path + "?" + url.Values{"_pragma": {
+		"journal_mode(WAL)",
+		// Commits stay atomic, a power loss may only drop the last ones, cursor included
+		"synchronous(NORMAL)",
+		"busy_timeout(5000)",
+	}}.Encode()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite has a single writer, and each connection to ":memory:" is its own database
+	db.SetMaxOpenConns(1)
+
+	s, err := New(db, cfg)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// New creates the tables in db if needed. db must use a SQLite driver.
+func New(db *sql.DB, cfg Config) (*Sink, error) {
+	decoder := core.NewDecoder()
+	for _, declaration := range cfg.Events {
+		if _, err := decoder.Register(declaration); err != nil {
+			return nil, err
+		}
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("failed to create schema: %w", err)
+		}
+	}
+	return &Sink{db: db, decoder: decoder}, nil
+}
+
+// DB returns the underlying database, e.g. to query the stored events.
+func (s *Sink) DB() *sql.DB {
+	return s.db
+}
+
+// Close closes the database.
+func (s *Sink) Close() error {
+	return s.db.Close()
+}
+
+// Write stores the logs of the batch, raw and decoded, then its cursor.
+// Rows already stored in the batch range are replaced, so replaying a window is safe.
+func (s *Sink) Write(ctx context.Context, batch core.Batch) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := deleteRange(ctx, tx, batch.ChainId, "block_number BETWEEN ? AND ?", batch.FromBlock, batch.ToBlock); err != nil {
+			return err
+		}
+
+		insertLog, err := tx.PrepareContext(ctx, `INSERT INTO logs (chain_id, block_number, block_hash, tx_hash, tx_index, log_index,
+	address, topic0, topic1, topic2, topic3, data) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer insertLog.Close()
+		insertEvent, err := tx.PrepareContext(ctx, `INSERT INTO events (chain_id, block_number, log_index, tx_hash, address,
+	name, signature, args) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer insertEvent.Close()
+
+		for _, l := range batch.Logs {
+			blockNumber, err := core.HexQtyToUint64(l.BlockNumber)
+			if err != nil {
+				return fmt.Errorf("invalid block number %q: %w", l.BlockNumber, err)
+			}
+			txIndex, err := core.HexQtyToUint64(l.TransactionIndex)
+			if err != nil {
+				return fmt.Errorf("invalid transaction index %q: %w", l.TransactionIndex, err)
+			}
+			logIndex, err := core.HexQtyToUint64(l.LogIndex)
+			if err != nil {
+				return fmt.Errorf("invalid log index %q: %w", l.LogIndex, err)
+			}
+
+			var topics [4]any
+			for i := 0; i < len(l.Topics) && i < len(topics); i++ {
+				if t, ok := l.Topics[i].(string); ok {
+					topics[i] = strings.ToLower(t)
+				}
+			}
+			txHash := strings.ToLower(l.TransactionHash)
+			address := strings.ToLower(l.Address)
+			_, err = insertLog.ExecContext(ctx, batch.ChainId, blockNumber, strings.ToLower(l.BlockHash), txHash, txIndex, logIndex,
+				address, topics[0], topics[1], topics[2], topics[3], l.Data)
+			if err != nil {
+				return fmt.Errorf("failed to insert log %d of block %d: %w", logIndex, blockNumber, err)
+			}
+
+			// Logs of other events are only stored raw
+			ev, err := s.decoder.Decode(l)
+			if err != nil {
+				continue
+			}
+			args := make(map[string]any, len(ev.Args))
+			for name, v := range ev.Args {
+				args[name] = core.JSONValue(v)
+			}
+			raw, err := json.Marshal(args)
+			if err != nil {
+				return err
+			}
+			if _, err := insertEvent.ExecContext(ctx, batch.ChainId, blockNumber, logIndex, txHash, address, ev.Name, ev.Signature, string(raw)); err != nil {
+				return fmt.Errorf("failed to insert event %s of block %d: %w", ev.Name, blockNumber, err)
+			}
+		}
+
+		if batch.Cursor.ChainId == "" {
+			return nil
+		}
+		return saveCursor(ctx, tx, batch.Cursor)
+	})
+}
+
+// Rollback deletes the logs and events of the chain above ancestor.
+func (s *Sink) Rollback(ctx context.Context, chainId string, ancestor uint64) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return deleteRange(ctx, tx, chainId, "block_number > ?", ancestor)
+	})
+}
+
+// Flush is a no-op, every Write is committed.
+func (s *Sink) Flush(ctx context.Context) error {
+	return nil
+}
+
+// LoadCursor implements core.CursorStore.
+func (s *Sink) LoadCursor(ctx context.Context, chainId string) (*core.Cursor, error) {
+	cursor := core.Cursor{ChainId: chainId}
+	var children string
+	err := s.db.QueryRowContext(ctx, "SELECT block_number, children FROM cursors WHERE chain_id = ?", chainId).Scan(&cursor.BlockNumber, &children)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cursor: %w", err)
+	}
+	if err := json.Unmarshal([]byte(children), &cursor.Children); err != nil {
+		return nil, fmt.Errorf("invalid stored children: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT block_number, hash FROM window_hashes WHERE chain_id = ? ORDER BY block_number", chainId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load window hashes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var wh core.WindowHash
+		if err := rows.Scan(&wh.BlockNumber, &wh.Hash); err != nil {
+			return nil, err
+		}
+		cursor.WindowHashes = append(cursor.WindowHashes, wh)
+	}
+	return &cursor, rows.Err()
+}
+
+// SaveCursor implements core.CursorStore, the processor calls it after reorgs and on stop.
+func (s *Sink) SaveCursor(ctx context.Context, cursor core.Cursor) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return saveCursor(ctx, tx, cursor)
+	})
+}
+
+// saveCursor replaces the cursor of the chain and its window-hash ring.
+func saveCursor(ctx context.Context, tx *sql.Tx, cursor core.Cursor) error {
+	children := cursor.Children
+	if children == nil {
+		children = []core.ChildContract{}
+	}
+	raw, err := json.Marshal(children)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO cursors (chain_id, block_number, children, updated_at) VALUES (?, ?, ?, ?)
+ON CONFLICT (chain_id) DO UPDATE SET block_number = excluded.block_number, children = excluded.children, updated_at = excluded.updated_at`,
+		cursor.ChainId, cursor.BlockNumber, string(raw), time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save cursor: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM window_hashes WHERE chain_id = ?", cursor.ChainId); err != nil {
+		return fmt.Errorf("failed to save window hashes: %w", err)
+	}
+	for _, wh := range cursor.WindowHashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO window_hashes (chain_id, block_number, hash) VALUES (?, ?, ?)",
+			cursor.ChainId, wh.BlockNumber, wh.Hash); err != nil {
+			return fmt.Errorf("failed to save window hashes: %w", err)
+		}
+	}
+	return nil
+}
+
+// deleteRange deletes the logs and events of a chain matching the block condition.
+func deleteRange(ctx context.Context, tx *sql.Tx, chainId string, cond string, args ...any) error {
+	for _, table := range []string{"logs", "events"} {
+		query := fmt.Sprintf("DELETE FROM %s WHERE chain_id = ? AND %s", table, cond)
+		if _, err := tx.ExecContext(ctx, query, append([]any{chainId}, args...)...); err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func (s *Sink) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
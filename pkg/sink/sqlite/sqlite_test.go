@@ -0,0 +1,141 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ryuux05/indexer-sdk-go/internal/sinktest"
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+const testFrom = "0x28c6c06298d514db089934071355e5743bf21d60"
+
+func count(t *testing.T, s *Sink, table string) int {
+	var n int
+	assert.NoError(t, s.DB().QueryRow("SELECT count(*) FROM "+table).Scan(&n))
+	return n
+}
+
+func TestSink_WriteRollback(t *testing.T) {
+	ctx := context.Background()
+	s, err := Open(filepath.Join(t.TempDir(), "index.db"), Config{Events: []string{sinktest.TransferDecl}})
+	assert.NoError(t, err)
+	defer s.Close()
+
+	unknown := sinktest.Transfer{Block: 3, Value: 1}.Log()
+	unknown.Topics = []any{"0x" + fmt.Sprintf("%064x", 1)}
+	batch := core.Batch{
+		ChainId: "1", FromBlock: 1, ToBlock: 10,
+		Logs:   []core.Log{sinktest.Transfer{Block: 2, Value: 100}.Log(), unknown, sinktest.Transfer{Block: 9, Value: 300}.Log()},
+		Cursor: core.Cursor{ChainId: "1", BlockNumber: 10, WindowHashes: []core.WindowHash{{BlockNumber: 10, Hash: sinktest.BlockHash(10)}}},
+	}
+	assert.NoError(t, s.Write(ctx, batch))
+	// Replaying a window replaces its rows
+	assert.NoError(t, s.Write(ctx, batch))
+	assert.Equal(t, 3, count(t, s, "logs"))
+	assert.Equal(t, 2, count(t, s, "events"))
+
+	var name, value, from string
+	err = s.DB().QueryRow("SELECT name, json_extract(args, '$.value'), json_extract(args, '$.from') FROM events WHERE block_number = 9").Scan(&name, &value, &from)
+	assert.NoError(t, err)
+	assert.Equal(t, "Transfer", name)
+	assert.Equal(t, "300", value)
+	assert.Equal(t, fmt.Sprintf("0x%040x", 9), from)
+
+	assert.NoError(t, s.Rollback(ctx, "1", 2))
+	assert.Equal(t, 1, count(t, s, "logs"))
+	assert.Equal(t, 1, count(t, s, "events"))
+	// Other chains are untouched
+	assert.NoError(t, s.Rollback(ctx, "2", 0))
+	assert.Equal(t, 1, count(t, s, "logs"))
+}
+
+func TestSink_CursorStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "index.db")
+	s, err := Open(path, Config{})
+	assert.NoError(t, err)
+
+	cursor, err := s.LoadCursor(ctx, "1")
+	assert.NoError(t, err)
+	assert.Nil(t, cursor)
+
+	want := core.Cursor{
+		ChainId:      "1",
+		BlockNumber:  30,
+		WindowHashes: []core.WindowHash{{BlockNumber: 20, Hash: sinktest.BlockHash(20)}, {BlockNumber: 30, Hash: sinktest.BlockHash(30)}},
+		Children:     []core.ChildContract{{Address: sinktest.Token, Factory: testFrom, BlockNumber: 12}},
+	}
+	assert.NoError(t, s.SaveCursor(ctx, core.Cursor{ChainId: "1", BlockNumber: 5, WindowHashes: []core.WindowHash{{BlockNumber: 5, Hash: "0x05"}}}))
+	assert.NoError(t, s.SaveCursor(ctx, want))
+	assert.NoError(t, s.Close())
+
+	s, err = Open(path, Config{})
+	assert.NoError(t, err)
+	defer s.Close()
+	cursor, err = s.LoadCursor(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, &want, cursor)
+}
+
+// run indexes up to head with the sink as sink and cursor store, and returns the first block fetched.
+func run(t *testing.T, path string, head uint64) uint64 {
+	s, err := Open(path, Config{Events: []string{sinktest.TransferDecl}})
+	assert.NoError(t, err)
+	defer s.Close()
+
+	rpc := sinktest.NewRPC(head)
+	processor := core.NewProcessor()
+	err = processor.AddChain(core.ChainInfo{ChainId: "1", RPC: rpc}, &core.Options{
+		RangeSize:          5,
+		FetcherConcurrency: 2,
+		LogsBufferSize:     10,
+		Sinks:              []core.Sink{s},
+		CursorStore:        s,
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	logsCh, _ := processor.Logs("1")
+	go func() {
+		for range logsCh {
+		}
+	}()
+	go processor.Run(ctx)
+
+	for {
+		cursor, err := s.LoadCursor(ctx, "1")
+		assert.NoError(t, err)
+		if cursor != nil && cursor.BlockNumber >= head {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Test timeout, cursor %v", cursor)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	assert.NoError(t, processor.Stop(ctx))
+	return rpc.FirstLog()
+}
+
+func TestSink_ResumesExactlyOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+
+	assert.Equal(t, uint64(1), run(t, path, 20))
+	// The restart resumes after the stored cursor
+	assert.Equal(t, uint64(21), run(t, path, 30))
+
+	s, err := Open(path, Config{})
+	assert.NoError(t, err)
+	defer s.Close()
+	var n, distinct int
+	assert.NoError(t, s.DB().QueryRow("SELECT count(*), count(DISTINCT block_number) FROM events").Scan(&n, &distinct))
+	assert.Equal(t, 30, n)
+	assert.Equal(t, 30, distinct)
+}
@@ -0,0 +1,276 @@
+// Package webhook is a core.Sink POSTing every batch of events as signed JSON to an HTTP endpoint.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+)
+
+// Headers of every delivery.
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderTimestamp is the unix time of the attempt, in seconds
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature is "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Payload types.
+const (
+	TypeBatch    = "batch"
+	TypeRollback = "rollback"
+)
+
+// Config describes the endpoint and the delivered events.
+type Config struct {
+	URL string
+	// Secret signs the deliveries, empty sends them unsigned
+	Secret string
+	// Events are event declarations, only matching logs are delivered, decoded.
+	// Empty delivers every log raw.
+	Events []string
+	// RetryConfig retries deliveries on 429, 5xx and unreachable endpoints, core.DefaultRetryConfig by default
+	RetryConfig *core.RetryConfig
+	// Client defaults to a client with a 10s timeout
+	Client *http.Client
+}
+
+// Payload is the body of a delivery.
+type Payload struct {
+	Type string `json:"type"`
+	// IdempotencyKey is "<chainId>:<from>-<to>" for batches and "<chainId>:rollback:<ancestor>:<sequence>" for rollbacks.
+	// The sequence is a nanosecond timestamp, so two rollbacks to the same ancestor get different keys
+	IdempotencyKey string `json:"idempotencyKey"`
+	ChainId        string `json:"chainId"`
+	// FromBlock and ToBlock are the range of a batch
+	FromBlock uint64  `json:"fromBlock,omitempty"`
+	ToBlock   uint64  `json:"toBlock,omitempty"`
+	Events    []Event `json:"events,omitempty"`
+	// Ancestor is the last block kept by a rollback, the receiver drops everything above it
+	Ancestor *uint64 `json:"ancestor,omitempty"`
+}
+
+// Event is a delivered log, decoded when it matches Config.Events.
+type Event struct {
+	BlockNumber      uint64 `json:"blockNumber"`
+	BlockHash        string `json:"blockHash"`
+	TransactionHash  string `json:"transactionHash"`
+	TransactionIndex uint64 `json:"transactionIndex"`
+	LogIndex         uint64 `json:"logIndex"`
+	Address          string `json:"address"`
+	Topics           []any  `json:"topics,omitempty"`
+	Data             string `json:"data,omitempty"`
+	// Name, Signature and Args of a decoded event, args use core.JSONValue
+	Name      string         `json:"name,omitempty"`
+	Signature string         `json:"signature,omitempty"`
+	Args      map[string]any `json:"args,omitempty"`
+}
+
+// Sink delivers each batch with events in one POST before Write returns, so the cursor only moves
+// once the receiver acknowledged it with a 2xx. Deliveries are at-least-once: a batch is sent again
+// after a failed attempt or a restart, with the same idempotency key.
+//
+// A reorg sends a rollback payload before the replaced range is delivered again under the same keys,
+// receivers forget the keys above the ancestor along with the data.
+type Sink struct {
+	url     string
+	secret  []byte
+	decoder *core.Decoder
+	retry   core.RetryConfig
+	client  *http.Client
+
+	mu sync.Mutex
+	// lastRollback is the sequence of the last rollback
+	lastRollback int64
+}
+
+// New creates the sink.
+func New(cfg Config) (*Sink, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("missing webhook url")
+	}
+	s := &Sink{url: cfg.URL, secret: []byte(cfg.Secret), client: cfg.Client}
+	if s.client == nil {
+		s.client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.RetryConfig != nil {
+		s.retry = *cfg.RetryConfig
+	} else {
+		s.retry = core.DefaultRetryConfig()
+	}
+	if len(cfg.Events) > 0 {
+		s.decoder = core.NewDecoder()
+		for _, e := range cfg.Events {
+			if _, err := s.decoder.Register(e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// Write delivers the events of the batch, batches without any are skipped.
+func (s *Sink) Write(ctx context.Context, batch core.Batch) error {
+	payload := Payload{
+		Type:           TypeBatch,
+		IdempotencyKey: fmt.Sprintf("%s:%d-%d", batch.ChainId, batch.FromBlock, batch.ToBlock),
+		ChainId:        batch.ChainId,
+		FromBlock:      batch.FromBlock,
+		ToBlock:        batch.ToBlock,
+	}
+	for _, l := range batch.Logs {
+		ev, ok, err := s.event(l)
+		if err != nil {
+			return err
+		}
+		if ok {
+			payload.Events = append(payload.Events, ev)
+		}
+	}
+	if len(payload.Events) == 0 {
+		return nil
+	}
+	return s.deliver(ctx, payload)
+}
+
+func (s *Sink) event(l core.Log) (Event, bool, error) {
+	ev := Event{BlockHash: l.BlockHash, TransactionHash: l.TransactionHash, Address: l.Address}
+	var err error
+	if ev.BlockNumber, err = core.HexQtyToUint64(l.BlockNumber); err != nil {
+		return ev, false, fmt.Errorf("invalid block number %q: %w", l.BlockNumber, err)
+	}
+	if ev.TransactionIndex, err = core.HexQtyToUint64(l.TransactionIndex); err != nil {
+		return ev, false, fmt.Errorf("invalid transaction index %q: %w", l.TransactionIndex, err)
+	}
+	if ev.LogIndex, err = core.HexQtyToUint64(l.LogIndex); err != nil {
+		return ev, false, fmt.Errorf("invalid log index %q: %w", l.LogIndex, err)
+	}
+
+	if s.decoder == nil {
+		ev.Topics = l.Topics
+		ev.Data = l.Data
+		return ev, true, nil
+	}
+	decoded, err := s.decoder.Decode(l)
+	if err != nil {
+		// Logs of other events are not delivered
+		return ev, false, nil
+	}
+	ev.Name = decoded.Name
+	ev.Signature = decoded.Signature
+	ev.Args = make(map[string]any, len(decoded.Args))
+	for name, v := range decoded.Args {
+		ev.Args[name] = core.JSONValue(v)
+	}
+	return ev, true, nil
+}
+
+// Rollback notifies the receiver that blocks above ancestor were orphaned.
+func (s *Sink) Rollback(ctx context.Context, chainId string, ancestor uint64) error {
+	return s.deliver(ctx, Payload{
+		Type:           TypeRollback,
+		IdempotencyKey: fmt.Sprintf("%s:rollback:%d:%d", chainId, ancestor, s.rollbackSequence()),
+		ChainId:        chainId,
+		Ancestor:       &ancestor,
+	})
+}
+
+// rollbackSequence returns an increasing nanosecond timestamp, unique across restarts unless the clock goes back.
+func (s *Sink) rollbackSequence() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seq := time.Now().UnixNano()
+	if seq <= s.lastRollback {
+		seq = s.lastRollback + 1
+	}
+	s.lastRollback = seq
+	return seq
+}
+
+// Flush is a no-op, Write returns once the batch is delivered.
+func (s *Sink) Flush(ctx context.Context) error {
+	return nil
+}
+
+// deliver POSTs the payload, retrying with backoff. Each attempt is signed with its own timestamp.
+func (s *Sink) deliver(ctx context.Context, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshaling payload: %w", err)
+	}
+	err = core.RetryWithBackoff(ctx, s.retry, func() error {
+		return s.post(ctx, payload.IdempotencyKey, body)
+	})
+	if err != nil {
+		return fmt.Errorf("webhook delivery %s failed: %w", payload.IdempotencyKey, err)
+	}
+	return nil
+}
+
+func (s *Sink) post(ctx context.Context, key string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating http request: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderIdempotencyKey, key)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if len(s.secret) > 0 {
+		req.Header.Set(HeaderSignature, Sign(s.secret, timestamp, body))
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		// An unreachable receiver is retried like one answering 503
+		return &core.HTTPError{StatusCode: http.StatusServiceUnavailable, Message: err.Error()}
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return &core.HTTPError{StatusCode: res.StatusCode, Message: res.Status}
+	}
+	return nil
+}
+
+// Sign returns the HeaderSignature value of a body sent at timestamp.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature headers of a delivery, rejecting timestamps older than tolerance.
+// A tolerance of 0 skips the age check.
+func Verify(secret []byte, header http.Header, body []byte, tolerance time.Duration) error {
+	timestamp := header.Get(HeaderTimestamp)
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", HeaderTimestamp, timestamp)
+	}
+	if tolerance > 0 && time.Since(time.Unix(sent, 0)) > tolerance {
+		return fmt.Errorf("delivery timestamp %s is too old", timestamp)
+	}
+	if !hmac.Equal([]byte(header.Get(HeaderSignature)), []byte(Sign(secret, timestamp, body))) {
+		return fmt.Errorf("invalid %s", HeaderSignature)
+	}
+	return nil
+}
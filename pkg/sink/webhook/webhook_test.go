@@ -0,0 +1,174 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ryuux05/indexer-sdk-go/internal/sinktest"
+	"github.com/ryuux05/indexer-sdk-go/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "s3cret"
+
+func testRetry() *core.RetryConfig {
+	return &core.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 2}
+}
+
+// receiver verifies and records deliveries, answering the queued statuses first.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	payloads []Payload
+	keys     []string
+	errs     []error
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys = append(r.keys, req.Header.Get(HeaderIdempotencyKey))
+	if err := Verify([]byte(testSecret), req.Header, body, time.Minute); err != nil {
+		r.errs = append(r.errs, err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if len(r.statuses) > 0 {
+		status := r.statuses[0]
+		r.statuses = r.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+	var p Payload
+	json.Unmarshal(body, &p)
+	r.payloads = append(r.payloads, p)
+}
+
+func TestSink_Deliver(t *testing.T) {
+	ctx := context.Background()
+	recv := &receiver{}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	sink, err := New(Config{URL: srv.URL, Secret: testSecret, Events: []string{sinktest.TransferDecl}, RetryConfig: testRetry()})
+	assert.NoError(t, err)
+
+	other := sinktest.TransferLog(4, 1)
+	other.Topics = []any{fmt.Sprintf("0x%064x", 1)}
+	assert.NoError(t, sink.Write(ctx, core.Batch{ChainId: "1", FromBlock: 1, ToBlock: 10, Logs: []core.Log{sinktest.TransferLog(3, 1), other}}))
+	// Nothing to deliver
+	assert.NoError(t, sink.Write(ctx, core.Batch{ChainId: "1", FromBlock: 11, ToBlock: 20, Logs: []core.Log{other}}))
+
+	recv.mu.Lock()
+	defer recv.mu.Unlock()
+	assert.Empty(t, recv.errs)
+	assert.Equal(t, []string{"1:1-10"}, recv.keys)
+	if assert.Len(t, recv.payloads, 1) {
+		p := recv.payloads[0]
+		assert.Equal(t, TypeBatch, p.Type)
+		assert.Equal(t, "1:1-10", p.IdempotencyKey)
+		assert.Equal(t, uint64(1), p.FromBlock)
+		assert.Equal(t, uint64(10), p.ToBlock)
+		assert.Nil(t, p.Ancestor)
+		if assert.Len(t, p.Events, 1) {
+			assert.Equal(t, "Transfer", p.Events[0].Name)
+			assert.Equal(t, uint64(3), p.Events[0].BlockNumber)
+			assert.Equal(t, uint64(1), p.Events[0].LogIndex)
+			assert.Equal(t, "301", p.Events[0].Args["value"])
+			assert.Equal(t, fmt.Sprintf("0x%040x", 3), p.Events[0].Args["from"])
+		}
+	}
+}
+
+func TestSink_Retry(t *testing.T) {
+	ctx := context.Background()
+	recv := &receiver{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	sink, _ := New(Config{URL: srv.URL, Secret: testSecret, RetryConfig: testRetry()})
+	assert.NoError(t, sink.Write(ctx, core.Batch{ChainId: "1", FromBlock: 1, ToBlock: 10, Logs: []core.Log{sinktest.TransferLog(3, 1)}}))
+
+	recv.mu.Lock()
+	// Every attempt carries the same key, raw logs without Events
+	assert.Equal(t, []string{"1:1-10", "1:1-10", "1:1-10"}, recv.keys)
+	if assert.Len(t, recv.payloads, 1) && assert.Len(t, recv.payloads[0].Events, 1) {
+		assert.Equal(t, sinktest.TransferLog(3, 1).Data, recv.payloads[0].Events[0].Data)
+		assert.Empty(t, recv.payloads[0].Events[0].Name)
+	}
+	recv.statuses = []int{http.StatusBadRequest}
+	recv.mu.Unlock()
+
+	// Client errors are not retried, the chain fails with them
+	err := sink.Write(ctx, core.Batch{ChainId: "1", FromBlock: 11, ToBlock: 20, Logs: []core.Log{sinktest.TransferLog(12, 1)}})
+	var httpErr *core.HTTPError
+	if assert.True(t, errors.As(err, &httpErr)) {
+		assert.Equal(t, http.StatusBadRequest, httpErr.StatusCode)
+	}
+	recv.mu.Lock()
+	assert.Len(t, recv.keys, 4)
+	recv.mu.Unlock()
+
+	// An unreachable receiver is retried until attempts run out
+	srv.Close()
+	err = sink.Write(ctx, core.Batch{ChainId: "1", FromBlock: 21, ToBlock: 30, Logs: []core.Log{sinktest.TransferLog(22, 1)}})
+	assert.ErrorContains(t, err, "max retry attempts (3) exceeded")
+}
+
+func TestSink_Rollback(t *testing.T) {
+	recv := &receiver{statuses: []int{http.StatusServiceUnavailable}}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	sink, _ := New(Config{URL: srv.URL, Secret: testSecret, RetryConfig: testRetry()})
+	assert.NoError(t, sink.Rollback(context.Background(), "1", 0))
+	// A second reorg back to the same ancestor
+	assert.NoError(t, sink.Rollback(context.Background(), "1", 0))
+
+	recv.mu.Lock()
+	defer recv.mu.Unlock()
+	if assert.Len(t, recv.payloads, 2) && assert.Len(t, recv.keys, 3) {
+		p := recv.payloads[0]
+		assert.Equal(t, TypeRollback, p.Type)
+		assert.True(t, strings.HasPrefix(p.IdempotencyKey, "1:rollback:0:"), p.IdempotencyKey)
+		if assert.NotNil(t, p.Ancestor) {
+			assert.Equal(t, uint64(0), *p.Ancestor)
+		}
+		assert.Empty(t, p.Events)
+
+		// Retries keep the key, each rollback gets its own
+		assert.Equal(t, recv.keys[0], recv.keys[1])
+		assert.Equal(t, p.IdempotencyKey, recv.keys[1])
+		assert.NotEqual(t, p.IdempotencyKey, recv.payloads[1].IdempotencyKey)
+		assert.True(t, strings.HasPrefix(recv.payloads[1].IdempotencyKey, "1:rollback:0:"))
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"type":"batch"}`)
+	now := fmt.Sprint(time.Now().Unix())
+	header := http.Header{}
+	header.Set(HeaderTimestamp, now)
+	header.Set(HeaderSignature, Sign([]byte(testSecret), now, body))
+	assert.NoError(t, Verify([]byte(testSecret), header, body, time.Minute))
+
+	assert.Error(t, Verify([]byte("other"), header, body, time.Minute))
+	assert.Error(t, Verify([]byte(testSecret), header, []byte(`{"type":"rollback"}`), time.Minute))
+
+	old := fmt.Sprint(time.Now().Add(-time.Hour).Unix())
+	header.Set(HeaderTimestamp, old)
+	header.Set(HeaderSignature, Sign([]byte(testSecret), old, body))
+	assert.Error(t, Verify([]byte(testSecret), header, body, time.Minute))
+	assert.NoError(t, Verify([]byte(testSecret), header, body, 0))
+}